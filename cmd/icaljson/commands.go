@@ -5,8 +5,17 @@
 package cmd
 
 import (
+	"bytes"
+	"cmp"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/beyondcivic/icaljson/pkg/icaljson"
 	"github.com/beyondcivic/icaljson/pkg/version"
@@ -33,23 +42,50 @@ func versionCmd() *cobra.Command {
 // Generate command
 func generateCmd() *cobra.Command {
 	var generateCmd = &cobra.Command{
-		Use:   "generate [icsPath]",
+		Use:   "generate [icsPath|url]",
 		Short: "Generate JSON from a ICS file",
-		Long:  `Generate JSON from a ICS file, automatically inferring data types.`,
-		Args:  cobra.ExactArgs(1),
+		Long: `Generate JSON from a ICS file, automatically inferring data types.
+
+The input can also be an http://, https:// or webcal:// URL of a published
+calendar feed. Feeds are cached and re-requested conditionally (ETag /
+Last-Modified); when the feed has not changed since the last run and the
+output file exists, no output is written and the command exits with status 3.
+A feed is only cached once its output has been written.
+
+Malformed lines and components are skipped and counted; with --strict the
+command fails on the first one instead, reporting its line and column.
+
+Inline (base64) attachments are kept in the JSON by default. With
+--extract-attachments DIR they are decoded into DIR and replaced by file
+references relative to the JSON output. Existing files in DIR are never
+overwritten: taken names get a numeric suffix, and files with the same content
+are reused.
+
+E-mails (.eml) and mailboxes (.mbox) are searched for text/calendar and
+application/ics parts, e.g. invitations. Each calendar found is converted
+with the e-mail's From, To, Subject and Date under "message"; when there are
+several, the output files are numbered (invite-1.json, invite-2.json, ...).`,
+		Args: cobra.ExactArgs(1),
 		Run: func(cmd *cobra.Command, args []string) {
 			icsPath := args[0]
 			flagOutputPath, _ := cmd.Flags().GetString("output")
+			flagStrict, _ := cmd.Flags().GetBool("strict")
+			flagAttachmentDir, _ := cmd.Flags().GetString("extract-attachments")
+			isURL := icaljson.IsURL(icsPath)
+			parseOpts := parseOptions(cmd)
+			parseOpts.Strict = flagStrict
 
 			// Validate input file
-			if !fileExists(icsPath) {
-				fmt.Printf("Error: ICS file '%s' does not exist.\n", icsPath)
-				os.Exit(1)
-			}
+			if !isURL {
+				if !fileExists(icsPath) {
+					fmt.Printf("Error: ICS file '%s' does not exist.\n", icsPath)
+					os.Exit(exitRead)
+				}
 
-			if !isICalFile(icsPath) {
-				fmt.Printf("Error: File '%s' does not appear to be a ICS file.\n", icsPath)
-				os.Exit(1)
+				if !isICalFile(icsPath) && !icaljson.IsMailFile(icsPath) {
+					fmt.Printf("Error: File '%s' does not appear to be a ICS file or e-mail.\n", icsPath)
+					os.Exit(exitUsage)
+				}
 			}
 
 			// Determine output path
@@ -58,25 +94,1260 @@ func generateCmd() *cobra.Command {
 			// Validate output path
 			if err := icaljson.ValidateOutputPath(outputPath); err != nil {
 				fmt.Printf("Error: Invalid output path: %v\n", err)
-				os.Exit(1)
+				os.Exit(exitCode(err))
+			}
+
+			filterOpts, err := filterOptions(cmd)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
 			}
 
 			// Generate metadata
 			fmt.Printf("Generating JSON file for '%s'...\n", icsPath)
-			_, err := icaljson.Generate(icsPath, outputPath)
-			if err != nil {
-				fmt.Printf("Error generating metadata: %v\n", err)
-				os.Exit(1)
+			var calendar *icaljson.Calendar
+			var calendars []*icaljson.Calendar
+			var feed *icaljson.FetchResult
+			if isURL {
+				// Cache the feed only once its output is written, see below
+				fetchOpts := fetchOptions(cmd)
+				fetchOpts.DeferCache = true
+				result, err := icaljson.Fetch(cmd.Context(), icsPath, fetchOpts)
+				if err != nil {
+					fmt.Printf("Error generating metadata: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				feed = result
+				if result.NotModified && fileExists(outputPath) {
+					fmt.Printf("Feed not modified since last fetch, '%s' left unchanged.\n", outputPath)
+					os.Exit(exitNotModified)
+				}
+				calendar, err = icaljson.ParseWithOptions(bytes.NewReader(result.Body), parseOpts)
+				if err != nil {
+					fmt.Printf("Error generating metadata: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+			} else if icaljson.IsMailFile(icsPath) {
+				calendars, err = icaljson.ParseMailFile(icsPath, parseOpts)
+				if err != nil {
+					fmt.Printf("Error generating metadata: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				fmt.Printf("Found %d calendar(s) in '%s'\n", len(calendars), icsPath)
+			} else {
+				calendar, err = icaljson.ParseFileWithOptions(icsPath, parseOpts)
+				if err != nil {
+					fmt.Printf("Error generating metadata: %v\n", err)
+					os.Exit(exitCode(err))
+				}
 			}
+			if calendar != nil {
+				calendars = append(calendars, calendar)
+			}
+
+			for i, calendar := range calendars {
+				calendarOutputPath := numberedOutputPath(outputPath, i, len(calendars))
+				if n := len(calendar.Diagnostics); n > 0 {
+					fmt.Printf("Warning: %d problem(s) found while parsing, run 'icaljson validate' for details.\n", n)
+				}
 
-			fmt.Printf("✓ JSON file generated successfully")
-			if outputPath != "" {
-				fmt.Printf(" and saved to: %s\n", outputPath)
+				if !filterOpts.IsZero() {
+					calendar, err = filterOpts.Apply(calendar)
+					if err != nil {
+						fmt.Printf("Error filtering events: %v\n", err)
+						os.Exit(exitCode(err))
+					}
+				}
+
+				if err := applyDescriptionFormat(cmd, calendar); err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+
+				if flagAttachmentDir != "" {
+					written, err := icaljson.ExtractAttachments(calendar, flagAttachmentDir, filepath.Dir(calendarOutputPath))
+					if err != nil {
+						fmt.Printf("Error extracting attachments: %v\n", err)
+						os.Exit(exitCode(err))
+					}
+					fmt.Printf("Extracted %d attachment(s) to '%s'\n", len(written), flagAttachmentDir)
+				}
+
+				if err := icaljson.WriteJSON(calendar, calendarOutputPath); err != nil {
+					fmt.Printf("Error generating metadata: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+
+				fmt.Printf("✓ JSON file generated successfully")
+				if calendarOutputPath != "" {
+					fmt.Printf(" and saved to: %s\n", calendarOutputPath)
+				}
+			}
+
+			if feed != nil {
+				if err := feed.StoreCache(); err != nil {
+					fmt.Printf("Error caching feed: %v\n", err)
+					os.Exit(exitCode(err))
+				}
 			}
 
 		},
 	}
 	generateCmd.Flags().StringP("output", "o", "", "Output path for the JSON file")
+	generateCmd.Flags().Bool("strict", false, "Fail on the first malformed line or component")
+	generateCmd.Flags().String("extract-attachments", "", "Write inline attachments to this directory and reference them from the JSON")
+	addFetchFlags(generateCmd)
+	addParseFlags(generateCmd)
+	addFilterFlags(generateCmd)
+	addDescriptionFlags(generateCmd)
 
 	return generateCmd
 }
+
+// Query command
+func queryCmd() *cobra.Command {
+	var queryCmd = &cobra.Command{
+		Use:   "query [icsPath|url] [expression]",
+		Short: "Select and project events with a query expression",
+		Long: `Select events with a typed expression over their JSON fields, then sort,
+limit and project them.
+
+Expressions compare fields with = != < <= > >=, match regular expressions with
+~ and !~ (case-insensitive), and test membership with 'in' and 'contains'.
+Conditions combine with and, or, not and parentheses. Dates are written as
+2025-10-01 or 2025-10-01T10:00:00Z, lists as ["a", "b"]. A query may end with
+'order by field [asc|desc], ...' and 'limit n'.
+
+Example:
+  icaljson query cal.ics 'start >= 2025-10-01 and "music" in categories and location ~ "Zurich"' \
+    --select uid,summary,start --format table`,
+		Args: cobra.RangeArgs(1, 2),
+		Run: func(cmd *cobra.Command, args []string) {
+			expression := ""
+			if len(args) > 1 {
+				expression = args[1]
+			}
+			flagSelect, _ := cmd.Flags().GetString("select")
+			flagSort, _ := cmd.Flags().GetString("sort")
+			flagLimit, _ := cmd.Flags().GetInt("limit")
+			flagFormat, _ := cmd.Flags().GetString("format")
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+
+			query, err := icaljson.ParseQuery(expression)
+			if err != nil {
+				fmt.Printf("Error: Invalid query: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			if flagSort != "" {
+				if query.OrderBy, err = icaljson.ParseSortKeys(flagSort); err != nil {
+					err = invalidFlag("--sort", err)
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+			}
+			if flagLimit > 0 {
+				query.Limit = flagLimit
+			}
+			fields := icaljson.ParseFieldList(flagSelect)
+			if err := icaljson.CheckFields(fields); err != nil {
+				err = invalidFlag("--select", err)
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			filterOpts, err := filterOptions(cmd)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			calendar, err := loadCalendar(cmd, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			if !filterOpts.IsZero() {
+				if calendar, err = filterOpts.Apply(calendar); err != nil {
+					fmt.Printf("Error filtering events: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+			}
+
+			if err := applyDescriptionFormat(cmd, calendar); err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			events, err := query.Run(calendar.Events)
+			if err != nil {
+				fmt.Printf("Error running query: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			out, err := openOutput(flagOutputPath)
+			if err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			defer out.Close()
+
+			if err := icaljson.WriteEvents(out, events, fields, flagFormat); err != nil {
+				fmt.Printf("Error writing results: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+		},
+	}
+	queryCmd.Flags().String("select", "", "Comma-separated fields to output (JSON names, e.g. uid,summary,start)")
+	queryCmd.Flags().String("sort", "", "Comma-separated sort fields, prefix with - for descending (overrides 'order by')")
+	queryCmd.Flags().Int("limit", 0, "Maximum number of events (overrides 'limit')")
+	queryCmd.Flags().StringP("format", "f", icaljson.FormatJSON, "Output format: "+strings.Join(icaljson.OutputFormats, ", "))
+	queryCmd.Flags().StringP("output", "o", "", "Output file (default: stdout)")
+	addFetchFlags(queryCmd)
+	addParseFlags(queryCmd)
+	addFilterFlags(queryCmd)
+	addDescriptionFlags(queryCmd)
+
+	return queryCmd
+}
+
+// Merge command
+func mergeCmd() *cobra.Command {
+	var mergeCmd = &cobra.Command{
+		Use:   "merge [icsPath|url]...",
+		Short: "Merge several calendars into one",
+		Long: `Merge several calendars into one JSON calendar.
+
+Events sharing a UID (and RECURRENCE-ID) are resolved by SEQUENCE, then
+LAST-MODIFIED, then DTSTAMP with the 'newest' strategy; 'first' keeps the
+event from the earliest input and 'fail' aborts on conflicting events.
+Tasks and availability components are merged the same way. Each merged
+component records its input in the "source" field. Events without a
+shared UID can be checked for near-duplicates by summary, start and location.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+			flagStrategy, _ := cmd.Flags().GetString("strategy")
+			flagDetect, _ := cmd.Flags().GetBool("detect-duplicates")
+			flagDrop, _ := cmd.Flags().GetBool("drop-duplicates")
+			flagSimilarity, _ := cmd.Flags().GetFloat64("similarity")
+			flagWindow, _ := cmd.Flags().GetDuration("window")
+			flagReport, _ := cmd.Flags().GetString("report")
+
+			if err := icaljson.ValidateOutputPath(flagOutputPath); err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			var calendars []*icaljson.Calendar
+			for _, input := range args {
+				calendar, err := loadCalendar(cmd, input)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				calendars = append(calendars, calendar)
+			}
+
+			policy := icaljson.MergePolicy{
+				Strategy:           icaljson.MergeStrategy(flagStrategy),
+				DetectDuplicates:   flagDetect,
+				DropDuplicates:     flagDrop,
+				DuplicateThreshold: flagSimilarity,
+				DuplicateWindow:    flagWindow,
+			}
+			merged, report, err := icaljson.Merge(policy, calendars...)
+			if err != nil {
+				fmt.Printf("Error merging calendars: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			if err := icaljson.WriteJSON(merged, flagOutputPath); err != nil {
+				fmt.Printf("Error writing merged calendar: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			fmt.Printf("✓ Merged %d calendars into %d events and %d tasks and saved to: %s\n",
+				len(report.Sources), report.Events, report.Todos, flagOutputPath)
+			for _, conflict := range report.Conflicts {
+				fmt.Printf("  conflict %s: kept %s (%s), discarded %d\n",
+					conflict.UID, conflict.Kept.Source, conflict.Reason, len(conflict.Discarded))
+			}
+			for _, dup := range report.Duplicates {
+				action := "possible duplicate"
+				if dup.Dropped {
+					action = "dropped duplicate"
+				}
+				fmt.Printf("  %s (%.2f): %q [%s] ~ %q [%s]\n", action, dup.Score,
+					dup.First.Summary, dup.First.Source, dup.Second.Summary, dup.Second.Source)
+			}
+
+			if flagReport != "" {
+				data, err := json.MarshalIndent(report, "", "  ")
+				if err == nil {
+					err = os.WriteFile(flagReport, data, 0600)
+				}
+				if err != nil {
+					fmt.Printf("Error writing merge report: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+			}
+		},
+	}
+	mergeCmd.Flags().StringP("output", "o", "merged.json", "Output path for the merged JSON calendar")
+	mergeCmd.Flags().String("strategy", string(icaljson.MergeKeepNewest), "Conflict strategy for shared UIDs: newest, first or fail")
+	mergeCmd.Flags().Bool("detect-duplicates", false, "Report near-duplicate events without a shared UID")
+	mergeCmd.Flags().Bool("drop-duplicates", false, "Remove the later event of each near-duplicate pair")
+	mergeCmd.Flags().Float64("similarity", icaljson.DefaultDuplicateThreshold, "Minimum similarity (0-1) of near-duplicates")
+	mergeCmd.Flags().Duration("window", icaljson.DefaultDuplicateWindow, "Maximum start time difference of near-duplicates")
+	mergeCmd.Flags().String("report", "", "Write the merge report as JSON to this file")
+	addFetchFlags(mergeCmd)
+	addParseFlags(mergeCmd)
+
+	return mergeCmd
+}
+
+// Diff command
+func diffCmd() *cobra.Command {
+	var diffCmd = &cobra.Command{
+		Use:   "diff [old] [new]",
+		Short: "Show added, removed and changed events between two calendars",
+		Long: `Compare two versions of a calendar. Either side can be an ICS file, a JSON
+file written by generate, or a feed URL.
+
+Events, tasks and availability components are matched by UID plus
+RECURRENCE-ID. The result is printed as text,
+as an RFC 6902 JSON Patch against the old JSON document (--format json-patch),
+or as a machine-readable change list (--format json).`,
+		Args: cobra.ExactArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagFormat, _ := cmd.Flags().GetString("format")
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+			flagExitCode, _ := cmd.Flags().GetBool("exit-code")
+
+			oldCal, err := loadCalendar(cmd, args[0])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			newCal, err := loadCalendar(cmd, args[1])
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			diff, err := icaljson.Diff(oldCal, newCal)
+			if err != nil {
+				fmt.Printf("Error comparing calendars: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			out, err := openOutput(flagOutputPath)
+			if err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			switch flagFormat {
+			case "text":
+				err = diff.WriteText(out)
+			case "json":
+				err = writeIndentedJSON(out, diff)
+			case "json-patch":
+				err = writeIndentedJSON(out, diff.JSONPatch())
+			default:
+				err = invalidFlag("--format", fmt.Errorf("unsupported format %q", flagFormat))
+			}
+			out.Close()
+			if err != nil {
+				fmt.Printf("Error writing diff: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			if flagExitCode && diff.HasChanges() {
+				os.Exit(exitDifferences)
+			}
+		},
+	}
+	diffCmd.Flags().StringP("format", "f", "text", "Output format: text, json or json-patch")
+	diffCmd.Flags().StringP("output", "o", "", "Output file (default: stdout)")
+	diffCmd.Flags().Bool("exit-code", false, "Exit with status 4 when the calendars differ")
+	addFetchFlags(diffCmd)
+	addParseFlags(diffCmd)
+
+	return diffCmd
+}
+
+// Validate command
+func validateCmd() *cobra.Command {
+	var validateCmd = &cobra.Command{
+		Use:   "validate [icsPath...]",
+		Short: "Check iCal files for RFC 5545 conformance",
+		Long: `Lint one or more iCal files and report problems with line numbers and the
+RFC section they violate.
+
+Checks include missing UID, DTSTAMP, PRODID and VERSION, unbalanced
+BEGIN/END, DTEND before DTSTART, DTEND together with DURATION, invalid
+dates, durations, recurrence rules and GEO values, TZIDs without a
+VTIMEZONE, and lines longer than 75 octets.
+
+Diagnostics are printed as text, JSON or SARIF (--format sarif) for code
+scanning. The command exits with status 1 when errors are found, and with
+--strict also when warnings are found.`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagFormat, _ := cmd.Flags().GetString("format")
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+			flagStrict, _ := cmd.Flags().GetBool("strict")
+
+			var reports []icaljson.ValidationReport
+			for _, path := range args {
+				if !fileExists(path) {
+					fmt.Printf("Error: ICS file '%s' does not exist\n", path)
+					os.Exit(exitRead)
+				}
+				diagnostics, err := icaljson.ValidateFileWithOptions(path, parseOptions(cmd))
+				if err != nil {
+					fmt.Printf("Error validating %s: %v\n", path, err)
+					os.Exit(exitCode(err))
+				}
+				if diagnostics == nil {
+					diagnostics = []icaljson.Diagnostic{}
+				}
+				reports = append(reports, icaljson.ValidationReport{Path: path, Diagnostics: diagnostics})
+			}
+
+			out, err := openOutput(flagOutputPath)
+			if err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			switch flagFormat {
+			case "text":
+				err = icaljson.WriteValidationText(out, reports)
+			case "json":
+				err = writeIndentedJSON(out, reports)
+			case "sarif":
+				err = writeIndentedJSON(out, icaljson.ValidationSARIF(reports, version.Version))
+			default:
+				err = invalidFlag("--format", fmt.Errorf("unsupported format %q", flagFormat))
+			}
+			out.Close()
+			if err != nil {
+				fmt.Printf("Error writing diagnostics: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			for _, report := range reports {
+				if report.Errors() > 0 || (flagStrict && report.Warnings() > 0) {
+					os.Exit(exitError)
+				}
+			}
+		},
+	}
+	validateCmd.Flags().StringP("format", "f", "text", "Output format: text, json or sarif")
+	validateCmd.Flags().StringP("output", "o", "", "Output file (default: stdout)")
+	validateCmd.Flags().Bool("strict", false, "Also exit with status 1 when warnings are found")
+	addParseFlags(validateCmd)
+
+	return validateCmd
+}
+
+// Tree command
+func treeCmd() *cobra.Command {
+	var treeCmd = &cobra.Command{
+		Use:   "tree [icsPath|url]",
+		Short: "Show the RELATED-TO hierarchy of events and tasks",
+		Long: `Resolve the RELATED-TO properties of events and tasks (VTODO) by UID and
+print the parent/child trees. RELTYPE=PARENT (the default) and CHILD are
+merged, RELTYPE=SIBLING is listed next to each component.
+
+References to UIDs that are not in the calendar and cycles in the hierarchy
+are reported after the trees. Use --format json for a nested document.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagFormat, _ := cmd.Flags().GetString("format")
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+
+			calendar, err := loadCalendar(cmd, args[0])
+			if err != nil {
+				fmt.Printf("Error loading %s: %v\n", args[0], err)
+				os.Exit(exitCode(err))
+			}
+			graph := calendar.Graph()
+
+			out, err := openOutput(flagOutputPath)
+			if err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			switch flagFormat {
+			case "text":
+				err = graph.WriteText(out)
+			case "json":
+				err = writeIndentedJSON(out, graph)
+			default:
+				err = invalidFlag("--format", fmt.Errorf("unsupported format %q", flagFormat))
+			}
+			out.Close()
+			if err != nil {
+				fmt.Printf("Error writing tree: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+		},
+	}
+	treeCmd.Flags().StringP("format", "f", "text", "Output format: text or json")
+	treeCmd.Flags().StringP("output", "o", "", "Output file (default: stdout)")
+	addFetchFlags(treeCmd)
+	addParseFlags(treeCmd)
+
+	return treeCmd
+}
+
+// Free/busy command
+func freebusyCmd() *cobra.Command {
+	var freebusyCmd = &cobra.Command{
+		Use:   "freebusy [icsPath|url]...",
+		Short: "Publish the busy time of calendars without event details",
+		Long: `Compute the busy periods of one or more calendars between --from and --to
+and write them as a VFREEBUSY (RFC 5545) or as a JSON list of intervals.
+
+Recurring events are expanded. Events marked TRANSP:TRANSPARENT or
+STATUS:CANCELLED count as free time, TENTATIVE events as BUSY-TENTATIVE.
+Overlapping periods are merged and all periods are clipped to the window.
+Floating times and all-day events are taken in the local time zone.
+
+Example:
+  icaljson freebusy work.ics private.ics --from 2025-10-06 --to 2025-10-13 \
+    --organizer mailto:ana@example.com -o ana.ifb`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagFrom, _ := cmd.Flags().GetString("from")
+			flagTo, _ := cmd.Flags().GetString("to")
+			flagFormat, _ := cmd.Flags().GetString("format")
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+			flagOrganizer, _ := cmd.Flags().GetString("organizer")
+
+			from, err := icaljson.ParseTimeBound(flagFrom, time.Local)
+			if err != nil {
+				err = invalidFlag("--from", err)
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			to, err := icaljson.ParseTimeBound(flagTo, time.Local)
+			if err != nil {
+				err = invalidFlag("--to", err)
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			combined := &icaljson.Calendar{}
+			for _, input := range args {
+				calendar, err := loadCalendar(cmd, input)
+				if err != nil {
+					fmt.Printf("Error loading %s: %v\n", input, err)
+					os.Exit(exitCode(err))
+				}
+				combined.Events = append(combined.Events, calendar.Events...)
+				combined.Availability = append(combined.Availability, calendar.Availability...)
+			}
+
+			freeBusy, err := combined.FreeBusy(from, to)
+			if err != nil {
+				fmt.Printf("Error computing free/busy time: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			if flagOrganizer != "" {
+				freeBusy.Organizer = flagOrganizer
+			}
+
+			out, err := openOutput(flagOutputPath)
+			if err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			switch flagFormat {
+			case "ics":
+				err = icaljson.WriteFreeBusy(out, freeBusy)
+			case "json":
+				err = writeIndentedJSON(out, freeBusy)
+			default:
+				err = invalidFlag("--format", fmt.Errorf("unsupported format %q", flagFormat))
+			}
+			out.Close()
+			if err != nil {
+				fmt.Printf("Error writing free/busy time: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+		},
+	}
+	freebusyCmd.Flags().String("from", "", "Start of the window (RFC 3339, YYYY-MM-DD, 'now' or 'today')")
+	freebusyCmd.Flags().String("to", "", "End of the window (RFC 3339, YYYY-MM-DD, 'now' or 'today')")
+	freebusyCmd.Flags().StringP("format", "f", "ics", "Output format: ics or json")
+	freebusyCmd.Flags().StringP("output", "o", "", "Output file (default: stdout)")
+	freebusyCmd.Flags().String("organizer", "", "Calendar user the free/busy time belongs to, e.g. mailto:ana@example.com")
+	freebusyCmd.MarkFlagRequired("from")
+	freebusyCmd.MarkFlagRequired("to")
+	addFetchFlags(freebusyCmd)
+	addParseFlags(freebusyCmd)
+
+	return freebusyCmd
+}
+
+// Conflicts command
+func conflictsCmd() *cobra.Command {
+	var conflictsCmd = &cobra.Command{
+		Use:   "conflicts [icsPath|url]...",
+		Short: "Find double-booked resources, attendees and locations",
+		Long: `Find overlapping events in one or more calendars that share a resource, an
+attendee or a location, and report each pair with the time they overlap.
+
+Recurring events are expanded. Transparent and cancelled events, and
+attendees who declined, are ignored. Resources are RESOURCES values, VRESOURCE
+names and attendees with CUTYPE=ROOM or RESOURCE; locations are compared
+ignoring case, punctuation and spacing. An event that appears in several
+calendars with the same UID is only considered once.
+
+Without --to the search is open-ended, unless an event recurs forever (an
+RRULE without COUNT or UNTIL): then it ends one year after --from (or after
+now), and a note on stderr says so.
+
+Example:
+  icaljson conflicts rooms.ics team.ics --by resources,attendees --from today --to 2025-12-31`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagBy, _ := cmd.Flags().GetStringSlice("by")
+			flagFrom, _ := cmd.Flags().GetString("from")
+			flagTo, _ := cmd.Flags().GetString("to")
+			flagFormat, _ := cmd.Flags().GetString("format")
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+			flagExitCode, _ := cmd.Flags().GetBool("exit-code")
+
+			opts := icaljson.ConflictOptions{By: flagBy}
+			var err error
+			if opts.From, err = icaljson.ParseTimeBound(flagFrom, time.Local); err != nil {
+				err = invalidFlag("--from", err)
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			if opts.To, err = icaljson.ParseTimeBound(flagTo, time.Local); err != nil {
+				err = invalidFlag("--to", err)
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			calendars := make([]*icaljson.Calendar, 0, len(args))
+			for _, input := range args {
+				calendar, err := loadCalendar(cmd, input)
+				if err != nil {
+					fmt.Printf("Error loading %s: %v\n", input, err)
+					os.Exit(exitCode(err))
+				}
+				calendars = append(calendars, calendar)
+			}
+
+			if opts.To.IsZero() && hasInfiniteEvent(calendars) {
+				opts.To = time.Now()
+				if opts.From.After(opts.To) {
+					opts.To = opts.From
+				}
+				opts.To = opts.To.AddDate(1, 0, 0)
+				fmt.Fprintf(os.Stderr, "Note: an event recurs forever, searching until %s (set --to to change)\n", opts.To.Format(time.RFC3339))
+			}
+
+			conflicts, err := icaljson.FindConflicts(opts, calendars...)
+			if err != nil {
+				fmt.Printf("Error finding conflicts: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			out, err := openOutput(flagOutputPath)
+			if err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			switch flagFormat {
+			case "text":
+				err = icaljson.WriteConflictsText(out, conflicts)
+			case "json":
+				err = writeIndentedJSON(out, conflicts)
+			default:
+				err = invalidFlag("--format", fmt.Errorf("unsupported format %q", flagFormat))
+			}
+			out.Close()
+			if err != nil {
+				fmt.Printf("Error writing conflicts: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			if flagExitCode && len(conflicts) > 0 {
+				os.Exit(exitDifferences)
+			}
+		},
+	}
+	conflictsCmd.Flags().StringSlice("by", []string{icaljson.ConflictByResources}, "What overlapping events must share: "+strings.Join(icaljson.ConflictKeys, ", "))
+	conflictsCmd.Flags().String("from", "", "Only events ending after this time (RFC 3339, YYYY-MM-DD, 'now' or 'today')")
+	conflictsCmd.Flags().String("to", "", "Only events starting before this time (RFC 3339, YYYY-MM-DD, 'now' or 'today'; default: open, or one year after --from or now when an event recurs forever)")
+	conflictsCmd.Flags().StringP("format", "f", "text", "Output format: text or json")
+	conflictsCmd.Flags().StringP("output", "o", "", "Output file (default: stdout)")
+	conflictsCmd.Flags().Bool("exit-code", false, "Exit with status 4 when conflicts are found")
+	addFetchFlags(conflictsCmd)
+	addParseFlags(conflictsCmd)
+
+	return conflictsCmd
+}
+
+// Slots command
+func slotsCmd() *cobra.Command {
+	var slotsCmd = &cobra.Command{
+		Use:   "slots [icsPath|url]...",
+		Short: "Propose meeting times at which all calendars are free",
+		Long: `Find time slots of --duration between --from and --to at which every
+calendar is free, and list the best candidates first.
+
+A participant is free within their working hours, outside their busy time
+(recurring events expanded; transparent and cancelled events are free) plus a
+buffer before and after each event. --working-hours and --buffer set the
+defaults; prefix a value with an input and '=' to set it for one calendar.
+
+Slots that leave someone a gap too short for another meeting of the same
+length rank lower. With --tentative-free, tentative events do not block a
+slot but lower its score.
+
+Example:
+  icaljson slots ana.ics ben.ics --duration 45m --from 2025-10-06 --to 2025-10-11 \
+    --working-hours 09:00-17:30 --working-hours "ben.ics=Mon-Thu 08:00-16:00" \
+    --buffer 10m --tz Europe/Zurich`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagDuration, _ := cmd.Flags().GetDuration("duration")
+			flagFrom, _ := cmd.Flags().GetString("from")
+			flagTo, _ := cmd.Flags().GetString("to")
+			flagTZ, _ := cmd.Flags().GetString("tz")
+			flagHours, _ := cmd.Flags().GetStringArray("working-hours")
+			flagBuffers, _ := cmd.Flags().GetStringArray("buffer")
+			flagStep, _ := cmd.Flags().GetDuration("step")
+			flagTentative, _ := cmd.Flags().GetBool("tentative-free")
+			flagLimit, _ := cmd.Flags().GetInt("limit")
+			flagFormat, _ := cmd.Flags().GetString("format")
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+
+			opts, calendars, err := slotOptions(flagTZ, flagFrom, flagTo, flagHours, flagBuffers, args)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			opts.Duration = flagDuration
+			opts.Step = flagStep
+			opts.AllowTentative = flagTentative
+			opts.Limit = flagLimit
+
+			for i, input := range args {
+				if calendars[i].Calendar, err = loadCalendar(cmd, input); err != nil {
+					fmt.Printf("Error loading %s: %v\n", input, err)
+					os.Exit(exitCode(err))
+				}
+			}
+
+			slots, err := icaljson.FindSlots(opts, calendars...)
+			if err != nil {
+				fmt.Printf("Error finding slots: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			out, err := openOutput(flagOutputPath)
+			if err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			switch flagFormat {
+			case "text":
+				err = icaljson.WriteSlotsText(out, slots)
+			case "json":
+				err = writeIndentedJSON(out, slots)
+			default:
+				err = invalidFlag("--format", fmt.Errorf("unsupported format %q", flagFormat))
+			}
+			out.Close()
+			if err != nil {
+				fmt.Printf("Error writing slots: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+		},
+	}
+	slotsCmd.Flags().Duration("duration", time.Hour, "Length of the meeting")
+	slotsCmd.Flags().String("from", "", "Start of the search window (RFC 3339, YYYY-MM-DD, 'now' or 'today')")
+	slotsCmd.Flags().String("to", "", "End of the search window (RFC 3339, YYYY-MM-DD, 'now' or 'today')")
+	slotsCmd.Flags().String("tz", "", "Time zone of the window, working hours and slots (default: local time zone)")
+	slotsCmd.Flags().StringArray("working-hours", nil, "Working hours, e.g. 09:00-17:30 or 'Mon-Thu 08:00-16:00'; INPUT=HOURS for one calendar (default: Mon-Fri 09:00-17:00)")
+	slotsCmd.Flags().StringArray("buffer", nil, "Free time to keep around events, e.g. 10m; INPUT=DURATION for one calendar")
+	slotsCmd.Flags().Duration("step", icaljson.DefaultSlotStep, "Granularity of slot start times")
+	slotsCmd.Flags().Bool("tentative-free", false, "Allow slots during tentative events, ranked lower")
+	slotsCmd.Flags().Int("limit", icaljson.DefaultSlotLimit, "Maximum number of slots, -1 for all")
+	slotsCmd.Flags().StringP("format", "f", "text", "Output format: text or json")
+	slotsCmd.Flags().StringP("output", "o", "", "Output file (default: stdout)")
+	slotsCmd.MarkFlagRequired("from")
+	slotsCmd.MarkFlagRequired("to")
+	addFetchFlags(slotsCmd)
+	addParseFlags(slotsCmd)
+
+	return slotsCmd
+}
+
+// Reminders command
+func remindersCmd() *cobra.Command {
+	var remindersCmd = &cobra.Command{
+		Use:   "reminders [icsPath|url]...",
+		Short: "List upcoming alarms, or fire them when they are due",
+		Long: `List the VALARM reminders of events and tasks that fire between --from and
+--to (default: the next 24 hours). Recurring events are expanded and each
+occurrence gets its own reminders; triggers RELATED=END count from the end of
+the event or the DUE time of a task, and REPEAT/DURATION add repetitions.
+
+With --daemon, reminders are fired when they are due instead: --exec runs a
+shell command (the reminder is passed as JSON on standard input and in
+ICALJSON_REMINDER_* variables), --webhook POSTs it as JSON, and otherwise it is
+printed as one line of JSON. The calendars are reloaded every --interval.
+Fired reminders are recorded in the --state file so that none fires twice,
+also across restarts; reminders missed by up to --grace still fire. --once
+fires the due reminders and exits, e.g. when run from cron.
+
+Examples:
+  icaljson reminders samples/reminders.ics --from 2025-10-06 --to 2025-10-08
+  icaljson reminders work.ics --daemon --exec 'notify-send "$ICALJSON_REMINDER_SUMMARY"'
+  icaljson reminders https://example.com/team.ics --daemon --webhook http://localhost:8080/hook`,
+		Args: cobra.MinimumNArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagFrom, _ := cmd.Flags().GetString("from")
+			flagTo, _ := cmd.Flags().GetString("to")
+			flagTZ, _ := cmd.Flags().GetString("tz")
+			flagFormat, _ := cmd.Flags().GetString("format")
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+			flagDaemon, _ := cmd.Flags().GetBool("daemon")
+			flagOnce, _ := cmd.Flags().GetBool("once")
+
+			loc := time.Local
+			if flagTZ != "" {
+				var err error
+				if loc, err = time.LoadLocation(flagTZ); err != nil {
+					err = invalidFlag("--tz", err)
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+			}
+
+			if flagDaemon || flagOnce {
+				scheduler, err := reminderScheduler(cmd, args, loc)
+				if err != nil {
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				if flagOnce {
+					_, err = scheduler.Poll(cmd.Context())
+				} else {
+					ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+					err = scheduler.Run(ctx)
+					stop()
+				}
+				if err != nil {
+					fmt.Printf("Error firing reminders: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				return
+			}
+
+			from, err := icaljson.ParseTimeBound(cmp.Or(flagFrom, "now"), loc)
+			if err != nil {
+				err = invalidFlag("--from", err)
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			to := from.Add(24 * time.Hour)
+			if flagTo != "" {
+				if to, err = icaljson.ParseTimeBound(flagTo, loc); err != nil {
+					err = invalidFlag("--to", err)
+					fmt.Printf("Error: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+			}
+
+			reminders := []icaljson.Reminder{}
+			for _, input := range args {
+				calendar, err := loadCalendar(cmd, input)
+				if err != nil {
+					fmt.Printf("Error loading %s: %v\n", input, err)
+					os.Exit(exitCode(err))
+				}
+				found, err := calendar.Reminders(from, to)
+				if err != nil {
+					fmt.Printf("Error computing reminders: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+				reminders = append(reminders, found...)
+			}
+			slices.SortStableFunc(reminders, func(a, b icaljson.Reminder) int {
+				return a.Time.Compare(b.Time)
+			})
+			for i, r := range reminders {
+				reminders[i].Time, reminders[i].Start, reminders[i].End = r.Time.In(loc), r.Start.In(loc), r.End.In(loc)
+			}
+
+			out, err := openOutput(flagOutputPath)
+			if err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			switch flagFormat {
+			case "text":
+				err = icaljson.WriteRemindersText(out, reminders)
+			case "json":
+				err = writeIndentedJSON(out, reminders)
+			default:
+				err = invalidFlag("--format", fmt.Errorf("unsupported format %q", flagFormat))
+			}
+			out.Close()
+			if err != nil {
+				fmt.Printf("Error writing reminders: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+		},
+	}
+	remindersCmd.Flags().String("from", "", "Start of the listing window (RFC 3339, YYYY-MM-DD, 'now' or 'today'; default: now)")
+	remindersCmd.Flags().String("to", "", "End of the listing window (default: 24 hours after --from)")
+	remindersCmd.Flags().String("tz", "", "Time zone of floating date-times and listed times (default: local time zone)")
+	remindersCmd.Flags().StringP("format", "f", "text", "Output format of the listing: text or json")
+	remindersCmd.Flags().StringP("output", "o", "", "Output file of the listing (default: stdout)")
+	remindersCmd.Flags().Bool("daemon", false, "Keep running and fire reminders when they are due")
+	remindersCmd.Flags().Bool("once", false, "Fire the reminders that are due now and exit")
+	remindersCmd.Flags().String("exec", "", "Shell command to run for each reminder")
+	remindersCmd.Flags().String("webhook", "", "URL to POST each reminder to as JSON")
+	remindersCmd.Flags().String("state", icaljson.DefaultReminderStatePath(), "File recording fired reminders")
+	remindersCmd.Flags().Duration("interval", icaljson.DefaultReminderInterval, "How often the calendars are reloaded")
+	remindersCmd.Flags().Duration("grace", icaljson.DefaultReminderGrace, "How late a missed reminder still fires")
+	remindersCmd.MarkFlagsMutuallyExclusive("daemon", "once")
+	remindersCmd.MarkFlagsMutuallyExclusive("exec", "webhook")
+	addFetchFlags(remindersCmd)
+	addParseFlags(remindersCmd)
+
+	return remindersCmd
+}
+
+// iTIP command
+func itipCmd() *cobra.Command {
+	var itipCmd = &cobra.Command{
+		Use:   "itip",
+		Short: "Process iTIP scheduling messages (RFC 5546)",
+	}
+
+	var applyCmd = &cobra.Command{
+		Use:   "apply [store] [message]...",
+		Short: "Apply iTIP messages to a stored calendar",
+		Long: `Apply iTIP messages (REQUEST, REPLY, CANCEL, ADD, PUBLISH, COUNTER,
+DECLINECOUNTER, REFRESH) to a stored calendar, in the order given. The store
+can be an ICS file or a JSON file written by generate; messages can also be
+e-mails (.eml) or mailboxes (.mbox) holding iMIP messages.
+
+Updates only replace an event with a higher SEQUENCE, or the same SEQUENCE
+and a later DTSTAMP. Replies update the attendee's PARTSTAT. Cancelled events
+get STATUS:CANCELLED unless --remove-cancelled is set. COUNTER and REFRESH are
+reported for the organizer and leave the store unchanged.`,
+		Args: cobra.MinimumNArgs(2),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagOutputPath, _ := cmd.Flags().GetString("output")
+			flagReport, _ := cmd.Flags().GetString("report")
+			flagRemove, _ := cmd.Flags().GetBool("remove-cancelled")
+
+			outputPath := determineOutputPath(flagOutputPath, args[0])
+			if err := icaljson.ValidateOutputPath(outputPath); err != nil {
+				fmt.Printf("Error: Invalid output path: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			store, err := loadCalendar(cmd, args[0])
+			if err != nil {
+				fmt.Printf("Error loading %s: %v\n", args[0], err)
+				os.Exit(exitCode(err))
+			}
+
+			var changes []icaljson.Change
+			opts := icaljson.ITIPOptions{RemoveCancelled: flagRemove}
+			messages := 0
+			for _, input := range args[1:] {
+				msgs, err := loadCalendars(cmd, input)
+				if err != nil {
+					fmt.Printf("Error loading %s: %v\n", input, err)
+					os.Exit(exitCode(err))
+				}
+				for _, msg := range msgs {
+					updated, applied, err := icaljson.ApplyITIPWithOptions(store, msg, opts)
+					if err != nil {
+						fmt.Printf("Error applying %s: %v\n", input, err)
+						os.Exit(exitCode(err))
+					}
+					store = updated
+					changes = append(changes, applied...)
+				}
+				messages += len(msgs)
+			}
+
+			if err := icaljson.WriteJSON(store, outputPath); err != nil {
+				fmt.Printf("Error writing calendar: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+
+			fmt.Printf("✓ Applied %d messages with %d changes and saved to: %s\n",
+				messages, len(changes), outputPath)
+			for _, change := range changes {
+				fmt.Printf("  %s %-9s %s", change.Method, change.Kind, change.UID)
+				if change.RecurrenceID != "" {
+					fmt.Printf(" [%s]", change.RecurrenceID)
+				}
+				if change.Attendee != "" {
+					fmt.Printf(" %s", change.Attendee)
+				}
+				if change.PartStat != "" {
+					old := change.OldPartStat
+					if old == "" {
+						old = "(none)"
+					}
+					fmt.Printf(" %s -> %s", old, change.PartStat)
+				}
+				if change.Reason != "" {
+					fmt.Printf(": %s", change.Reason)
+				} else if len(change.Fields) > 0 {
+					fields := make([]string, len(change.Fields))
+					for i, field := range change.Fields {
+						fields[i] = field.Field
+					}
+					fmt.Printf(": %s", strings.Join(fields, ", "))
+				}
+				fmt.Println()
+			}
+
+			if flagReport != "" {
+				data, err := json.MarshalIndent(changes, "", "  ")
+				if err == nil {
+					err = os.WriteFile(flagReport, data, 0600)
+				}
+				if err != nil {
+					fmt.Printf("Error writing iTIP report: %v\n", err)
+					os.Exit(exitCode(err))
+				}
+			}
+		},
+	}
+	applyCmd.Flags().StringP("output", "o", "", "Output path for the updated JSON calendar (default: based on the store name)")
+	applyCmd.Flags().String("report", "", "Write the list of changes as JSON to this file")
+	applyCmd.Flags().Bool("remove-cancelled", false, "Remove cancelled events instead of marking them CANCELLED")
+	addFetchFlags(applyCmd)
+	addParseFlags(applyCmd)
+
+	var requestCmd = &cobra.Command{
+		Use:   "request [store]",
+		Short: "Build a REQUEST inviting the attendees of an event",
+		Long: `Build an iTIP REQUEST for the event with the given UID, written as ICS.
+
+With --previous (the calendar holding the revision sent last) SEQUENCE is
+incremented when the event was rescheduled, and the attendees are asked to
+respond again. DTSTAMP is set to the current time.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagPrevious, _ := cmd.Flags().GetString("previous")
+
+			event := loadSchedulingEvent(cmd, args[0])
+			var previous *icaljson.Event
+			if flagPrevious != "" {
+				calendar, err := loadCalendar(cmd, flagPrevious)
+				if err != nil {
+					fmt.Printf("Error loading %s: %v\n", flagPrevious, err)
+					os.Exit(exitCode(err))
+				}
+				if e, ok := calendar.FindEvent(event.UID, event.RecurrenceID); ok {
+					previous = &e
+				}
+			}
+
+			msg, err := icaljson.BuildRequest(event, previous)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			writeSchedulingMessage(cmd, msg)
+		},
+	}
+	requestCmd.Flags().String("previous", "", "Calendar with the revision sent last, to decide whether SEQUENCE is incremented")
+	addSchedulingFlags(requestCmd)
+
+	var replyCmd = &cobra.Command{
+		Use:   "reply [store]",
+		Short: "Build a REPLY answering an invitation for one attendee",
+		Long: `Build an iTIP REPLY in which --attendee answers the event with the given
+UID with --partstat (ACCEPTED, DECLINED, TENTATIVE, DELEGATED or
+NEEDS-ACTION), written as ICS.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			flagAttendee, _ := cmd.Flags().GetString("attendee")
+			flagPartStat, _ := cmd.Flags().GetString("partstat")
+
+			event := loadSchedulingEvent(cmd, args[0])
+			msg, err := icaljson.BuildReply(event, flagAttendee, flagPartStat)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			writeSchedulingMessage(cmd, msg)
+		},
+	}
+	replyCmd.Flags().String("attendee", "", "Address of the replying attendee, e.g. mailto:ben@example.com")
+	replyCmd.Flags().String("partstat", icaljson.PartStatAccepted, "Participation status: ACCEPTED, DECLINED, TENTATIVE, DELEGATED or NEEDS-ACTION")
+	replyCmd.MarkFlagRequired("attendee")
+	addSchedulingFlags(replyCmd)
+
+	var cancelCmd = &cobra.Command{
+		Use:   "cancel [store]",
+		Short: "Build a CANCEL for an event or one of its occurrences",
+		Long: `Build an iTIP CANCEL for the event with the given UID, or for a single
+occurrence with --recurrence-id, written as ICS. SEQUENCE is incremented and
+all attendees are addressed.`,
+		Args: cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			event := loadSchedulingEvent(cmd, args[0])
+			msg, err := icaljson.BuildCancel(event)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+			writeSchedulingMessage(cmd, msg)
+		},
+	}
+	addSchedulingFlags(cancelCmd)
+
+	itipCmd.AddCommand(applyCmd, requestCmd, replyCmd, cancelCmd)
+	return itipCmd
+}
+
+// addSchedulingFlags registers the flags shared by the iTIP builder commands
+func addSchedulingFlags(cmd *cobra.Command) {
+	cmd.Flags().String("uid", "", "UID of the event")
+	cmd.Flags().String("recurrence-id", "", "RECURRENCE-ID of a single occurrence")
+	cmd.Flags().StringP("output", "o", "", "Output file for the ICS message (default: stdout)")
+	cmd.Flags().String("eml", "", "Also write the message as an iMIP e-mail (.eml) to this file")
+	cmd.Flags().String("from", "", "Sender of the e-mail (default: organizer or replying attendee)")
+	cmd.Flags().StringSlice("to", nil, "Recipients of the e-mail (default: attendees or organizer)")
+	cmd.MarkFlagRequired("uid")
+	addFetchFlags(cmd)
+	addParseFlags(cmd)
+}
+
+// addFilterFlags registers the event selection flags
+func addFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from", "", "Only events ending after this time (RFC 3339, YYYY-MM-DD, 'now' or 'today')")
+	cmd.Flags().String("to", "", "Only events starting before this time (RFC 3339, YYYY-MM-DD, 'now' or 'today')")
+	cmd.Flags().Bool("expand", false, "Expand recurring events into their individual occurrences (needs --to for events that recur forever)")
+	cmd.Flags().StringSlice("category", nil, "Only events with one of these categories")
+	cmd.Flags().StringSlice("status", nil, "Only events with one of these statuses (TENTATIVE, CONFIRMED, CANCELLED)")
+	cmd.Flags().StringSlice("class", nil, "Only events with one of these classes (PUBLIC, PRIVATE, CONFIDENTIAL)")
+	cmd.Flags().String("text", "", "Only events whose summary, description or location contains this text")
+	cmd.Flags().Bool("regex", false, "Interpret --text as a regular expression")
+	cmd.Flags().String("near", "", "Only events within a radius of a point, as lat,lon,radiusKm")
+}
+
+// invalidFlag reports an unusable flag value as an invalid argument.
+func invalidFlag(name string, err error) error {
+	return icaljson.AppError{Message: "invalid " + name, Value: err, Code: icaljson.CodeInvalidArgument}
+}
+
+// filterOptions builds FilterOptions from the flags registered by addFilterFlags
+func filterOptions(cmd *cobra.Command) (icaljson.FilterOptions, error) {
+	var opts icaljson.FilterOptions
+	fromValue, _ := cmd.Flags().GetString("from")
+	toValue, _ := cmd.Flags().GetString("to")
+	nearValue, _ := cmd.Flags().GetString("near")
+
+	var err error
+	if opts.From, err = icaljson.ParseTimeBound(fromValue, time.Local); err != nil {
+		return opts, invalidFlag("--from", err)
+	}
+	if opts.To, err = icaljson.ParseTimeBound(toValue, time.Local); err != nil {
+		return opts, invalidFlag("--to", err)
+	}
+	if nearValue != "" {
+		if opts.Near, err = icaljson.ParseGeoRadius(nearValue); err != nil {
+			return opts, invalidFlag("--near", err)
+		}
+	}
+
+	opts.Expand, _ = cmd.Flags().GetBool("expand")
+	opts.Categories, _ = cmd.Flags().GetStringSlice("category")
+	opts.Statuses, _ = cmd.Flags().GetStringSlice("status")
+	opts.Classes, _ = cmd.Flags().GetStringSlice("class")
+	opts.Text, _ = cmd.Flags().GetString("text")
+	opts.Regexp, _ = cmd.Flags().GetBool("regex")
+
+	return opts, nil
+}
+
+// addFetchFlags registers the flags controlling remote feed downloads
+func addFetchFlags(cmd *cobra.Command) {
+	cmd.Flags().String("cache-dir", icaljson.DefaultCacheDir(), "Directory for cached calendar feeds")
+	cmd.Flags().Bool("no-cache", false, "Always download feeds, ignoring and not updating the cache")
+	cmd.Flags().Duration("timeout", icaljson.DefaultFetchTimeout, "Timeout for downloading a feed")
+	cmd.Flags().Int64("max-size", icaljson.DefaultMaxBodySize, "Maximum feed size in bytes")
+	cmd.Flags().Int("max-redirects", icaljson.DefaultMaxRedirects, "Maximum number of redirects to follow")
+}
+
+// fetchOptions builds FetchOptions from the flags registered by addFetchFlags
+func fetchOptions(cmd *cobra.Command) icaljson.FetchOptions {
+	cacheDir, _ := cmd.Flags().GetString("cache-dir")
+	noCache, _ := cmd.Flags().GetBool("no-cache")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	maxSize, _ := cmd.Flags().GetInt64("max-size")
+	maxRedirects, _ := cmd.Flags().GetInt("max-redirects")
+
+	if envCacheDir := os.Getenv("ICALJSON_CACHE_DIR"); envCacheDir != "" && !cmd.Flags().Changed("cache-dir") {
+		cacheDir = envCacheDir
+	}
+	if noCache {
+		cacheDir = ""
+	}
+
+	return icaljson.FetchOptions{
+		CacheDir:     cacheDir,
+		Timeout:      timeout,
+		MaxBodySize:  maxSize,
+		MaxRedirects: maxRedirects,
+	}
+}
+
+// addParseFlags registers the flags read by parseOptions
+func addParseFlags(cmd *cobra.Command) {
+	cmd.Flags().String("input-encoding", "", "Character encoding of iCal input, e.g. windows-1252 or utf-16le (default: detect)")
+}
+
+// parseOptions builds ParseOptions from the flags registered by addParseFlags
+func parseOptions(cmd *cobra.Command) icaljson.ParseOptions {
+	encoding, _ := cmd.Flags().GetString("input-encoding")
+	return icaljson.ParseOptions{Encoding: encoding}
+}
+
+// addDescriptionFlags registers the flag read by applyDescriptionFormat
+func addDescriptionFlags(cmd *cobra.Command) {
+	cmd.Flags().String("description-format", "", "Convert descriptions to "+strings.Join(icaljson.DescriptionFormats, ", ")+" (default: keep DESCRIPTION and X-ALT-DESC as they are)")
+}
+
+// applyDescriptionFormat converts the calendar's descriptions as requested by --description-format
+func applyDescriptionFormat(cmd *cobra.Command, calendar *icaljson.Calendar) error {
+	format, _ := cmd.Flags().GetString("description-format")
+	if format == "" {
+		return nil
+	}
+	if !slices.Contains(icaljson.DescriptionFormats, format) {
+		return invalidFlag("--description-format", fmt.Errorf("%q is not one of %s", format, strings.Join(icaljson.DescriptionFormats, ", ")))
+	}
+	return icaljson.ApplyDescriptionFormat(calendar, format)
+}
@@ -4,6 +4,18 @@
 //
 // The command-line tool provides functionality to:
 //   - Generate JSON from iCal files with automatic type inference
+//   - Fetch published calendar feeds over http(s) and webcal with caching
+//   - Extract invitations from .eml e-mails and mbox mailboxes
+//   - Query, filter and project events with a small expression language
+//   - Merge calendars with UID-aware conflict resolution
+//   - Diff two versions of a calendar as text, JSON Patch or a change list
+//   - Validate iCal files against RFC 5545 with text, JSON or SARIF output
+//   - Show the RELATED-TO hierarchy of events and tasks as a tree
+//   - Apply and build iTIP scheduling messages (RFC 5546), optionally as iMIP e-mail
+//   - Publish free/busy time as VFREEBUSY or JSON
+//   - Find double-booked resources, attendees and locations
+//   - Propose meeting times at which several calendars are free
+//   - List upcoming alarms, or fire them through a command or webhook
 //   - Display version and build information
 //
 // # Command Reference
@@ -12,6 +24,58 @@
 //
 //	icaljson generate caledar.ics
 //
+// Generate json from an e-mailed invitation:
+//
+//	icaljson generate invite.eml
+//
+// Generate json from a published feed:
+//
+//	icaljson generate webcal://example.com/events.ics
+//
+// Query upcoming events:
+//
+//	icaljson query calendar.ics 'start >= today order by start' --select uid,summary,start
+//
+// Merge venue feeds into one calendar:
+//
+//	icaljson merge a.ics b.ics c.ics -o merged.json --detect-duplicates
+//
+// Show what changed in a feed:
+//
+//	icaljson diff old.json new.ics
+//
+// Lint a calendar in CI:
+//
+//	icaljson validate calendar.ics --strict --format sarif -o icaljson.sarif
+//
+// Show a project plan as a tree:
+//
+//	icaljson tree project.ics
+//
+// Apply meeting replies and cancellations:
+//
+//	icaljson itip apply calendar.json reply.ics cancel.ics
+//
+// Accept an invitation:
+//
+//	icaljson itip reply invite.ics --uid UID --attendee ben@example.com --eml reply.eml
+//
+// Publish next week's busy time:
+//
+//	icaljson freebusy calendar.ics --from 2025-10-06 --to 2025-10-13 -o busy.ifb
+//
+// Audit room bookings:
+//
+//	icaljson conflicts rooms.ics team.ics --by resources --from today
+//
+// Find a 45 minute meeting slot for two people:
+//
+//	icaljson slots ana.ics ben.ics --duration 45m --from today --to 2025-10-31 --tz Europe/Zurich
+//
+// Run a command for each reminder as it becomes due:
+//
+//	icaljson reminders calendar.ics --daemon --exec 'notify-send "$ICALJSON_REMINDER_SUMMARY"'
+//
 // Show version information:
 //
 //	icaljson version
@@ -25,10 +89,19 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/beyondcivic/icaljson/pkg/icaljson"
 	"github.com/beyondcivic/icaljson/pkg/version"
@@ -36,6 +109,19 @@ import (
 	"github.com/spf13/viper"
 )
 
+// Process exit codes.
+const (
+	exitError       = 1 // Unclassified error, or validation failures
+	exitUsage       = 2 // Invalid arguments, options or queries
+	exitNotModified = 3 // Feed not modified since the last run, output left as is
+	exitDifferences = 4 // Calendars differ, or conflicts were found
+	exitInvalidData = 5 // Input is not valid iCalendar or JSON
+	exitRead        = 6 // Input could not be opened or read
+	exitWrite       = 7 // Output could not be written
+	exitFetch       = 8 // Feed could not be downloaded
+	exitConflict    = 9 // Conflicting events in merge --strategy fail
+)
+
 // Root cobra command.
 // Call Init() once to initialize child commands.
 // Global so it can be picked up by docs/doc-gen.go.
@@ -57,18 +143,47 @@ func Init() {
 	// Add child commands
 	RootCmd.AddCommand(versionCmd())
 	RootCmd.AddCommand(generateCmd())
+	RootCmd.AddCommand(queryCmd())
+	RootCmd.AddCommand(mergeCmd())
+	RootCmd.AddCommand(diffCmd())
+	RootCmd.AddCommand(validateCmd())
+	RootCmd.AddCommand(treeCmd())
+	RootCmd.AddCommand(itipCmd())
+	RootCmd.AddCommand(freebusyCmd())
+	RootCmd.AddCommand(conflictsCmd())
+	RootCmd.AddCommand(slotsCmd())
+	RootCmd.AddCommand(remindersCmd())
 }
 
 func Execute() {
 	// Execute the command
 	if err := RootCmd.Execute(); err != nil {
 		fmt.Println(err)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 }
 
 // Helper functions
 
+// exitCode maps an error to the process exit code of its kind.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, icaljson.ErrInvalidArgument):
+		return exitUsage
+	case errors.Is(err, icaljson.ErrParse):
+		return exitInvalidData
+	case errors.Is(err, icaljson.ErrRead):
+		return exitRead
+	case errors.Is(err, icaljson.ErrWrite):
+		return exitWrite
+	case errors.Is(err, icaljson.ErrFetch):
+		return exitFetch
+	case errors.Is(err, icaljson.ErrConflict):
+		return exitConflict
+	}
+	return exitError
+}
+
 func fileExists(filename string) bool {
 	info, err := os.Stat(filename)
 	if os.IsNotExist(err) {
@@ -77,11 +192,293 @@ func fileExists(filename string) bool {
 	return !info.IsDir()
 }
 
+// loadCalendar parses a calendar from an ICS file, a JSON file written by
+// generate or, for URLs, a feed download using the fetch and parse flags
+// registered on cmd (if any).
+func loadCalendar(cmd *cobra.Command, input string) (*icaljson.Calendar, error) {
+	var calendar *icaljson.Calendar
+	var err error
+	if icaljson.IsURL(input) {
+		result, fetchErr := icaljson.Fetch(cmd.Context(), input, fetchOptions(cmd))
+		if fetchErr != nil {
+			return nil, fetchErr
+		}
+		calendar, err = icaljson.ParseWithOptions(bytes.NewReader(result.Body), parseOptions(cmd))
+	} else {
+		if !fileExists(input) {
+			return nil, icaljson.AppError{Message: fmt.Sprintf("ICS file '%s' does not exist", input), Code: icaljson.CodeReadFailed}
+		}
+		if strings.EqualFold(filepath.Ext(input), ".json") {
+			calendar, err = icaljson.ReadJSON(input)
+		} else if icaljson.IsMailFile(input) {
+			calendars, mailErr := loadCalendars(cmd, input)
+			if mailErr != nil {
+				return nil, mailErr
+			}
+			if len(calendars) > 1 {
+				return nil, icaljson.AppError{Message: fmt.Sprintf("'%s' contains %d calendars, convert them with generate first", input, len(calendars)), Code: icaljson.CodeInvalidArgument}
+			}
+			calendar = calendars[0]
+		} else {
+			calendar, err = icaljson.ParseFileWithOptions(input, parseOptions(cmd))
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	calendar.Source = input
+	return calendar, nil
+}
+
+// loadSchedulingEvent loads the store and returns the event or occurrence
+// selected by the --uid and --recurrence-id flags.
+func loadSchedulingEvent(cmd *cobra.Command, input string) icaljson.Event {
+	flagUID, _ := cmd.Flags().GetString("uid")
+	flagRecurrenceID, _ := cmd.Flags().GetString("recurrence-id")
+
+	calendar, err := loadCalendar(cmd, input)
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", input, err)
+		os.Exit(exitCode(err))
+	}
+	event, ok, err := calendar.FindOccurrence(flagUID, flagRecurrenceID)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if !ok && calendar.HasTodo(flagUID) {
+		fmt.Printf("Error: '%s' in %s is a task; scheduling messages can only be built for events\n", flagUID, input)
+		os.Exit(exitUsage)
+	}
+	if !ok {
+		fmt.Printf("Error: no event with UID '%s' in %s\n", flagUID, input)
+		os.Exit(exitUsage)
+	}
+	return event
+}
+
+// writeSchedulingMessage writes an iTIP message as ICS to --output and, with
+// --eml, as an iMIP e-mail.
+func writeSchedulingMessage(cmd *cobra.Command, msg *icaljson.Calendar) {
+	flagOutputPath, _ := cmd.Flags().GetString("output")
+	flagEML, _ := cmd.Flags().GetString("eml")
+	flagFrom, _ := cmd.Flags().GetString("from")
+	flagTo, _ := cmd.Flags().GetStringSlice("to")
+
+	out, err := openOutput(flagOutputPath)
+	if err == nil {
+		err = icaljson.WriteICS(out, msg)
+		out.Close()
+	}
+	if err != nil {
+		fmt.Printf("Error writing %s message: %v\n", msg.Method, err)
+		os.Exit(exitCode(err))
+	}
+
+	if flagEML != "" {
+		opts := icaljson.IMIPOptions{From: flagFrom, To: flagTo}
+		if err := icaljson.WriteIMIPFile(msg, flagEML, opts); err != nil {
+			fmt.Printf("Error writing iMIP message: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		if flagOutputPath != "" {
+			fmt.Printf("✓ %s message saved to: %s and %s\n", msg.Method, flagOutputPath, flagEML)
+		}
+	} else if flagOutputPath != "" {
+		fmt.Printf("✓ %s message saved to: %s\n", msg.Method, flagOutputPath)
+	}
+}
+
+// loadCalendars parses every calendar of an e-mail or mailbox, or the single
+// calendar of any other input accepted by loadCalendar.
+func loadCalendars(cmd *cobra.Command, input string) ([]*icaljson.Calendar, error) {
+	if !icaljson.IsMailFile(input) || icaljson.IsURL(input) {
+		calendar, err := loadCalendar(cmd, input)
+		if err != nil {
+			return nil, err
+		}
+		return []*icaljson.Calendar{calendar}, nil
+	}
+	if !fileExists(input) {
+		return nil, icaljson.AppError{Message: fmt.Sprintf("e-mail file '%s' does not exist", input), Code: icaljson.CodeReadFailed}
+	}
+	calendars, err := icaljson.ParseMailFile(input, parseOptions(cmd))
+	if err != nil {
+		return nil, err
+	}
+	for _, calendar := range calendars {
+		calendar.Source = input
+	}
+	return calendars, nil
+}
+
+// numberedOutputPath returns outputPath for a single output, and inserts
+// "-1", "-2", ... before the extension when there are several.
+func numberedOutputPath(outputPath string, i, n int) string {
+	if n <= 1 {
+		return outputPath
+	}
+	ext := filepath.Ext(outputPath)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(outputPath, ext), i+1, ext)
+}
+
+// hasInfiniteEvent reports whether an event in one of the calendars recurs
+// forever, so that expanding it needs an end of the window.
+func hasInfiniteEvent(calendars []*icaljson.Calendar) bool {
+	for _, calendar := range calendars {
+		for _, e := range calendar.Events {
+			if e.RecurrenceID == "" && e.IsInfinite() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// slotOptions builds the time window and the per-calendar working hours and
+// buffers of the slots command. Values of the form INPUT=VALUE apply to the
+// calendar read from INPUT, others to all calendars.
+func slotOptions(tz, from, to string, hours, buffers, inputs []string) (icaljson.SlotOptions, []icaljson.SlotCalendar, error) {
+	var opts icaljson.SlotOptions
+	calendars := make([]icaljson.SlotCalendar, len(inputs))
+
+	loc := time.Local
+	if tz != "" {
+		var err error
+		if loc, err = time.LoadLocation(tz); err != nil {
+			return opts, nil, invalidFlag("--tz", err)
+		}
+	}
+	opts.Location = loc
+
+	var err error
+	if opts.From, err = icaljson.ParseTimeBound(from, loc); err != nil {
+		return opts, nil, invalidFlag("--from", err)
+	}
+	if opts.To, err = icaljson.ParseTimeBound(to, loc); err != nil {
+		return opts, nil, invalidFlag("--to", err)
+	}
+
+	// target returns the calendar a value applies to, or -1 for all
+	target := func(value string) (int, string, error) {
+		input, rest, ok := strings.Cut(value, "=")
+		if !ok {
+			return -1, value, nil
+		}
+		if i := slices.Index(inputs, input); i >= 0 {
+			return i, rest, nil
+		}
+		return 0, "", icaljson.AppError{Message: "no such input", Value: input, Code: icaljson.CodeInvalidArgument}
+	}
+	for _, value := range hours {
+		i, value, err := target(value)
+		if err != nil {
+			return opts, nil, invalidFlag("--working-hours", err)
+		}
+		wh, err := icaljson.ParseWorkingHours(value)
+		if err != nil {
+			return opts, nil, invalidFlag("--working-hours", err)
+		}
+		if i < 0 {
+			opts.WorkingHours = wh
+		} else {
+			calendars[i].WorkingHours = &wh
+		}
+	}
+	for _, value := range buffers {
+		i, value, err := target(value)
+		if err != nil {
+			return opts, nil, invalidFlag("--buffer", err)
+		}
+		buffer, err := time.ParseDuration(value)
+		if err != nil || buffer < 0 {
+			return opts, nil, invalidFlag("--buffer", fmt.Errorf("invalid duration %q", value))
+		}
+		if i < 0 {
+			opts.Buffer = buffer
+		} else {
+			calendars[i].Buffer = buffer
+		}
+	}
+	return opts, calendars, nil
+}
+
+// reminderScheduler builds the scheduler of the reminders command from its
+// hook, state and timing flags. Calendars are reloaded from inputs.
+func reminderScheduler(cmd *cobra.Command, inputs []string, loc *time.Location) (*icaljson.ReminderScheduler, error) {
+	flagExec, _ := cmd.Flags().GetString("exec")
+	flagWebhook, _ := cmd.Flags().GetString("webhook")
+	flagState, _ := cmd.Flags().GetString("state")
+	flagInterval, _ := cmd.Flags().GetDuration("interval")
+	flagGrace, _ := cmd.Flags().GetDuration("grace")
+
+	state, err := icaljson.LoadReminderState(flagState)
+	if err != nil {
+		return nil, err
+	}
+
+	hook := icaljson.JSONHook(os.Stdout)
+	switch {
+	case flagExec != "":
+		hook = icaljson.CommandHook(flagExec, os.Stdout, os.Stderr)
+	case flagWebhook != "":
+		if u, err := url.Parse(flagWebhook); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, invalidFlag("--webhook", fmt.Errorf("%q is not an http(s) URL", flagWebhook))
+		}
+		hook = icaljson.WebhookHook(flagWebhook, nil)
+	}
+
+	return &icaljson.ReminderScheduler{
+		Load: func(ctx context.Context) ([]*icaljson.Calendar, error) {
+			calendars := make([]*icaljson.Calendar, len(inputs))
+			for i, input := range inputs {
+				calendar, err := loadCalendar(cmd, input)
+				if err != nil {
+					return nil, fmt.Errorf("loading %s: %w", input, err)
+				}
+				calendars[i] = calendar
+			}
+			return calendars, nil
+		},
+		Hook:     hook,
+		State:    state,
+		Interval: flagInterval,
+		Grace:    flagGrace,
+		Location: loc,
+		OnError: func(err error) {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		},
+	}, nil
+}
+
+// openOutput returns stdout for an empty path, or creates the file.
+func openOutput(outputPath string) (io.WriteCloser, error) {
+	if outputPath == "" || outputPath == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	if err := icaljson.ValidateOutputPath(outputPath); err != nil {
+		return nil, err
+	}
+	return os.Create(outputPath)
+}
+
+// writeIndentedJSON writes v as indented JSON followed by a newline.
+func writeIndentedJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
 func isICalFile(filename string) bool {
 	return icaljson.IsICalFile(filename)
 }
 
-func determineOutputPath(providedPath, csvPath string) string {
+func determineOutputPath(providedPath, icsPath string) string {
 	if providedPath != "" {
 		return providedPath
 	}
@@ -92,7 +489,18 @@ func determineOutputPath(providedPath, csvPath string) string {
 		return envOutputPath
 	}
 
-	// Generate default path based on CSV filename
-	baseName := strings.TrimSuffix(filepath.Base(csvPath), filepath.Ext(csvPath))
+	// Generate default path based on ICS filename, or the last URL path segment
+	name := icsPath
+	if icaljson.IsURL(icsPath) {
+		name = "calendar"
+		if u, err := url.Parse(icsPath); err == nil {
+			if base := path.Base(u.Path); base != "/" && base != "." {
+				name = base
+			} else {
+				name = u.Hostname()
+			}
+		}
+	}
+	baseName := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
 	return baseName + "_parsed.json"
 }
@@ -0,0 +1,206 @@
+package icaljson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// filenameParams hold the file name of an attachment, in order of preference.
+// FILENAME is defined by RFC 8607; the others are written by Apple, Oracle and
+// Microsoft clients.
+var filenameParams = []string{"FILENAME", "X-FILENAME", "X-APPLE-FILENAME", "X-ORACLE-FILENAME", "X-MS-FILENAME"}
+
+// IsInline reports whether the attachment carries its content as base64 data.
+func (a Attachment) IsInline() bool {
+	return a.Data != ""
+}
+
+// Decode returns the content of an inline attachment. The base64 data is only
+// decoded on demand, so parsing and converting calendars with large
+// attachments stays cheap.
+func (a Attachment) Decode() ([]byte, error) {
+	if !a.IsInline() {
+		return nil, AppError{Message: "attachment has no inline data", Value: a.URI, Code: CodeInvalidArgument}
+	}
+	data := strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\r' || r == '\n' {
+			return -1
+		}
+		return r
+	}, a.Data)
+	decoded, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		// Some writers drop the padding
+		var rawErr error
+		if decoded, rawErr = base64.RawStdEncoding.DecodeString(strings.TrimRight(data, "=")); rawErr != nil {
+			return nil, AppError{Message: "invalid base64 attachment data", Value: err, Code: CodeInvalidPropertyValue}
+		}
+	}
+	return decoded, nil
+}
+
+// attachmentSize returns the SIZE parameter or, for inline data, the decoded
+// length computed from the base64 text without decoding it.
+func attachmentSize(params map[string]string, data string) int64 {
+	if size, err := strconv.ParseInt(params["SIZE"], 10, 64); err == nil && size >= 0 {
+		return size
+	}
+	if data == "" {
+		return 0
+	}
+	n := 0
+	for _, c := range data {
+		switch c {
+		case ' ', '\t', '\r', '\n', '=':
+		default:
+			n++
+		}
+	}
+	return int64(n * 6 / 8)
+}
+
+// attachmentFilename returns the file name given by the attachment's parameters.
+func attachmentFilename(params map[string]string) string {
+	for _, name := range filenameParams {
+		if filename := params[name]; filename != "" {
+			return filename
+		}
+	}
+	return ""
+}
+
+// ExtractAttachments writes the inline attachments and images of the calendar,
+// its events and tasks to dir and replaces their data with a URI relative to
+// base, the directory the JSON output is written to. File names come from the
+// attachment's FILENAME parameter or are derived from its media type; names
+// that occur more than once or already exist in dir get a numeric suffix, so
+// existing files are never overwritten; an existing file with the same content
+// is referenced instead, so that converting a calendar again reuses its files.
+// It returns the paths of the attachment files.
+func ExtractAttachments(calendar *Calendar, dir, base string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, AppError{Message: "failed to create attachment directory", Value: err, Code: CodeWriteFailed}
+	}
+
+	var inline []*Attachment
+	collect := func(attachments []Attachment, images []Image) {
+		for i := range attachments {
+			if attachments[i].IsInline() {
+				inline = append(inline, &attachments[i])
+			}
+		}
+		for i := range images {
+			if images[i].IsInline() {
+				inline = append(inline, &images[i].Attachment)
+			}
+		}
+	}
+	collect(nil, calendar.Images)
+	for i := range calendar.Events {
+		collect(calendar.Events[i].Attachments, calendar.Events[i].Images)
+	}
+	for i := range calendar.Todos {
+		collect(calendar.Todos[i].Attachments, calendar.Todos[i].Images)
+	}
+
+	var written []string
+	used := map[string]bool{}
+	for i, attachment := range inline {
+		data, err := attachment.Decode()
+		if err != nil {
+			return written, err
+		}
+
+		path, err := writeNewFile(dir, attachmentBaseName(*attachment, i+1), data, used)
+		if err != nil {
+			return written, err
+		}
+		written = append(written, path)
+
+		uri, err := filepath.Rel(base, path)
+		if err != nil {
+			uri = path
+		}
+		attachment.URI = filepath.ToSlash(uri)
+		attachment.Data = ""
+		attachment.Size = int64(len(data))
+	}
+	return written, nil
+}
+
+// writeNewFile writes data to a file in dir named after name, made unique
+// against used and the files that already exist, and returns its path. An
+// existing file holding data is returned without writing.
+func writeNewFile(dir, name string, data []byte, used map[string]bool) (string, error) {
+	for {
+		path := filepath.Join(dir, uniqueFilename(name, used))
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, fs.ErrExist) {
+			if existing, err := os.ReadFile(path); err == nil && bytes.Equal(existing, data) {
+				return path, nil
+			}
+			continue
+		}
+		if err != nil {
+			return "", AppError{Message: "failed to write attachment", Value: err, Code: CodeWriteFailed}
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return "", AppError{Message: "failed to write attachment", Value: err, Code: CodeWriteFailed}
+		}
+		return path, nil
+	}
+}
+
+// attachmentBaseName returns a safe file name for the n-th inline attachment.
+func attachmentBaseName(a Attachment, n int) string {
+	name := filepath.Base(strings.ReplaceAll(a.Filename, `\`, "/"))
+	if name == "." || name == "/" || name == ".." {
+		name = ""
+	}
+	if name == "" {
+		name = fmt.Sprintf("attachment-%d", n) + attachmentExtension(a.FmtType)
+	}
+	return name
+}
+
+// attachmentExtension returns the usual file extension for a media type.
+func attachmentExtension(fmttype string) string {
+	mediaType, _, err := mime.ParseMediaType(fmttype)
+	if err != nil {
+		return ""
+	}
+	switch mediaType {
+	case "image/jpeg":
+		return ".jpg"
+	case "text/plain":
+		return ".txt"
+	}
+	if exts, _ := mime.ExtensionsByType(mediaType); len(exts) > 0 {
+		return exts[0]
+	}
+	return ""
+}
+
+// uniqueFilename appends "-2", "-3", ... to names already in used.
+func uniqueFilename(name string, used map[string]bool) string {
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	candidate := name
+	for i := 2; used[strings.ToLower(candidate)]; i++ {
+		candidate = fmt.Sprintf("%s-%d%s", stem, i, ext)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
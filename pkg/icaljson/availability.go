@@ -0,0 +1,113 @@
+package icaljson
+
+import (
+	"cmp"
+	"slices"
+	"time"
+)
+
+// IsAvailable reports whether the calendar's VAVAILABILITY components make
+// the calendar user available at t, see AvailableWindows. Events are not
+// taken into account; Calendar.FreeBusy combines both.
+func (c *Calendar) IsAvailable(t time.Time) bool {
+	for _, p := range c.availabilityPeriods(t, t.Add(time.Second)) {
+		if !t.Before(p.Start) && t.Before(p.End) {
+			return p.Type == FBTypeFree
+		}
+	}
+	return true
+}
+
+// AvailableWindows returns the periods within [from, to) in which the
+// calendar user is available according to the VAVAILABILITY components,
+// following the priority layering of RFC 7953 §4: each component makes its
+// time range busy with its BUSYTYPE except for its AVAILABLE blocks, and
+// overrides the components of lower PRIORITY (1 highest, 9 lowest, 0
+// undefined and lower than 9; later components win ties). Time covered by
+// no component is available. Floating times are taken in the time zone of from.
+func (c *Calendar) AvailableWindows(from, to time.Time) []BusyPeriod {
+	if !from.Before(to) {
+		return nil
+	}
+	window := []BusyPeriod{{Start: from.UTC(), End: to.UTC(), Type: FBTypeFree}}
+	var unavailable []BusyPeriod
+	for _, p := range c.availabilityPeriods(from, to) {
+		if p.Type != FBTypeFree {
+			unavailable = append(unavailable, p)
+		}
+	}
+	return subtractPeriods(window, mergePeriods(unavailable))
+}
+
+// availabilityPeriods layers the VAVAILABILITY components within [from, to)
+// into sorted, non-overlapping periods, which are FREE or of a BUSYTYPE.
+// Time not covered by any component is left out.
+func (c *Calendar) availabilityPeriods(from, to time.Time) []BusyPeriod {
+	rank := func(a Availability) int {
+		if a.Priority == 0 {
+			return 10
+		}
+		return a.Priority
+	}
+	// Lowest priority first, so that higher priorities are layered on top
+	ordered := slices.Clone(c.Availability)
+	slices.SortStableFunc(ordered, func(a, b Availability) int {
+		return cmp.Compare(rank(b), rank(a))
+	})
+
+	var layers []BusyPeriod
+	for _, a := range ordered {
+		covered, ok := availabilityRange(a, from, to)
+		if !ok {
+			continue
+		}
+
+		var available []BusyPeriod
+		instances, _ := ExpandEvents(a.Available, covered.Start.Add(-24*time.Hour), covered.End.Add(24*time.Hour))
+		for _, e := range instances {
+			start, end, err := eventInterval(e, from.Location())
+			if err != nil {
+				continue
+			}
+			start, end = maxTime(start, covered.Start).UTC(), minTime(end, covered.End).UTC()
+			if start.Before(end) {
+				available = append(available, BusyPeriod{Start: start, End: end, Type: FBTypeFree})
+			}
+		}
+		available = mergePeriods(available)
+
+		layers = subtractPeriods(layers, []BusyPeriod{covered})
+		layers = append(layers, available...)
+		layers = append(layers, subtractPeriods([]BusyPeriod{covered}, available)...)
+		slices.SortFunc(layers, func(a, b BusyPeriod) int {
+			return a.Start.Compare(b.Start)
+		})
+	}
+	return layers
+}
+
+// availabilityRange returns the time range of a VAVAILABILITY within
+// [from, to), typed with its BUSYTYPE, and whether the two overlap.
+func availabilityRange(a Availability, from, to time.Time) (BusyPeriod, bool) {
+	covered := BusyPeriod{Start: from.UTC(), End: to.UTC(), Type: cmp.Or(a.BusyType, FBTypeBusyUnavailable)}
+	if a.Start != "" {
+		start, end, err := eventInterval(a.Event, from.Location())
+		if err != nil {
+			return covered, false
+		}
+		covered.Start = maxTime(start, from).UTC()
+		if a.End != "" || a.Duration != "" {
+			covered.End = minTime(end, to).UTC()
+		}
+	} else if a.End != "" {
+		end, _, err := ParseDateTime(a.End, a.TimeZone())
+		if err != nil {
+			return covered, false
+		}
+		if _, err := time.Parse(time.RFC3339, a.End); err != nil && a.TZID == "" {
+			end = inLocation(end, from.Location())
+		}
+		covered.End = minTime(end, to).UTC()
+	}
+	return covered, covered.Start.Before(covered.End)
+}
@@ -0,0 +1,230 @@
+package icaljson
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+// What conflicting events must share to be reported by FindConflicts.
+const (
+	ConflictByResources = "resources"
+	ConflictByAttendees = "attendees"
+	ConflictByLocation  = "location"
+)
+
+// ConflictKeys lists the values accepted in ConflictOptions.By.
+var ConflictKeys = []string{ConflictByResources, ConflictByAttendees, ConflictByLocation}
+
+// ConflictOptions configures FindConflicts.
+type ConflictOptions struct {
+	// By lists what overlapping events must share, see ConflictKeys
+	// (default: resources).
+	By []string
+	// From and To limit the search to events overlapping [From, To). A zero
+	// bound leaves that side open; To is required when an event recurs
+	// forever.
+	From, To time.Time
+}
+
+// Conflict is a pair of overlapping events that share a resource, an
+// attendee or a location.
+type Conflict struct {
+	By     string    `json:"by"`     // resources, attendees or location
+	Shared []string  `json:"shared"` // The shared resources, attendee addresses or locations
+	Start  time.Time `json:"start"`  // Start of the overlap
+	End    time.Time `json:"end"`    // End of the overlap
+	First  EventRef  `json:"first"`
+	Second EventRef  `json:"second"`
+}
+
+// conflictInstance is an expanded event with its interval and the values it
+// may conflict on, keyed by normalised value.
+type conflictInstance struct {
+	event      Event
+	start, end time.Time
+	keys       map[string]map[string]string
+}
+
+// FindConflicts expands the events of the calendars within the window and
+// returns every pair of overlapping events that share a resource (RESOURCES,
+// VRESOURCE names or attendees with CUTYPE=ROOM or RESOURCE), an attendee
+// address (attendees who declined are not counted) or a normalised location,
+// depending on opts.By. Transparent and cancelled events do not block their
+// resources. An event found in several calendars (same UID and start) is
+// considered once, and occurrences of the same UID never conflict with each
+// other. Events record their calendar in EventRef.Source. Floating
+// date-times are taken in the time zone of opts.From, or UTC. An error is
+// returned when opts.To is zero and an event recurs without COUNT or UNTIL.
+func FindConflicts(opts ConflictOptions, calendars ...*Calendar) ([]Conflict, error) {
+	by := opts.By
+	if len(by) == 0 {
+		by = []string{ConflictByResources}
+	}
+	for _, key := range by {
+		if !slices.Contains(ConflictKeys, key) {
+			return nil, AppError{Message: "invalid conflict key, expected one of " + strings.Join(ConflictKeys, ", "), Value: key, Code: CodeInvalidArgument}
+		}
+	}
+	loc := time.UTC
+	if !opts.From.IsZero() {
+		loc = opts.From.Location()
+	}
+
+	var instances []conflictInstance
+	seen := map[string]bool{}
+	for i, calendar := range calendars {
+		source := calendar.Source
+		if source == "" {
+			source = fmt.Sprintf("calendar-%d", i+1)
+		}
+		if err := requireWindowEnd(calendar.Events, opts.To); err != nil {
+			return nil, err
+		}
+		expanded, err := ExpandEvents(calendar.Events, opts.From, opts.To)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range expanded {
+			if strings.EqualFold(e.Transp, "TRANSPARENT") || strings.EqualFold(e.Status, "CANCELLED") {
+				continue
+			}
+			start, end, err := eventInterval(e, loc)
+			if err != nil || !start.Before(end) {
+				continue
+			}
+			if e.UID != "" {
+				id := e.UID + "\x00" + start.UTC().Format(time.RFC3339)
+				if seen[id] {
+					continue
+				}
+				seen[id] = true
+			}
+			if e.Source == "" {
+				e.Source = source
+			}
+			instances = append(instances, conflictInstance{event: e, start: start, end: end, keys: conflictKeys(e, by)})
+		}
+	}
+	slices.SortStableFunc(instances, func(a, b conflictInstance) int {
+		return a.start.Compare(b.start)
+	})
+
+	conflicts := []Conflict{}
+	for i, a := range instances {
+		for _, b := range instances[i+1:] {
+			if !b.start.Before(a.end) {
+				break
+			}
+			if a.event.UID != "" && a.event.UID == b.event.UID {
+				continue
+			}
+			for _, key := range by {
+				var shared []string
+				for value, display := range a.keys[key] {
+					if _, ok := b.keys[key][value]; ok && !slices.Contains(shared, display) {
+						shared = append(shared, display)
+					}
+				}
+				if len(shared) == 0 {
+					continue
+				}
+				slices.Sort(shared)
+				conflicts = append(conflicts, Conflict{
+					By:     key,
+					Shared: shared,
+					Start:  b.start.UTC(),
+					End:    minTime(a.end, b.end).UTC(),
+					First:  eventRef(a.event),
+					Second: eventRef(b.event),
+				})
+			}
+		}
+	}
+	slices.SortStableFunc(conflicts, func(a, b Conflict) int {
+		return a.Start.Compare(b.Start)
+	})
+	return conflicts, nil
+}
+
+// conflictKeys collects the values an event may conflict on, by key.
+func conflictKeys(e Event, by []string) map[string]map[string]string {
+	keys := map[string]map[string]string{}
+	add := func(key, value, display string) {
+		if value == "" {
+			return
+		}
+		if keys[key] == nil {
+			keys[key] = map[string]string{}
+		}
+		if _, ok := keys[key][value]; !ok {
+			keys[key][value] = display
+		}
+	}
+
+	for _, key := range by {
+		switch key {
+		case ConflictByResources:
+			for _, resource := range e.Resources {
+				add(key, NormalizeText(resource.Value), resource.Value)
+			}
+			for _, resource := range e.VResources {
+				add(key, NormalizeText(resource.Name), resource.Name)
+			}
+			for _, attendee := range e.Attendees {
+				switch strings.ToUpper(attendee.Param("CUTYPE")) {
+				case "ROOM", "RESOURCE":
+					if !strings.EqualFold(attendee.Param("PARTSTAT"), PartStatDeclined) {
+						address := calAddress(attendee.Value)
+						display := cmp.Or(attendee.Param("CN"), address)
+						add(key, strings.ToLower(address), display)
+						add(key, NormalizeText(attendee.Param("CN")), display)
+					}
+				}
+			}
+		case ConflictByAttendees:
+			for _, attendee := range e.Attendees {
+				if !strings.EqualFold(attendee.Param("PARTSTAT"), PartStatDeclined) {
+					address := calAddress(attendee.Value)
+					add(key, strings.ToLower(address), address)
+				}
+			}
+		case ConflictByLocation:
+			add(key, NormalizeText(e.Location), e.Location)
+			for _, location := range e.VLocations {
+				add(key, NormalizeText(location.Name), location.Name)
+			}
+		}
+	}
+	return keys
+}
+
+// WriteConflictsText writes conflicts as a human-readable list.
+func WriteConflictsText(w io.Writer, conflicts []Conflict) error {
+	if len(conflicts) == 0 {
+		_, err := fmt.Fprintln(w, "No conflicts.")
+		return err
+	}
+	label := func(ref EventRef) string {
+		s := ref.UID
+		if ref.RecurrenceID != "" {
+			s += " @ " + ref.RecurrenceID
+		}
+		if ref.Summary != "" {
+			s += fmt.Sprintf(" (%s)", ref.Summary)
+		}
+		return s + " [" + ref.Source + "]"
+	}
+	for _, c := range conflicts {
+		if _, err := fmt.Fprintf(w, "! %s - %s  %s: %s\n    %s\n    %s\n",
+			c.Start.Format(time.RFC3339), c.End.Format(time.RFC3339), c.By, strings.Join(c.Shared, ", "),
+			label(c.First), label(c.Second)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%d conflict(s)\n", len(conflicts))
+	return err
+}
@@ -0,0 +1,148 @@
+package icaljson
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// ContentLine is an unfolded "NAME;PARAM=VALUE:value" line (RFC 5545 §3.1).
+type ContentLine struct {
+	Name   string              // Property name, upper-cased
+	Params map[string][]string // Parameter values by upper-cased name, unquoted
+	Value  string              // Raw property value
+	Line   int                 // 1-based number of the first physical line
+
+	valueColumn int // 1-based offset of the value within the unfolded line
+}
+
+// Param returns the first value of a parameter, or "".
+func (c ContentLine) Param(name string) string {
+	if values := c.Params[strings.ToUpper(name)]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// numberedLine is an unfolded line with the physical line it started on.
+type numberedLine struct {
+	Text string
+	Line int
+}
+
+// splitPhysicalLines splits data on CRLF, LF or a bare CR, reporting whether
+// any line was not terminated by CRLF. The final line may lack a terminator.
+func splitPhysicalLines(data []byte) (lines []string, nonCRLF bool) {
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "\r\n")
+		if i < 0 {
+			lines = append(lines, string(data))
+			break
+		}
+		lines = append(lines, string(data[:i]))
+		if data[i] == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			data = data[i+2:]
+			continue
+		}
+		nonCRLF = true
+		data = data[i+1:]
+	}
+	return lines, nonCRLF
+}
+
+// unfoldNumbered joins folded lines, keeping the number of each logical
+// line's first physical line. Empty lines are dropped.
+func unfoldNumbered(lines []string) []numberedLine {
+	var result []numberedLine
+	for i, line := range lines {
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(result) > 0 {
+			result[len(result)-1].Text += line[1:]
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		result = append(result, numberedLine{Text: line, Line: i + 1})
+	}
+	return result
+}
+
+// ContentLineError reports a syntax error within a content line.
+type ContentLineError struct {
+	Column  int // 1-based byte offset of the error
+	Message string
+}
+
+func (e *ContentLineError) Error() string {
+	return fmt.Sprintf("column %d: %s", e.Column, e.Message)
+}
+
+// ParseContentLine splits an unfolded content line into name, parameters and
+// value. Parameter values may be quoted and may contain ':' and ';' when quoted.
+func ParseContentLine(line string) (ContentLine, error) {
+	result := ContentLine{Params: map[string][]string{}}
+
+	i := 0
+	for i < len(line) && isNameChar(line[i]) {
+		i++
+	}
+	if i == 0 {
+		return result, &ContentLineError{Column: 1, Message: "missing property name"}
+	}
+	result.Name = strings.ToUpper(line[:i])
+
+	for i < len(line) && line[i] == ';' {
+		i++
+		start := i
+		for i < len(line) && isNameChar(line[i]) {
+			i++
+		}
+		if i == start {
+			return result, &ContentLineError{Column: i + 1, Message: "missing parameter name"}
+		}
+		paramName := strings.ToUpper(line[start:i])
+		if i >= len(line) || line[i] != '=' {
+			return result, &ContentLineError{Column: i + 1, Message: fmt.Sprintf("missing '=' after parameter %s", paramName)}
+		}
+		i++
+
+		for {
+			var value string
+			if i < len(line) && line[i] == '"' {
+				end := strings.IndexByte(line[i+1:], '"')
+				if end < 0 {
+					return result, &ContentLineError{Column: i + 1, Message: "unterminated quoted parameter value"}
+				}
+				value = line[i+1 : i+1+end]
+				i += end + 2
+			} else {
+				start := i
+				for i < len(line) && line[i] != ',' && line[i] != ';' && line[i] != ':' {
+					if line[i] == '"' {
+						return result, &ContentLineError{Column: i + 1, Message: "unexpected '\"' in parameter value"}
+					}
+					i++
+				}
+				value = line[start:i]
+			}
+			result.Params[paramName] = append(result.Params[paramName], value)
+			if i < len(line) && line[i] == ',' {
+				i++
+				continue
+			}
+			break
+		}
+	}
+
+	if i >= len(line) || line[i] != ':' {
+		return result, &ContentLineError{Column: i + 1, Message: "missing ':' before property value"}
+	}
+	result.Value = line[i+1:]
+	result.valueColumn = i + 2
+
+	return result, nil
+}
+
+func isNameChar(c byte) bool {
+	return c == '-' || (c >= '0' && c <= '9') || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
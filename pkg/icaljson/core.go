@@ -1,11 +1,13 @@
 package icaljson
 
 import (
-	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -13,228 +15,838 @@ import (
 
 // Generate generates JSON file from a ICS file with automatic type inference.
 func Generate(icsPath string, outputPath string) (*Calendar, error) {
+	calendar, err := ParseFile(icsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Write to file if output path is provided
+	if outputPath != "" {
+		if err := WriteJSON(calendar, outputPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return calendar, nil
+}
+
+// ParseFile reads and parses the ICS file at icsPath in lenient mode.
+func ParseFile(icsPath string) (*Calendar, error) {
+	return ParseFileWithOptions(icsPath, ParseOptions{})
+}
+
+// ParseFileWithOptions reads and parses the ICS file at icsPath.
+func ParseFileWithOptions(icsPath string, opts ParseOptions) (*Calendar, error) {
 	// Get file information
 	_, err := os.Stat(icsPath)
 	if err != nil {
-		return nil, AppError{Message: "failed to get file info", Value: err}
+		return nil, AppError{Message: "failed to get file info", Value: err, Code: CodeReadFailed}
 	}
 
 	// Read and parse ICS file
 	file, err := os.Open(icsPath)
 	if err != nil {
-		return nil, AppError{Message: "failed to open ICS file", Value: err}
+		return nil, AppError{Message: "failed to open ICS file", Value: err, Code: CodeReadFailed}
 	}
 	defer file.Close()
 
-	calendar, err := parseICS(file)
+	return ParseWithOptions(file, opts)
+}
+
+// ParseOptions configures ParseWithOptions.
+type ParseOptions struct {
+	// Strict fails with a *ParseError on the first error-level violation.
+	// Otherwise the parser skips or repairs what it can and records every
+	// problem in Calendar.Diagnostics.
+	Strict bool
+	// Encoding overrides charset detection, e.g. "windows-1252" or "utf-16le".
+	// See DecodeInput.
+	Encoding string
+}
+
+// Parse parses an iCalendar stream according to RFC 5545 in lenient mode.
+func Parse(r io.Reader) (*Calendar, error) {
+	return ParseWithOptions(r, ParseOptions{})
+}
+
+// ParseWithOptions parses an iCalendar stream according to RFC 5545.
+// Strict violations are returned as *ParseError.
+func ParseWithOptions(r io.Reader, opts ParseOptions) (*Calendar, error) {
+	return parseICS(r, opts)
+}
+
+// WriteJSON writes the calendar as indented JSON to outputPath.
+func WriteJSON(calendar *Calendar, outputPath string) error {
+	// Marshal calendar to JSON with proper indentation
+	metadataJSON, err := json.MarshalIndent(calendar, "", "  ")
 	if err != nil {
-		return nil, AppError{Message: "failed to parse ICS file", Value: err}
+		return AppError{Message: "failed to marshal JSON", Value: err, Code: CodeWriteFailed}
 	}
 
-	// Write to file if output path is provided
-	if outputPath != "" {
-		// Marshal calendar to JSON with proper indentation
-		metadataJSON, err := json.MarshalIndent(calendar, "", "  ")
-		if err != nil {
-			return nil, AppError{Message: "failed to marshal JSON", Value: err}
-		}
+	// Ensure directory exists
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0750); err != nil {
+		return AppError{Message: "failed to create directory", Value: err, Code: CodeWriteFailed}
+	}
 
-		// Ensure directory exists
-		if err := os.MkdirAll(filepath.Dir(outputPath), 0750); err != nil {
-			return nil, AppError{Message: "failed to create directory", Value: err}
-		}
+	// Write metadata to file
+	if err := os.WriteFile(outputPath, metadataJSON, 0600); err != nil {
+		return AppError{Message: "failed to write file", Value: err, Code: CodeWriteFailed}
+	}
 
-		// Write metadata to file
-		if err := os.WriteFile(outputPath, metadataJSON, 0600); err != nil {
-			return nil, AppError{Message: "failed to write file", Value: err}
-		}
+	return nil
+}
+
+// ReadJSON reads a calendar previously written by WriteJSON.
+func ReadJSON(jsonPath string) (*Calendar, error) {
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		return nil, AppError{Message: "failed to read JSON file", Value: err, Code: CodeReadFailed}
 	}
 
-	return calendar, nil
+	var calendar Calendar
+	if err := json.Unmarshal(data, &calendar); err != nil {
+		return nil, AppError{Message: "failed to parse JSON file", Value: err, Code: CodeInvalidJSON}
+	}
+
+	return &calendar, nil
+}
+
+// parser holds the state of a single parseICS run.
+type parser struct {
+	strict      bool
+	diagnostics []Diagnostic
 }
 
-// parseICS parses an ICS file according to RFC 5545
-func parseICS(file *os.File) (*Calendar, error) {
-	scanner := bufio.NewScanner(file)
+// report records a diagnostic. In strict mode, errors are returned as
+// *ParseError instead and parsing stops.
+func (p *parser) report(line, column int, code string, format string, args ...any) error {
+	d := newDiagnostic(line, column, code, fmt.Sprintf(format, args...))
+	if p.strict && d.Severity == SeverityError {
+		return &ParseError{AppError: AppError{Message: d.Message, Code: code}, Line: line, Column: column}
+	}
+	p.diagnostics = append(p.diagnostics, d)
+	return nil
+}
+
+// openComponent is a component on the parser's BEGIN/END stack.
+type openComponent struct {
+	Name string
+	Line int
+}
+
+// parseICS parses an ICS stream according to RFC 5545
+func parseICS(r io.Reader, opts ParseOptions) (*Calendar, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, AppError{Message: "failed to read file", Value: err, Code: CodeReadFailed}
+	}
+
+	p := &parser{strict: opts.Strict}
 	calendar := &Calendar{}
 	var currentEvent *Event
-	var lines []string
+	var currentTodo *Todo
+	var currentParticipant *Participant
+	var currentLocation *VLocation
+	var currentResource *VResource
+	var currentAvailability *Availability
+	var currentAvailable *Event
+	var currentAlarm *Alarm
+	var stack []openComponent
 
-	// First pass: unfold lines (handle line continuation)
-	for scanner.Scan() {
-		line := scanner.Text()
-		lines = append(lines, line)
+	// owner returns the innermost open event or task
+	owner := func() *Event {
+		if currentTodo != nil {
+			return &currentTodo.Event
+		}
+		return currentEvent
+	}
+	// closeComponent stores a finished component in its parent
+	closeComponent := func(component string) {
+		switch component {
+		case "VEVENT":
+			if currentEvent != nil {
+				calendar.Events = append(calendar.Events, *currentEvent)
+				currentEvent = nil
+			}
+		case "VTODO":
+			if currentTodo != nil {
+				calendar.Todos = append(calendar.Todos, *currentTodo)
+				currentTodo = nil
+			}
+		case "VAVAILABILITY":
+			if currentAvailability != nil {
+				calendar.Availability = append(calendar.Availability, *currentAvailability)
+				currentAvailability = nil
+			}
+		case "AVAILABLE":
+			if currentAvailability != nil && currentAvailable != nil {
+				currentAvailability.Available = append(currentAvailability.Available, *currentAvailable)
+			}
+			currentAvailable = nil
+		case "VALARM":
+			if e := owner(); currentAlarm != nil && e != nil {
+				e.Alarms = append(e.Alarms, *currentAlarm)
+			}
+			currentAlarm = nil
+		case "PARTICIPANT":
+			if e := owner(); currentParticipant != nil && e != nil {
+				e.Participants = append(e.Participants, *currentParticipant)
+			}
+			currentParticipant = nil
+		case "VLOCATION":
+			if currentLocation == nil {
+				break
+			}
+			if currentParticipant != nil {
+				currentParticipant.VLocations = append(currentParticipant.VLocations, *currentLocation)
+			} else if e := owner(); e != nil {
+				e.VLocations = append(e.VLocations, *currentLocation)
+			}
+			currentLocation = nil
+		case "VRESOURCE":
+			if currentResource == nil {
+				break
+			}
+			if currentParticipant != nil {
+				currentParticipant.VResources = append(currentParticipant.VResources, *currentResource)
+			} else if e := owner(); e != nil {
+				e.VResources = append(e.VResources, *currentResource)
+			}
+			currentResource = nil
+		}
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, AppError{Message: "failed to read file", Value: err}
+	data, calendar.Encoding, err = DecodeInput(data, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if calendar.Encoding != EncodingUTF8 {
+		if err := p.report(1, 0, CodeNonUTF8Encoding, "input is encoded as %s, not UTF-8", calendar.Encoding); err != nil {
+			return nil, err
+		}
 	}
 
 	// Unfold lines (lines starting with space or tab continue previous line)
-	unfoldedLines := unfoldLines(lines)
+	physical, _ := splitPhysicalLines(data)
+	unfoldedLines := unfoldNumbered(physical)
+
+	if len(unfoldedLines) == 0 || !strings.EqualFold(strings.TrimSpace(unfoldedLines[0].Text), "BEGIN:VCALENDAR") {
+		if err := p.report(1, 1, CodeNotICalendar, "stream does not start with BEGIN:VCALENDAR"); err != nil {
+			return nil, err
+		}
+	}
 
 	// Parse the unfolded lines
-	for _, line := range unfoldedLines {
-		line = strings.TrimSpace(line)
-		if line == "" {
+	for _, nl := range unfoldedLines {
+		// Values are kept byte for byte; only blank lines are skipped
+		line := nl.Text
+		if strings.TrimSpace(line) == "" {
 			continue
 		}
 
+		prop, err := ParseContentLine(line)
+		if err != nil {
+			column := 1
+			if cle, ok := err.(*ContentLineError); ok {
+				column = cle.Column
+				err = errors.New(cle.Message)
+			}
+			if err := p.report(nl.Line, column, CodeInvalidContentLine, "%v", err); err != nil {
+				return nil, err
+			}
+			var ok bool
+			if prop, ok = lenientContentLine(line); !ok {
+				continue // Skip malformed lines
+			}
+		}
+		prop.Line = nl.Line
+
 		// Parse component boundaries
-		if strings.HasPrefix(line, "BEGIN:") {
-			component := strings.TrimPrefix(line, "BEGIN:")
-			if component == "VEVENT" {
+		switch prop.Name {
+		case "BEGIN":
+			component := strings.ToUpper(strings.TrimSpace(prop.Value))
+			stack = append(stack, openComponent{Name: component, Line: nl.Line})
+			switch component {
+			case "VEVENT":
 				currentEvent = &Event{}
+			case "VTODO":
+				currentTodo = &Todo{}
+			case "VAVAILABILITY":
+				currentAvailability = &Availability{}
+			case "AVAILABLE":
+				currentAvailable = &Event{}
+			case "VALARM":
+				currentAlarm = &Alarm{}
+			case "PARTICIPANT":
+				currentParticipant = &Participant{}
+			case "VLOCATION":
+				currentLocation = &VLocation{}
+			case "VRESOURCE":
+				currentResource = &VResource{}
 			}
 			continue
-		}
-
-		if strings.HasPrefix(line, "END:") {
-			component := strings.TrimPrefix(line, "END:")
-			if component == "VEVENT" && currentEvent != nil {
-				calendar.Events = append(calendar.Events, *currentEvent)
-				currentEvent = nil
+		case "END":
+			component := strings.ToUpper(strings.TrimSpace(prop.Value))
+			idx := len(stack) - 1
+			for idx >= 0 && stack[idx].Name != component {
+				idx--
+			}
+			switch {
+			case idx < 0:
+				if err := p.report(nl.Line, 1, CodeUnbalancedComponent, "END:%s without matching BEGIN", component); err != nil {
+					return nil, err
+				}
+				continue
+			case idx < len(stack)-1:
+				open := stack[len(stack)-1]
+				if err := p.report(open.Line, 1, CodeUnbalancedComponent, "BEGIN:%s is not closed before END:%s on line %d", open.Name, component, nl.Line); err != nil {
+					return nil, err
+				}
+			}
+			// Components left open inside are closed implicitly
+			for i := len(stack) - 1; i >= idx; i-- {
+				closeComponent(stack[i].Name)
 			}
+			stack = stack[:idx]
 			continue
 		}
 
-		// Parse properties
-		name, value, tzid, err := parsePropertyWithTZ(line)
-		if err != nil {
-			continue // Skip malformed lines
+		if len(stack) == 0 {
+			if err := p.report(nl.Line, 1, CodeInvalidContentLine, "property %s outside of any component", prop.Name); err != nil {
+				return nil, err
+			}
+			continue
 		}
 
-		// Handle calendar-level properties
-		if currentEvent == nil {
-			switch name {
+		// Properties of other nested components (VTIMEZONE, ...) are not mapped
+		switch stack[len(stack)-1].Name {
+		case "VCALENDAR":
+			// Handle calendar-level properties
+			switch prop.Name {
 			case "PRODID":
-				calendar.ProdID = value
+				calendar.ProdID = prop.Value
 			case "VERSION":
-				calendar.Version = value
+				calendar.Version = prop.Value
 			case "CALSCALE":
-				calendar.CalScale = value
+				calendar.CalScale = prop.Value
 			case "METHOD":
-				calendar.Method = value
+				calendar.Method = prop.Value
+			default:
+				if err := p.calendarProperty(calendar, prop); err != nil {
+					return nil, err
+				}
 			}
-		} else {
-			// Handle event properties
-			switch name {
-			// Required properties
-			case "UID":
-				currentEvent.UID = value
-
-			// Date/Time properties - parse to ISO8601 UTC
-			case "DTSTART", "DTSTART;TZID":
-				if parsed := parseICalDateTimeWithTZ(value, tzid); parsed != "" {
-					currentEvent.Start = parsed
-				} else {
-					currentEvent.Start = value // Fallback to raw value if parsing fails
+		case "VEVENT":
+			if currentEvent != nil {
+				if err := p.eventProperty(currentEvent, prop); err != nil {
+					return nil, err
 				}
-			case "DTEND", "DTEND;TZID":
-				if parsed := parseICalDateTimeWithTZ(value, tzid); parsed != "" {
-					currentEvent.End = parsed
-				} else {
-					currentEvent.End = value // Fallback to raw value if parsing fails
+			}
+		case "VTODO":
+			if currentTodo != nil {
+				if err := p.todoProperty(currentTodo, prop); err != nil {
+					return nil, err
 				}
-			case "DURATION":
-				currentEvent.Duration = value
-
-			// Core descriptive properties
-			case "SUMMARY":
-				currentEvent.Summary = value
-			case "DESCRIPTION":
-				currentEvent.Description = unescapeText(value)
-			case "LOCATION":
-				currentEvent.Location = unescapeText(value)
-
-			// Optional commonly used properties
-			case "URL":
-				currentEvent.URL = value
-			case "STATUS":
-				currentEvent.Status = strings.ToUpper(value)
-			case "CATEGORIES":
-				if value != "" {
-					currentEvent.Categories = strings.Split(value, ",")
-					for i := range currentEvent.Categories {
-						currentEvent.Categories[i] = strings.TrimSpace(currentEvent.Categories[i])
-					}
+			}
+		case "VAVAILABILITY":
+			if currentAvailability != nil {
+				if err := p.availabilityProperty(currentAvailability, prop); err != nil {
+					return nil, err
 				}
-
-			// Classification and access
-			case "CLASS":
-				currentEvent.Class = strings.ToUpper(value)
-			case "TRANSP":
-				currentEvent.Transp = strings.ToUpper(value)
-
-			// Organizational properties
-			case "ORGANIZER":
-				currentEvent.Organizer = value
-			case "ATTENDEE":
-				currentEvent.Attendees = append(currentEvent.Attendees, value)
-
-			// Scheduling properties
-			case "PRIORITY":
-				// PRIORITY is 0-9 integer
-				if priority := parseInt(value); priority >= 0 {
-					currentEvent.Priority = priority
+			}
+		case "AVAILABLE":
+			if currentAvailable != nil {
+				if err := p.eventProperty(currentAvailable, prop); err != nil {
+					return nil, err
 				}
-			case "SEQUENCE":
-				if sequence := parseInt(value); sequence >= 0 {
-					currentEvent.Sequence = sequence
+			}
+		case "VALARM":
+			if currentAlarm != nil {
+				if err := p.alarmProperty(currentAlarm, prop); err != nil {
+					return nil, err
 				}
-
-			// Date/Time metadata
-			case "CREATED":
-				currentEvent.Created = value
-			case "LAST-MODIFIED":
-				currentEvent.LastModified = value
-
-			// Recurrence properties
-			case "RRULE":
-				currentEvent.RRule = value
-			case "RECURRENCE-ID", "RECURRENCE-ID;TZID":
-				currentEvent.RecurrenceID = value
-			case "EXDATE", "EXDATE;TZID":
-				currentEvent.ExDates = append(currentEvent.ExDates, value)
-			case "RDATE", "RDATE;TZID":
-				currentEvent.RDates = append(currentEvent.RDates, value)
-
-			case "GEO":
-				// Other properties
-				if value != "" {
-					parts := strings.Split(value, ";")
-					if len(parts) == 2 {
-						lat, err1 := strconv.ParseFloat(parts[0], 64)
-						lon, err2 := strconv.ParseFloat(parts[1], 64)
-						if err1 == nil && err2 == nil {
-							currentEvent.Geo = Geolocation{
-								Latitude:  lat,
-								Longitude: lon,
-							}
-						}
-					}
+			}
+		case "PARTICIPANT":
+			if currentParticipant != nil {
+				if err := p.participantProperty(currentParticipant, prop); err != nil {
+					return nil, err
 				}
-
-			case "RESOURCES":
-				if value != "" {
-					resources := strings.Split(value, ",")
-					for _, r := range resources {
-						currentEvent.Resources = append(currentEvent.Resources, strings.TrimSpace(r))
-					}
+			}
+		case "VLOCATION":
+			if currentLocation != nil {
+				if err := p.locationProperty(currentLocation, prop); err != nil {
+					return nil, err
+				}
+			}
+		case "VRESOURCE":
+			if currentResource != nil {
+				if err := p.resourceProperty(currentResource, prop); err != nil {
+					return nil, err
 				}
-			case "CONTACT":
-				currentEvent.Contact = value
-			case "RELATED-TO":
-				currentEvent.RelatedTo = value
-			case "COMMENT":
-				currentEvent.Comment = unescapeText(value)
 			}
 		}
 	}
 
+	for i := len(stack) - 1; i >= 0; i-- {
+		if err := p.report(stack[i].Line, 1, CodeUnbalancedComponent, "BEGIN:%s is never closed", stack[i].Name); err != nil {
+			return nil, err
+		}
+		// Keep unterminated components rather than losing their data
+		closeComponent(stack[i].Name)
+	}
+
+	calendar.Diagnostics = p.diagnostics
 	return calendar, nil
 }
 
+// eventProperty maps a VEVENT property onto the event.
+func (p *parser) eventProperty(currentEvent *Event, prop ContentLine) error {
+	value := prop.Value
+	tzid := prop.Param("TZID")
+
+	if altrep := prop.Param("ALTREP"); altrep != "" && slices.Contains(altRepProperties, prop.Name) {
+		if currentEvent.AltReps == nil {
+			currentEvent.AltReps = map[string]string{}
+		}
+		currentEvent.AltReps[prop.Name] = altrep
+		// Some writers inline the HTML description as a data: URI
+		if content, mediaType, ok := decodeDataURI(altrep); ok && prop.Name == "DESCRIPTION" && mediaType == "text/html" && currentEvent.DescriptionHTML == "" {
+			currentEvent.DescriptionHTML = content
+		}
+	}
+
+	switch prop.Name {
+	// Required properties
+	case "UID":
+		currentEvent.UID = DecodeText(value)
+
+	// Date/Time properties - parse to ISO8601 UTC
+	case "DTSTART":
+		start, err := p.dateTime(prop)
+		if err != nil {
+			return err
+		}
+		currentEvent.Start = start
+		currentEvent.TZID = tzid
+	case "DTEND":
+		end, err := p.dateTime(prop)
+		if err != nil {
+			return err
+		}
+		currentEvent.End = end
+	case "DURATION":
+		currentEvent.Duration = value
+
+	// Core descriptive properties
+	case "SUMMARY":
+		currentEvent.Summary = DecodeText(value)
+	case "DESCRIPTION":
+		currentEvent.Description = DecodeText(value)
+	case "LOCATION":
+		currentEvent.Location = DecodeText(value)
+	case "X-ALT-DESC":
+		if fmttype := prop.Param("FMTTYPE"); fmttype == "" || strings.EqualFold(fmttype, "text/html") {
+			currentEvent.DescriptionHTML = DecodeText(value)
+		}
+
+	// Optional commonly used properties
+	case "URL":
+		currentEvent.URL = value
+	case "STATUS":
+		currentEvent.Status = strings.ToUpper(strings.TrimSpace(value))
+	case "CATEGORIES":
+		currentEvent.Categories = appendTextList(currentEvent.Categories, prop, value)
+
+	// Classification and access
+	case "CLASS":
+		currentEvent.Class = strings.ToUpper(strings.TrimSpace(value))
+	case "TRANSP":
+		currentEvent.Transp = strings.ToUpper(strings.TrimSpace(value))
+
+	// Organizational properties
+	case "ORGANIZER":
+		currentEvent.Organizer = value
+	case "ATTENDEE":
+		currentEvent.Attendees = append(currentEvent.Attendees, newProperty(prop, strings.TrimSpace(value)))
+
+	// Scheduling properties
+	case "PRIORITY":
+		// PRIORITY is 0-9 integer
+		priority := parseInt(value)
+		if priority < 0 || priority > 9 {
+			return p.report(prop.Line, prop.valueColumn, CodeInvalidPropertyValue, "PRIORITY %q must be an integer from 0 to 9", value)
+		}
+		currentEvent.Priority = priority
+	case "SEQUENCE":
+		sequence := parseInt(value)
+		if sequence < 0 {
+			return p.report(prop.Line, prop.valueColumn, CodeInvalidPropertyValue, "SEQUENCE %q must be a non-negative integer", value)
+		}
+		currentEvent.Sequence = sequence
+
+	// Date/Time metadata
+	case "DTSTAMP":
+		stamp, err := p.dateTime(prop)
+		if err != nil {
+			return err
+		}
+		currentEvent.DTStamp = stamp
+	case "CREATED":
+		currentEvent.Created = value
+	case "LAST-MODIFIED":
+		currentEvent.LastModified = value
+
+	// Recurrence properties
+	case "RRULE":
+		currentEvent.RRule = value
+	case "RECURRENCE-ID":
+		recurrenceID, err := p.dateTime(prop)
+		if err != nil {
+			return err
+		}
+		currentEvent.RecurrenceID = recurrenceID
+	case "EXDATE":
+		dates, err := p.dateTimeList(prop)
+		if err != nil {
+			return err
+		}
+		currentEvent.ExDates = append(currentEvent.ExDates, dates...)
+	case "RDATE":
+		dates, err := p.dateTimeList(prop)
+		if err != nil {
+			return err
+		}
+		currentEvent.RDates = append(currentEvent.RDates, dates...)
+
+	case "GEO":
+		// Other properties
+		geo, err := p.geo(prop)
+		if err != nil {
+			return err
+		}
+		if geo != nil {
+			currentEvent.Geo = *geo
+		}
+
+	case "RESOURCES":
+		currentEvent.Resources = appendTextList(currentEvent.Resources, prop, value)
+	case "CONTACT":
+		currentEvent.Contacts = append(currentEvent.Contacts, newProperty(prop, DecodeText(value)))
+	case "RELATED-TO":
+		currentEvent.RelatedTo = append(currentEvent.RelatedTo, newProperty(prop, DecodeText(value)))
+	case "COMMENT":
+		currentEvent.Comments = append(currentEvent.Comments, newProperty(prop, DecodeText(value)))
+	case "REQUEST-STATUS":
+		// Keep escapes, the ';' separated parts are decoded by the consumer
+		currentEvent.RequestStatus = append(currentEvent.RequestStatus, newProperty(prop, value))
+	case "ATTACH":
+		currentEvent.Attachments = append(currentEvent.Attachments, newAttachment(prop))
+
+	// RFC 7986 properties
+	case "COLOR":
+		currentEvent.Color = strings.TrimSpace(value)
+	case "IMAGE":
+		currentEvent.Images = append(currentEvent.Images, newImage(prop))
+	case "CONFERENCE":
+		currentEvent.Conferences = append(currentEvent.Conferences, newConference(prop))
+
+	// RFC 9073 properties
+	case "STRUCTURED-DATA":
+		currentEvent.StructuredData = append(currentEvent.StructuredData, newStructuredData(prop))
+	}
+
+	return nil
+}
+
+// participantProperty maps a PARTICIPANT property (RFC 9073 §7.1).
+func (p *parser) participantProperty(participant *Participant, prop ContentLine) error {
+	value := prop.Value
+
+	switch prop.Name {
+	case "UID":
+		participant.UID = DecodeText(value)
+	case "PARTICIPANT-TYPE":
+		participant.ParticipantType = strings.ToUpper(strings.TrimSpace(value))
+	case "CALENDAR-ADDRESS":
+		participant.CalendarAddress = strings.TrimSpace(value)
+	case "SUMMARY":
+		participant.Summary = DecodeText(value)
+	case "DESCRIPTION":
+		participant.Description = DecodeText(value)
+	case "URL":
+		participant.URL = strings.TrimSpace(value)
+	case "STATUS":
+		participant.Status = strings.ToUpper(strings.TrimSpace(value))
+	case "GEO":
+		geo, err := p.geo(prop)
+		if err != nil {
+			return err
+		}
+		participant.Geo = geo
+	case "CONTACT":
+		participant.Contacts = append(participant.Contacts, newProperty(prop, DecodeText(value)))
+	case "COMMENT":
+		participant.Comments = append(participant.Comments, newProperty(prop, DecodeText(value)))
+	case "STRUCTURED-DATA":
+		participant.StructuredData = append(participant.StructuredData, newStructuredData(prop))
+	}
+
+	return nil
+}
+
+// locationProperty maps a VLOCATION property (RFC 9073 §7.2).
+func (p *parser) locationProperty(location *VLocation, prop ContentLine) error {
+	value := prop.Value
+
+	switch prop.Name {
+	case "UID":
+		location.UID = DecodeText(value)
+	case "NAME":
+		location.Name = DecodeText(value)
+	case "DESCRIPTION":
+		location.Description = DecodeText(value)
+	case "LOCATION-TYPE":
+		location.LocationTypes = appendUnique(location.LocationTypes, splitTextList(value)...)
+	case "URL":
+		location.URL = strings.TrimSpace(value)
+	case "GEO":
+		geo, err := p.geo(prop)
+		if err != nil {
+			return err
+		}
+		location.Geo = geo
+	case "STRUCTURED-DATA":
+		location.StructuredData = append(location.StructuredData, newStructuredData(prop))
+	}
+
+	return nil
+}
+
+// resourceProperty maps a VRESOURCE property (RFC 9073 §7.3).
+func (p *parser) resourceProperty(resource *VResource, prop ContentLine) error {
+	value := prop.Value
+
+	switch prop.Name {
+	case "UID":
+		resource.UID = DecodeText(value)
+	case "NAME":
+		resource.Name = DecodeText(value)
+	case "DESCRIPTION":
+		resource.Description = DecodeText(value)
+	case "RESOURCE-TYPE":
+		resource.ResourceType = strings.ToUpper(strings.TrimSpace(value))
+	case "GEO":
+		geo, err := p.geo(prop)
+		if err != nil {
+			return err
+		}
+		resource.Geo = geo
+	case "STRUCTURED-DATA":
+		resource.StructuredData = append(resource.StructuredData, newStructuredData(prop))
+	}
+
+	return nil
+}
+
+// alarmProperty maps a VALARM property (RFC 5545 §3.6.6, RFC 9074).
+func (p *parser) alarmProperty(alarm *Alarm, prop ContentLine) error {
+	value := prop.Value
+
+	switch prop.Name {
+	case "UID":
+		alarm.UID = DecodeText(value)
+	case "ACTION":
+		alarm.Action = strings.ToUpper(strings.TrimSpace(value))
+	case "TRIGGER":
+		if strings.EqualFold(prop.Param("VALUE"), "DATE-TIME") {
+			trigger, err := p.dateTime(prop)
+			if err != nil {
+				return err
+			}
+			alarm.Trigger = trigger
+			break
+		}
+		alarm.Trigger = strings.TrimSpace(value)
+		if _, err := ParseDuration(alarm.Trigger); err != nil {
+			return p.report(prop.Line, prop.valueColumn, CodeInvalidDuration, "%s has invalid value %q", prop.Name, value)
+		}
+		if related := strings.ToUpper(prop.Param("RELATED")); related != "" && related != "START" {
+			alarm.Related = related
+		}
+	case "DURATION":
+		alarm.Duration = strings.TrimSpace(value)
+	case "REPEAT":
+		repeat := parseInt(value)
+		if repeat < 0 {
+			return p.report(prop.Line, prop.valueColumn, CodeInvalidPropertyValue, "REPEAT %q must be a non-negative integer", value)
+		}
+		alarm.Repeat = repeat
+	case "SUMMARY":
+		alarm.Summary = DecodeText(value)
+	case "DESCRIPTION":
+		alarm.Description = DecodeText(value)
+	case "ATTENDEE":
+		alarm.Attendees = append(alarm.Attendees, newProperty(prop, strings.TrimSpace(value)))
+	case "ATTACH":
+		alarm.Attachments = append(alarm.Attachments, newAttachment(prop))
+	case "ACKNOWLEDGED":
+		acknowledged, err := p.dateTime(prop)
+		if err != nil {
+			return err
+		}
+		alarm.Acknowledged = acknowledged
+	}
+
+	return nil
+}
+
+// geo parses a GEO property. It returns nil for an empty or invalid value.
+func (p *parser) geo(prop ContentLine) (*Geolocation, error) {
+	value := prop.Value
+	if value == "" {
+		return nil, nil
+	}
+	if !validGeo(value) {
+		return nil, p.report(prop.Line, prop.valueColumn, CodeInvalidGeo, "GEO value %q is not a valid latitude;longitude", value)
+	}
+	lat, lon, _ := strings.Cut(value, ";")
+	latitude, _ := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	longitude, _ := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	return &Geolocation{
+		Latitude:  latitude,
+		Longitude: longitude,
+	}, nil
+}
+
+// altRepProperties may carry an ALTREP parameter pointing to a richer
+// representation of their value (RFC 5545 §3.2.1).
+var altRepProperties = []string{"SUMMARY", "DESCRIPTION", "LOCATION"}
+
+// calendarProperty maps the RFC 7986 properties of VCALENDAR and their
+// X-WR-* predecessors. The standard property wins when both are present.
+func (p *parser) calendarProperty(calendar *Calendar, prop ContentLine) error {
+	value := prop.Value
+
+	switch prop.Name {
+	case "NAME":
+		calendar.Name = DecodeText(value)
+	case "X-WR-CALNAME":
+		if calendar.Name == "" {
+			calendar.Name = DecodeText(value)
+		}
+	case "DESCRIPTION":
+		calendar.Description = DecodeText(value)
+	case "X-WR-CALDESC":
+		if calendar.Description == "" {
+			calendar.Description = DecodeText(value)
+		}
+	case "UID":
+		calendar.UID = DecodeText(value)
+	case "URL":
+		calendar.URL = strings.TrimSpace(value)
+	case "LAST-MODIFIED":
+		modified, err := p.dateTime(prop)
+		if err != nil {
+			return err
+		}
+		calendar.LastModified = modified
+	case "CATEGORIES":
+		calendar.Categories = appendTextList(calendar.Categories, prop, value)
+	case "COLOR":
+		calendar.Color = strings.TrimSpace(value)
+	case "IMAGE":
+		calendar.Images = append(calendar.Images, newImage(prop))
+	case "REFRESH-INTERVAL", "X-PUBLISHED-TTL":
+		interval := strings.TrimSpace(value)
+		if _, err := ParseDuration(interval); err != nil {
+			return p.report(prop.Line, prop.valueColumn, CodeInvalidDuration, "%s has invalid value %q", prop.Name, value)
+		}
+		if prop.Name == "REFRESH-INTERVAL" || calendar.RefreshInterval == "" {
+			calendar.RefreshInterval = interval
+		}
+	case "SOURCE":
+		calendar.SourceURL = strings.TrimSpace(value)
+	case "X-WR-TIMEZONE":
+		calendar.Timezone = strings.TrimSpace(value)
+	}
+
+	return nil
+}
+
+// availabilityProperty maps a VAVAILABILITY property onto the availability.
+func (p *parser) availabilityProperty(availability *Availability, prop ContentLine) error {
+	if prop.Name == "BUSYTYPE" {
+		availability.BusyType = strings.ToUpper(strings.TrimSpace(prop.Value))
+		return nil
+	}
+	return p.eventProperty(&availability.Event, prop)
+}
+
+// todoProperty maps a VTODO property onto the task. Properties shared with
+// VEVENT are handled by eventProperty.
+func (p *parser) todoProperty(currentTodo *Todo, prop ContentLine) error {
+	switch prop.Name {
+	case "DUE":
+		due, err := p.dateTime(prop)
+		if err != nil {
+			return err
+		}
+		currentTodo.Due = due
+	case "COMPLETED":
+		completed, err := p.dateTime(prop)
+		if err != nil {
+			return err
+		}
+		currentTodo.Completed = completed
+	case "PERCENT-COMPLETE":
+		percent := parseInt(prop.Value)
+		if percent < 0 || percent > 100 {
+			return p.report(prop.Line, prop.valueColumn, CodeInvalidPropertyValue, "PERCENT-COMPLETE %q must be an integer from 0 to 100", prop.Value)
+		}
+		currentTodo.PercentComplete = percent
+	default:
+		return p.eventProperty(&currentTodo.Event, prop)
+	}
+	return nil
+}
+
+// dateTime converts a DATE or DATE-TIME property to ISO8601, falling back to
+// the raw value (and reporting it) when it cannot be parsed.
+func (p *parser) dateTime(prop ContentLine) (string, error) {
+	if parsed := parseICalDateTimeWithTZ(strings.TrimSpace(prop.Value), prop.Param("TZID")); parsed != "" {
+		return parsed, nil
+	}
+	err := p.report(prop.Line, prop.valueColumn, CodeInvalidDateTime, "%s has invalid value %q", prop.Name, prop.Value)
+	return prop.Value, err
+}
+
+// dateTimeList converts a date-time list property, see parseICalDateTimeList.
+func (p *parser) dateTimeList(prop ContentLine) ([]string, error) {
+	for _, part := range strings.Split(prop.Value, ",") {
+		start, _, _ := strings.Cut(strings.TrimSpace(part), "/")
+		if parseICalDateTimeWithTZ(start, "") == "" {
+			if err := p.report(prop.Line, prop.valueColumn, CodeInvalidDateTime, "%s has invalid value %q", prop.Name, part); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return parseICalDateTimeList(prop.Value, prop.Param("TZID")), nil
+}
+
+// lenientContentLine splits a line that ParseContentLine rejected at its first
+// colon, so that lenient parsing keeps as much data as possible.
+func lenientContentLine(line string) (ContentLine, bool) {
+	nameWithParams, value, ok := strings.Cut(line, ":")
+	if !ok {
+		return ContentLine{}, false
+	}
+	name, _, _ := strings.Cut(nameWithParams, ";")
+	if name == "" {
+		return ContentLine{}, false
+	}
+	return ContentLine{Name: strings.ToUpper(name), Params: map[string][]string{}, Value: value, valueColumn: len(nameWithParams) + 2}, true
+}
+
 // parseICalDateTimeWithTZ converts iCalendar datetime format to ISO8601 UTC
 // Supports formats like:
 // - 20251004T090000Z (UTC)
@@ -276,75 +888,152 @@ func parseICalDateTimeWithTZ(icalDate string, tzid string) string {
 	return ""
 }
 
-// unfoldLines handles RFC 5545 line folding where lines starting with space or tab
-// are continuations of the previous line
-func unfoldLines(lines []string) []string {
-	var result []string
-	var currentLine string
-
-	for _, line := range lines {
-		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') {
-			// This is a continuation of the previous line
-			currentLine += line[1:] // Remove the leading space/tab
-		} else {
-			// This is a new line
-			if currentLine != "" {
-				result = append(result, currentLine)
-			}
-			currentLine = line
+// parseICalDateTimeList converts a comma-separated list of iCalendar date-times
+// (as used by EXDATE and RDATE) to ISO8601, keeping unparseable entries as-is.
+// PERIOD values ("start/end") keep their end part unchanged.
+func parseICalDateTimeList(value string, tzid string) []string {
+	parts := strings.Split(value, ",")
+	result := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		start, rest, isPeriod := strings.Cut(part, "/")
+		if parsed := parseICalDateTimeWithTZ(start, tzid); parsed != "" {
+			start = parsed
 		}
+		if isPeriod {
+			start += "/" + rest
+		}
+		result = append(result, start)
 	}
+	return result
+}
 
-	// Don't forget the last line
-	if currentLine != "" {
-		result = append(result, currentLine)
+// newProperty keeps a property value together with its parameters.
+func newProperty(prop ContentLine, value string) Property {
+	return Property{Value: value, Params: flattenParams(prop.Params)}
+}
+
+// newImage converts an IMAGE property.
+func newImage(prop ContentLine) Image {
+	image := Image{Attachment: newAttachment(prop)}
+	image.Display = strings.ToUpper(image.Params["DISPLAY"])
+	image.AltRep = image.Params["ALTREP"]
+	delete(image.Params, "DISPLAY")
+	delete(image.Params, "ALTREP")
+	if len(image.Params) == 0 {
+		image.Params = nil
 	}
+	return image
+}
 
-	return result
+// newStructuredData converts a STRUCTURED-DATA property.
+func newStructuredData(prop ContentLine) StructuredData {
+	params := flattenParams(prop.Params)
+	data := StructuredData{
+		Type:    strings.ToUpper(params["VALUE"]),
+		FmtType: params["FMTTYPE"],
+		Schema:  params["SCHEMA"],
+	}
+	switch data.Type {
+	case "BINARY", "URI":
+		data.Value = strings.TrimSpace(prop.Value)
+	default:
+		data.Value = DecodeText(prop.Value)
+	}
+	delete(params, "VALUE")
+	delete(params, "ENCODING")
+	delete(params, "FMTTYPE")
+	delete(params, "SCHEMA")
+	if len(params) > 0 {
+		data.Params = params
+	}
+	return data
 }
 
-// parsePropertyWithTZ parses a property line into name, value, and timezone ID
-// Handles properties with parameters like "DTSTART;TZID=Europe/Zurich:20251004T090000"
-func parsePropertyWithTZ(line string) (string, string, string, error) {
-	// Find the colon that separates name from value
-	colonIndex := strings.Index(line, ":")
-	if colonIndex == -1 {
-		return "", "", "", AppError{Message: "invalid property line: no colon found"}
+// newConference converts a CONFERENCE property.
+func newConference(prop ContentLine) Conference {
+	params := flattenParams(prop.Params)
+	conference := Conference{URI: strings.TrimSpace(prop.Value), Label: params["LABEL"]}
+	for _, feature := range strings.Split(params["FEATURE"], ",") {
+		if feature = strings.ToUpper(strings.TrimSpace(feature)); feature != "" {
+			conference.Features = appendUnique(conference.Features, feature)
+		}
 	}
+	delete(params, "FEATURE")
+	delete(params, "LABEL")
+	delete(params, "VALUE")
+	if len(params) > 0 {
+		conference.Params = params
+	}
+	return conference
+}
 
-	nameWithParams := line[:colonIndex]
-	value := line[colonIndex+1:]
-	tzid := ""
+// newAttachment converts an ATTACH property.
+func newAttachment(prop ContentLine) Attachment {
+	params := flattenParams(prop.Params)
+	attachment := Attachment{FmtType: params["FMTTYPE"]}
+	delete(params, "FMTTYPE")
+	if strings.EqualFold(params["VALUE"], "BINARY") || strings.EqualFold(params["ENCODING"], "BASE64") {
+		attachment.Data = strings.TrimSpace(prop.Value)
+		delete(params, "VALUE")
+		delete(params, "ENCODING")
+	} else {
+		attachment.URI = strings.TrimSpace(prop.Value)
+	}
+	attachment.Filename = attachmentFilename(params)
+	attachment.Size = attachmentSize(params, attachment.Data)
+	for _, name := range append(filenameParams, "SIZE") {
+		delete(params, name)
+	}
+	if len(params) > 0 {
+		attachment.Params = params
+	}
+	return attachment
+}
 
-	// Extract the property name and timezone if present
-	// For "DTSTART;TZID=Europe/Zurich", we want "DTSTART", "Europe/Zurich"
-	name := nameWithParams
-	if semicolonIndex := strings.Index(nameWithParams, ";"); semicolonIndex != -1 {
-		baseName := nameWithParams[:semicolonIndex]
-		params := nameWithParams[semicolonIndex+1:]
+// flattenParams joins multi-valued parameters with commas; nil if there are none.
+func flattenParams(params map[string][]string) map[string]string {
+	if len(params) == 0 {
+		return nil
+	}
+	flat := make(map[string]string, len(params))
+	for name, values := range params {
+		flat[name] = strings.Join(values, ",")
+	}
+	return flat
+}
 
-		// Extract TZID parameter if present
-		if strings.HasPrefix(params, "TZID=") {
-			tzid = strings.TrimPrefix(params, "TZID=")
-			// For DTSTART and DTEND with timezone, we create a composite key
-			name = baseName + ";TZID"
-		} else {
-			name = baseName
+// appendUnique appends the items not yet in list.
+func appendUnique(list []string, items ...string) []string {
+	for _, item := range items {
+		if !slices.Contains(list, item) {
+			list = append(list, item)
 		}
 	}
+	return list
+}
 
-	return name, value, tzid, nil
+// appendTextList appends the items of a comma-separated TEXT list property
+// that are not in list yet, each with the parameters of its line.
+func appendTextList(list []Property, prop ContentLine, value string) []Property {
+	for _, item := range splitTextList(value) {
+		if !slices.ContainsFunc(list, func(p Property) bool { return p.Value == item }) {
+			list = append(list, newProperty(prop, item))
+		}
+	}
+	return list
 }
 
-// unescapeText unescapes special characters in text values according to RFC 5545
-// \n -> newline, \, -> comma, \; -> semicolon, \\ -> backslash
-func unescapeText(text string) string {
-	text = strings.ReplaceAll(text, "\\n", "\n")
-	text = strings.ReplaceAll(text, "\\N", "\n")
-	text = strings.ReplaceAll(text, "\\,", ",")
-	text = strings.ReplaceAll(text, "\\;", ";")
-	text = strings.ReplaceAll(text, "\\\\", "\\")
-	return text
+// splitTextList splits a comma-separated TEXT list, trimming items and
+// dropping empty ones.
+func splitTextList(value string) []string {
+	var items []string
+	for _, item := range SplitText(value, ',') {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 // parseInt safely parses a string to int, returning -1 on error
@@ -0,0 +1,231 @@
+package icaljson
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layouts of the date/time strings stored in Event fields.
+const (
+	layoutDate     = "2006-01-02"
+	layoutFloating = "2006-01-02T15:04:05"
+)
+
+// ParseDateTime parses a date/time as stored in the JSON model.
+// It accepts RFC 3339 timestamps, floating date-times ("2006-01-02T15:04:05"),
+// dates ("2006-01-02") and raw iCalendar values left unparsed by the converter.
+// Floating values are interpreted in loc (UTC when nil). allDay reports date values.
+func ParseDateTime(value string, loc *time.Location) (t time.Time, allDay bool, err error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	value = strings.TrimSpace(value)
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation(layoutFloating, value, loc); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation(layoutDate, value, loc); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse("20060102T150405Z", value); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil {
+		return t, true, nil
+	}
+
+	return time.Time{}, false, AppError{Message: "invalid date-time", Value: value, Code: CodeInvalidDateTime}
+}
+
+// formatLike formats t in the same representation as the reference value,
+// so expanded occurrences look like their master event.
+func formatLike(t time.Time, reference string) string {
+	if _, err := time.Parse(time.RFC3339, reference); err == nil || strings.HasSuffix(reference, "Z") {
+		return t.UTC().Format(time.RFC3339)
+	}
+	if _, allDay, err := ParseDateTime(reference, nil); err == nil && allDay {
+		return t.Format(layoutDate)
+	}
+	return t.Format(layoutFloating)
+}
+
+// ParseDuration parses an RFC 5545 DURATION value such as "PT1H30M", "P1D" or "-P1W".
+func ParseDuration(value string) (time.Duration, error) {
+	s := strings.ToUpper(strings.TrimSpace(value))
+	invalid := AppError{Message: "invalid duration", Value: value, Code: CodeInvalidDuration}
+
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign = -1
+		s = s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, invalid
+	}
+	s = s[1:]
+
+	var total time.Duration
+	inTime := false
+	number := ""
+	for _, r := range s {
+		switch {
+		case r >= '0' && r <= '9':
+			number += string(r)
+		case r == 'T':
+			if inTime || number != "" {
+				return 0, invalid
+			}
+			inTime = true
+		default:
+			n, err := strconv.Atoi(number)
+			if err != nil {
+				return 0, invalid
+			}
+			number = ""
+			unit, ok := durationUnit(r, inTime)
+			if !ok {
+				return 0, invalid
+			}
+			total += time.Duration(n) * unit
+		}
+	}
+	if number != "" {
+		return 0, invalid
+	}
+
+	return sign * total, nil
+}
+
+func durationUnit(r rune, inTime bool) (time.Duration, bool) {
+	if inTime {
+		switch r {
+		case 'H':
+			return time.Hour, true
+		case 'M':
+			return time.Minute, true
+		case 'S':
+			return time.Second, true
+		}
+		return 0, false
+	}
+	switch r {
+	case 'W':
+		return 7 * 24 * time.Hour, true
+	case 'D':
+		return 24 * time.Hour, true
+	}
+	return 0, false
+}
+
+// FormatDuration formats d as an RFC 5545 DURATION value.
+func FormatDuration(d time.Duration) string {
+	const day = 24 * time.Hour
+
+	sign := ""
+	if d < 0 {
+		sign = "-"
+		d = -d
+	}
+	if d == 0 {
+		return "PT0S"
+	}
+	if d%(7*day) == 0 {
+		return fmt.Sprintf("%sP%dW", sign, d/(7*day))
+	}
+
+	var b strings.Builder
+	b.WriteString(sign + "P")
+	if days := d / day; days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * day
+	}
+	if d > 0 {
+		b.WriteString("T")
+		if h := d / time.Hour; h > 0 {
+			fmt.Fprintf(&b, "%dH", h)
+			d -= h * time.Hour
+		}
+		if m := d / time.Minute; m > 0 {
+			fmt.Fprintf(&b, "%dM", m)
+			d -= m * time.Minute
+		}
+		if s := d / time.Second; s > 0 {
+			fmt.Fprintf(&b, "%dS", s)
+		}
+	}
+	return b.String()
+}
+
+// TimeZone returns the time zone of the event's DTSTART, or UTC.
+func (e Event) TimeZone() *time.Location {
+	if e.TZID != "" {
+		if loc, err := time.LoadLocation(e.TZID); err == nil {
+			return loc
+		}
+	}
+	return time.UTC
+}
+
+// IsAllDay reports whether the event starts on a date rather than a date-time.
+func (e Event) IsAllDay() bool {
+	_, allDay, err := ParseDateTime(e.Start, nil)
+	return err == nil && allDay
+}
+
+// StartTime returns the parsed DTSTART in the event's time zone.
+func (e Event) StartTime() (time.Time, error) {
+	loc := e.TimeZone()
+	t, _, err := ParseDateTime(e.Start, loc)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return t.In(loc), nil
+}
+
+// EndTime returns the end of the event, derived from DTEND or DURATION.
+// Without either, date events last one day and date-time events are instantaneous,
+// as specified by RFC 5545 §3.6.1.
+func (e Event) EndTime() (time.Time, error) {
+	start, err := e.StartTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if e.End != "" {
+		end, _, err := ParseDateTime(e.End, e.TimeZone())
+		if err != nil {
+			return time.Time{}, err
+		}
+		return end.In(start.Location()), nil
+	}
+	if e.Duration != "" {
+		d, err := ParseDuration(e.Duration)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return addDuration(start, d), nil
+	}
+	if e.IsAllDay() {
+		return start.AddDate(0, 0, 1), nil
+	}
+	return start, nil
+}
+
+// addDuration adds d to t, using calendar days for whole-day durations so that
+// nominal durations keep their wall-clock time across DST transitions.
+func addDuration(t time.Time, d time.Duration) time.Time {
+	const day = 24 * time.Hour
+	days := d / day
+	return t.AddDate(0, 0, int(days)).Add(d - days*day)
+}
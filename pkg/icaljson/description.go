@@ -0,0 +1,638 @@
+package icaljson
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Description formats accepted by FormattedDescription.
+const (
+	DescriptionText     = "text"
+	DescriptionHTML     = "html"
+	DescriptionMarkdown = "markdown"
+)
+
+// DescriptionFormats lists the formats accepted by FormattedDescription.
+var DescriptionFormats = []string{DescriptionText, DescriptionHTML, DescriptionMarkdown}
+
+// FormattedDescription returns the event's description as plain text,
+// sanitised HTML or Markdown. The rich X-ALT-DESC (or an ALTREP data URI) is
+// preferred for HTML and Markdown, DESCRIPTION for plain text; the other one
+// is converted when only one is present. Meeting boilerplate inserted by
+// Outlook, Teams and Google Calendar is removed.
+func (e Event) FormattedDescription(format string) (string, error) {
+	switch format {
+	case DescriptionText:
+		if e.Description == "" && e.DescriptionHTML != "" {
+			return HTMLToText(e.DescriptionHTML), nil
+		}
+		return StripBoilerplate(e.Description), nil
+	case DescriptionHTML:
+		if e.DescriptionHTML != "" {
+			return SanitizeHTML(e.DescriptionHTML), nil
+		}
+		return textToHTML(StripBoilerplate(e.Description)), nil
+	case DescriptionMarkdown:
+		if e.DescriptionHTML != "" {
+			return HTMLToMarkdown(e.DescriptionHTML), nil
+		}
+		return StripBoilerplate(e.Description), nil
+	}
+	return "", AppError{Message: "unsupported description format", Value: format, Code: CodeInvalidArgument}
+}
+
+// ApplyDescriptionFormat replaces the description of every event and task by
+// its formatted version and drops the then redundant DescriptionHTML.
+func ApplyDescriptionFormat(calendar *Calendar, format string) error {
+	apply := func(e *Event) error {
+		description, err := e.FormattedDescription(format)
+		if err != nil {
+			return err
+		}
+		e.Description = description
+		e.DescriptionHTML = ""
+		return nil
+	}
+	for i := range calendar.Events {
+		if err := apply(&calendar.Events[i]); err != nil {
+			return err
+		}
+	}
+	for i := range calendar.Todos {
+		if err := apply(&calendar.Todos[i].Event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SanitizeHTML keeps a safe subset of HTML: text formatting, paragraphs,
+// lists, tables, headings and links or images with http(s), mailto or tel
+// URLs. Scripts, styles, attributes, Office markup and meeting boilerplate are
+// removed.
+func SanitizeHTML(s string) string {
+	body := cleanHTML(s)
+	var b strings.Builder
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(&b, c); err != nil {
+			return ""
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// HTMLToMarkdown converts an HTML description to Markdown, keeping links,
+// emphasis, headings, lists, quotes, code and tables.
+func HTMLToMarkdown(s string) string {
+	c := &htmlConverter{}
+	return StripBoilerplate(normalizeConverted(c.render(cleanHTML(s))))
+}
+
+// HTMLToText converts an HTML description to plain text. Link targets are
+// given in parentheses after the link text.
+func HTMLToText(s string) string {
+	c := &htmlConverter{plain: true}
+	return StripBoilerplate(normalizeConverted(c.render(cleanHTML(s))))
+}
+
+// Boilerplate detection. Outlook puts "When/Where" headers above a line of
+// "*~*~*~" in forwarded invitations; Teams, Skype and Zoom put their join
+// instructions between lines of underscores, Google Calendar between
+// "-::~:~::~" lines.
+var (
+	headerSeparator  = regexp.MustCompile(`^(\\?\*~){4,}\\?\*?$`)
+	blockSeparator   = regexp.MustCompile(`^((\\?_){20,}|-::~[:~]+::-)$`)
+	boilerplateHints = []string{
+		"microsoft teams", "teams.microsoft.com", "skype meeting", "join zoom meeting", "zoom.us/j/",
+		"google meet", "meet.google.com", "do not edit this section",
+	}
+)
+
+func isBoilerplate(text string) bool {
+	text = strings.ToLower(text)
+	for _, hint := range boilerplateHints {
+		if strings.Contains(text, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// StripBoilerplate removes meeting join instructions and forwarded-invitation
+// headers from a plain-text or Markdown description.
+func StripBoilerplate(text string) string {
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		if headerSeparator.MatchString(strings.TrimSpace(lines[i])) {
+			lines = lines[i+1:]
+			break
+		}
+	}
+
+	var kept []string
+	for i := 0; i < len(lines); i++ {
+		if !blockSeparator.MatchString(strings.TrimSpace(lines[i])) {
+			kept = append(kept, lines[i])
+			continue
+		}
+		end := len(lines) - 1
+		for j := i + 1; j < len(lines); j++ {
+			if blockSeparator.MatchString(strings.TrimSpace(lines[j])) {
+				end = j
+				break
+			}
+		}
+		if !isBoilerplate(strings.Join(lines[i:end+1], "\n")) {
+			kept = append(kept, lines[i])
+			continue
+		}
+		i = end
+	}
+	return normalizeConverted(strings.Join(kept, "\n"))
+}
+
+// textToHTML renders plain text as paragraphs with line breaks.
+func textToHTML(text string) string {
+	if text == "" {
+		return ""
+	}
+	var b strings.Builder
+	for _, paragraph := range blankLines.Split(text, -1) {
+		lines := strings.Split(strings.TrimSpace(paragraph), "\n")
+		for i, line := range lines {
+			lines[i] = html.EscapeString(line)
+		}
+		b.WriteString("<p>" + strings.Join(lines, "<br/>") + "</p>")
+	}
+	return b.String()
+}
+
+// Sanitizer rules.
+var (
+	// droppedElements are removed together with their content.
+	droppedElements = []atom.Atom{
+		atom.Head, atom.Script, atom.Style, atom.Title, atom.Meta, atom.Link, atom.Object, atom.Iframe,
+		atom.Embed, atom.Noscript, atom.Template, atom.Svg, atom.Math, atom.Form, atom.Input,
+		atom.Button, atom.Select, atom.Textarea,
+	}
+	// allowedElements are kept with the listed attributes; all other elements
+	// are replaced by their content.
+	allowedElements = map[atom.Atom][]string{
+		atom.A: {"href", "title"}, atom.Img: {"src", "alt", "title"},
+		atom.B: nil, atom.Strong: nil, atom.I: nil, atom.Em: nil, atom.U: nil, atom.S: nil, atom.Del: nil,
+		atom.Ins: nil, atom.Sub: nil, atom.Sup: nil, atom.P: nil, atom.Br: nil, atom.Div: nil,
+		atom.Ul: nil, atom.Ol: nil, atom.Li: nil, atom.H1: nil, atom.H2: nil, atom.H3: nil, atom.H4: nil,
+		atom.H5: nil, atom.H6: nil, atom.Blockquote: nil, atom.Pre: nil, atom.Code: nil, atom.Hr: nil,
+		atom.Table: nil, atom.Thead: nil, atom.Tbody: nil, atom.Tfoot: nil, atom.Tr: nil,
+		atom.Th: {"colspan", "rowspan"}, atom.Td: {"colspan", "rowspan"}, atom.Caption: nil,
+	}
+	// emptyRemovable elements are removed when they hold no text.
+	emptyRemovable = []atom.Atom{atom.P, atom.Div, atom.B, atom.Strong, atom.I, atom.Em, atom.U, atom.A, atom.Li}
+)
+
+// cleanHTML parses s and returns its sanitised body element.
+func cleanHTML(s string) *html.Node {
+	doc, err := html.Parse(strings.NewReader(s))
+	body := &html.Node{Type: html.ElementNode, Data: "body", DataAtom: atom.Body}
+	if err != nil {
+		body.AppendChild(&html.Node{Type: html.TextNode, Data: s})
+		return body
+	}
+	if found := findElement(doc, atom.Body); found != nil {
+		body = found
+	}
+	sanitizeChildren(body)
+	stripBoilerplateNodes(body)
+	pruneEmpty(body)
+	return body
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func sanitizeChildren(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		switch c.Type {
+		case html.TextNode:
+		case html.ElementNode:
+			if slices.Contains(droppedElements, c.DataAtom) {
+				n.RemoveChild(c)
+				break
+			}
+			sanitizeChildren(c)
+			attrs, ok := allowedElements[c.DataAtom]
+			if !ok || c.DataAtom == 0 {
+				// Unknown elements such as <span>, <font> and Office's <o:p>
+				unwrap(c)
+				break
+			}
+			c.Attr = sanitizeAttrs(c, attrs)
+			switch {
+			case c.DataAtom == atom.Img && attrValue(c, "src") == "":
+				n.RemoveChild(c)
+			case c.DataAtom == atom.A && attrValue(c, "href") == "":
+				unwrap(c)
+			}
+		default:
+			// Comments, including Outlook's conditional comments
+			n.RemoveChild(c)
+		}
+		c = next
+	}
+}
+
+// unwrap replaces n by its children.
+func unwrap(n *html.Node) {
+	parent := n.Parent
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		n.RemoveChild(c)
+		parent.InsertBefore(c, n)
+		c = next
+	}
+	parent.RemoveChild(n)
+}
+
+func sanitizeAttrs(n *html.Node, allowed []string) []html.Attribute {
+	var attrs []html.Attribute
+	for _, attr := range n.Attr {
+		if attr.Namespace != "" || !slices.Contains(allowed, attr.Key) {
+			continue
+		}
+		if (attr.Key == "href" || attr.Key == "src") && !safeURL(attr.Val, attr.Key == "src") {
+			continue
+		}
+		attrs = append(attrs, html.Attribute{Key: attr.Key, Val: attr.Val})
+	}
+	if n.DataAtom == atom.A && attrValue(&html.Node{Attr: attrs}, "href") != "" {
+		attrs = append(attrs, html.Attribute{Key: "rel", Val: "noopener noreferrer"})
+	}
+	return attrs
+}
+
+// safeURL accepts http(s) URLs, and for links also mailto and tel.
+func safeURL(raw string, image bool) bool {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+		return u.Host != ""
+	case "mailto", "tel":
+		return !image
+	}
+	return false
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// nodeText returns the text content of n with non-breaking spaces as spaces.
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return strings.TrimSpace(strings.ReplaceAll(b.String(), "\u00a0", " "))
+}
+
+// stripBoilerplateNodes is StripBoilerplate for sibling elements.
+func stripBoilerplateNodes(n *html.Node) {
+	var children []*html.Node
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		children = append(children, c)
+	}
+	for i := len(children) - 1; i >= 0; i-- {
+		if headerSeparator.MatchString(nodeText(children[i])) {
+			for _, c := range children[:i+1] {
+				n.RemoveChild(c)
+			}
+			children = children[i+1:]
+			break
+		}
+	}
+	for i := 0; i < len(children); i++ {
+		if !blockSeparator.MatchString(nodeText(children[i])) {
+			continue
+		}
+		end := len(children) - 1
+		var text []string
+		for j := i; j < len(children); j++ {
+			text = append(text, nodeText(children[j]))
+			if j > i && blockSeparator.MatchString(text[len(text)-1]) {
+				end = j
+				break
+			}
+		}
+		if isBoilerplate(strings.Join(text, "\n")) {
+			for _, c := range children[i : end+1] {
+				n.RemoveChild(c)
+			}
+			i = end
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		stripBoilerplateNodes(c)
+	}
+}
+
+// pruneEmpty removes paragraphs and formatting elements without text, such as
+// Outlook's <p class=MsoNormal>&nbsp;</p>.
+func pruneEmpty(n *html.Node) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == html.ElementNode {
+			pruneEmpty(c)
+			if slices.Contains(emptyRemovable, c.DataAtom) && nodeText(c) == "" && findElement(c, atom.Img) == nil {
+				n.RemoveChild(c)
+			}
+		}
+		c = next
+	}
+}
+
+// htmlConverter renders a sanitised tree as Markdown or plain text.
+type htmlConverter struct {
+	plain bool
+	pre   bool
+}
+
+var (
+	whitespace      = regexp.MustCompile(`[ \t\r\n\f]+`)
+	blankLines      = regexp.MustCompile(`\n{2,}`)
+	markdownEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `_`, `\_`, "`", "\\`", `[`, `\[`, `]`, `\]`)
+)
+
+func (c *htmlConverter) render(n *html.Node) string {
+	var b strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		s := c.renderNode(child)
+		if child.Type == html.TextNode && !c.pre {
+			if out := b.String(); out == "" || strings.HasSuffix(out, "\n") || strings.HasSuffix(out, " ") {
+				s = strings.TrimLeft(s, " ")
+			}
+		}
+		b.WriteString(s)
+	}
+	return b.String()
+}
+
+func (c *htmlConverter) renderNode(n *html.Node) string {
+	if n.Type == html.TextNode {
+		text := strings.ReplaceAll(n.Data, "\u00a0", " ")
+		if c.pre {
+			return text
+		}
+		text = whitespace.ReplaceAllString(text, " ")
+		if !c.plain {
+			text = markdownEscaper.Replace(text)
+		}
+		return text
+	}
+	if n.Type != html.ElementNode {
+		return ""
+	}
+
+	block := func(s string) string {
+		if s = strings.TrimSpace(s); s == "" {
+			return ""
+		}
+		return "\n\n" + s + "\n\n"
+	}
+	inline := func(marker string) string {
+		s := c.render(n)
+		trimmed := strings.TrimSpace(s)
+		if c.plain || trimmed == "" {
+			return s
+		}
+		// Keep surrounding spaces outside the markers
+		lead := s[:len(s)-len(strings.TrimLeft(s, " "))]
+		trail := s[len(strings.TrimRight(s, " ")):]
+		return lead + marker + trimmed + marker + trail
+	}
+
+	switch n.DataAtom {
+	case atom.Br:
+		if c.plain || c.pre {
+			return "\n"
+		}
+		return "\\\n"
+	case atom.Hr:
+		return "\n\n---\n\n"
+	case atom.P, atom.Div, atom.Caption:
+		return block(c.render(n))
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		text := strings.TrimSpace(whitespace.ReplaceAllString(c.render(n), " "))
+		if c.plain || text == "" {
+			return block(text)
+		}
+		level := int(n.Data[1] - '0')
+		return block(strings.Repeat("#", level) + " " + text)
+	case atom.B, atom.Strong:
+		return inline("**")
+	case atom.I, atom.Em:
+		return inline("_")
+	case atom.S, atom.Del:
+		return inline("~~")
+	case atom.Code:
+		if c.pre {
+			return c.render(n)
+		}
+		if c.plain {
+			return nodeText(n)
+		}
+		return "`" + strings.ReplaceAll(nodeText(n), "`", "") + "`"
+	case atom.Pre:
+		c.pre = true
+		text := strings.Trim(c.render(n), "\n")
+		c.pre = false
+		if c.plain {
+			return block(text)
+		}
+		return "\n\n```\n" + text + "\n```\n\n"
+	case atom.A:
+		return c.renderLink(n)
+	case atom.Img:
+		alt := attrValue(n, "alt")
+		if c.plain {
+			return alt
+		}
+		return fmt.Sprintf("![%s](%s)", markdownEscaper.Replace(alt), attrValue(n, "src"))
+	case atom.Ul, atom.Ol:
+		return c.renderList(n)
+	case atom.Blockquote:
+		text := strings.TrimSpace(normalizeConverted(c.render(n)))
+		if text == "" {
+			return ""
+		}
+		return block("> " + strings.ReplaceAll(text, "\n", "\n> "))
+	case atom.Table:
+		return c.renderTable(n)
+	}
+	return c.render(n)
+}
+
+func (c *htmlConverter) renderLink(n *html.Node) string {
+	text := strings.TrimSpace(c.render(n))
+	href := attrValue(n, "href")
+	if href == "" {
+		return text
+	}
+	if c.plain {
+		target := strings.TrimPrefix(strings.TrimPrefix(href, "mailto:"), "tel:")
+		if text == "" || text == href || text == target {
+			return target
+		}
+		return fmt.Sprintf("%s (%s)", text, target)
+	}
+	if text == "" || text == markdownEscaper.Replace(href) {
+		return "<" + href + ">"
+	}
+	return fmt.Sprintf("[%s](%s)", text, strings.ReplaceAll(href, ")", "%29"))
+}
+
+func (c *htmlConverter) renderList(n *html.Node) string {
+	var items []string
+	number := 1
+	for li := n.FirstChild; li != nil; li = li.NextSibling {
+		if li.Type != html.ElementNode || li.DataAtom != atom.Li {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		// Keep lists tight, also around nested lists
+		text := blankLines.ReplaceAllString(strings.TrimSpace(normalizeConverted(c.render(li))), "\n")
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.ReplaceAll(text, "\n", "\n"+indent))
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	return "\n\n" + strings.Join(items, "\n") + "\n\n"
+}
+
+func (c *htmlConverter) renderTable(n *html.Node) string {
+	var rows [][]string
+	header := false
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			if child.DataAtom != atom.Tr {
+				walk(child)
+				continue
+			}
+			var cells []string
+			for cell := child.FirstChild; cell != nil; cell = cell.NextSibling {
+				if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+					header = header || (len(rows) == 0 && cell.DataAtom == atom.Th)
+					text := strings.TrimSpace(whitespace.ReplaceAllString(c.render(cell), " "))
+					cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+				}
+			}
+			if len(cells) > 0 {
+				rows = append(rows, cells)
+			}
+		}
+	}
+	walk(n)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	var lines []string
+	for i, cells := range rows {
+		if c.plain {
+			lines = append(lines, strings.Join(cells, " | "))
+			continue
+		}
+		lines = append(lines, "| "+strings.Join(cells, " | ")+" |")
+		if i == 0 {
+			separator := strings.Repeat("| --- ", len(cells)) + "|"
+			if !header {
+				// Markdown tables need a header row; use an empty one
+				lines = []string{strings.Repeat("|  ", len(cells)) + "|", separator, lines[0]}
+				continue
+			}
+			lines = append(lines, separator)
+		}
+	}
+	return "\n\n" + strings.Join(lines, "\n") + "\n\n"
+}
+
+// normalizeConverted trims trailing whitespace on every line and collapses
+// runs of blank lines.
+func normalizeConverted(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLines.ReplaceAllString(s, "\n\n")
+	return strings.Trim(s, "\n")
+}
+
+// decodeDataURI returns the content of a data: URI such as
+// "data:text/html;base64,PGI+aGk8L2I+" together with its media type.
+func decodeDataURI(uri string) (string, string, bool) {
+	rest, ok := strings.CutPrefix(uri, "data:")
+	if !ok {
+		if rest, ok = strings.CutPrefix(uri, "DATA:"); !ok {
+			return "", "", false
+		}
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok {
+		return "", "", false
+	}
+	mediaType, isBase64 := strings.CutSuffix(meta, ";base64")
+	mediaType, _, _ = strings.Cut(mediaType, ";")
+	if isBase64 {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return "", "", false
+		}
+		return string(decoded), strings.ToLower(mediaType), true
+	}
+	decoded, err := url.PathUnescape(data)
+	if err != nil {
+		return "", "", false
+	}
+	return decoded, strings.ToLower(mediaType), true
+}
@@ -0,0 +1,416 @@
+package icaljson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ChangeKind classifies an event change.
+type ChangeKind string
+
+const (
+	ChangeAdded    ChangeKind = "added"
+	ChangeRemoved  ChangeKind = "removed"
+	ChangeModified ChangeKind = "modified"
+)
+
+// FieldChange is a change of a single property, addressed by its JSON name.
+// Old or New is nil when the property was added or removed.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   any    `json:"old,omitempty"`
+	New   any    `json:"new,omitempty"`
+}
+
+// ComponentChange describes an added, removed or modified component.
+type ComponentChange[T any] struct {
+	Kind         ChangeKind    `json:"kind"`
+	UID          string        `json:"uid,omitempty"`
+	RecurrenceID string        `json:"recurrence_id,omitempty"`
+	Summary      string        `json:"summary,omitempty"`
+	Fields       []FieldChange `json:"fields,omitempty"`
+	Old          *T            `json:"old,omitempty"`
+	New          *T            `json:"new,omitempty"`
+
+	oldIndex int // position in the old calendar, for JSON Patch paths
+}
+
+// EventChange describes an added, removed or modified event.
+type EventChange = ComponentChange[Event]
+
+// TodoChange describes an added, removed or modified task.
+type TodoChange = ComponentChange[Todo]
+
+// AvailabilityChange describes an added, removed or modified VAVAILABILITY.
+type AvailabilityChange = ComponentChange[Availability]
+
+// CalendarDiff is the difference between two versions of a calendar.
+type CalendarDiff struct {
+	Calendar []FieldChange `json:"calendar,omitempty"`
+	Events   []EventChange `json:"events"`
+	Todos    []TodoChange  `json:"todos,omitempty"`
+
+	Availability []AvailabilityChange `json:"availability,omitempty"`
+
+	oldEvents       int
+	oldTodos        int
+	oldAvailability int
+}
+
+// PatchOperation is an RFC 6902 JSON Patch operation.
+type PatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value,omitempty"`
+}
+
+// MarshalJSON always includes the value of add and replace operations,
+// even when it is a zero value.
+func (op PatchOperation) MarshalJSON() ([]byte, error) {
+	if op.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{op.Op, op.Path})
+	}
+	return json.Marshal(struct {
+		Op    string `json:"op"`
+		Path  string `json:"path"`
+		Value any    `json:"value"`
+	}{op.Op, op.Path, op.Value})
+}
+
+// Diff compares two calendars. Events, tasks and availability components are
+// matched by UID plus RECURRENCE-ID (those without UID by summary and start);
+// field changes are reported by JSON name. The "source" field is ignored.
+func Diff(oldCal, newCal *Calendar) (*CalendarDiff, error) {
+	result := &CalendarDiff{
+		oldEvents:       len(oldCal.Events),
+		oldTodos:        len(oldCal.Todos),
+		oldAvailability: len(oldCal.Availability),
+	}
+
+	oldHeader, err := calendarHeader(oldCal)
+	if err != nil {
+		return nil, err
+	}
+	newHeader, err := calendarHeader(newCal)
+	if err != nil {
+		return nil, err
+	}
+	result.Calendar = diffFields(oldHeader, newHeader)
+
+	if result.Events, err = diffComponents(oldCal.Events, newCal.Events, func(e *Event) *Event { return e }); err != nil {
+		return nil, err
+	}
+	if result.Todos, err = diffComponents(oldCal.Todos, newCal.Todos, func(t *Todo) *Event { return &t.Event }); err != nil {
+		return nil, err
+	}
+	if result.Availability, err = diffComponents(oldCal.Availability, newCal.Availability, func(a *Availability) *Event { return &a.Event }); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// diffComponents matches the components of two calendars by diffKey of their
+// event properties and reports the added, removed and modified ones.
+func diffComponents[T any](oldItems, newItems []T, event func(*T) *Event) ([]ComponentChange[T], error) {
+	changes := []ComponentChange[T]{}
+
+	newByKey := map[string][]int{}
+	for i := range newItems {
+		key := diffKey(*event(&newItems[i]))
+		newByKey[key] = append(newByKey[key], i)
+	}
+
+	matched := make([]bool, len(newItems))
+	for i := range oldItems {
+		before := oldItems[i]
+		oldEvent := event(&before)
+		key := diffKey(*oldEvent)
+		candidates := newByKey[key]
+		if len(candidates) == 0 {
+			changes = append(changes, ComponentChange[T]{
+				Kind: ChangeRemoved, UID: oldEvent.UID, RecurrenceID: oldEvent.RecurrenceID,
+				Summary: oldEvent.Summary, Old: &before, oldIndex: i,
+			})
+			continue
+		}
+		j := candidates[0]
+		newByKey[key] = candidates[1:]
+		matched[j] = true
+
+		oldFields, err := componentFields(before)
+		if err != nil {
+			return nil, err
+		}
+		newFields, err := componentFields(newItems[j])
+		if err != nil {
+			return nil, err
+		}
+		if fields := diffFields(oldFields, newFields); len(fields) > 0 {
+			after := newItems[j]
+			newEvent := event(&after)
+			changes = append(changes, ComponentChange[T]{
+				Kind: ChangeModified, UID: newEvent.UID, RecurrenceID: newEvent.RecurrenceID,
+				Summary: newEvent.Summary, Fields: fields, Old: &before, New: &after,
+				oldIndex: i,
+			})
+		}
+	}
+
+	for j := range newItems {
+		if matched[j] {
+			continue
+		}
+		added := newItems[j]
+		newEvent := event(&added)
+		changes = append(changes, ComponentChange[T]{
+			Kind: ChangeAdded, UID: newEvent.UID, RecurrenceID: newEvent.RecurrenceID,
+			Summary: newEvent.Summary, New: &added, oldIndex: -1,
+		})
+	}
+
+	return changes, nil
+}
+
+// HasChanges reports whether the calendars differ.
+func (d *CalendarDiff) HasChanges() bool {
+	return len(d.Calendar) > 0 || len(d.Events) > 0 || len(d.Todos) > 0 || len(d.Availability) > 0
+}
+
+// Count returns the number of component changes of the given kind.
+func (d *CalendarDiff) Count(kind ChangeKind) int {
+	n := 0
+	for _, c := range d.Events {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	for _, c := range d.Todos {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	for _, c := range d.Availability {
+		if c.Kind == kind {
+			n++
+		}
+	}
+	return n
+}
+
+// JSONPatch returns RFC 6902 operations transforming the JSON document of the
+// old calendar (as written by WriteJSON) into the new one, up to the order of
+// its components.
+func (d *CalendarDiff) JSONPatch() []PatchOperation {
+	ops := []PatchOperation{}
+
+	for _, change := range d.Calendar {
+		ops = append(ops, fieldPatch("", change))
+	}
+	ops = componentPatch(ops, "events", d.Events, d.oldEvents)
+	ops = componentPatch(ops, "todos", d.Todos, d.oldTodos)
+	ops = componentPatch(ops, "availability", d.Availability, d.oldAvailability)
+
+	return ops
+}
+
+// componentPatch appends the operations for the changes of the calendar
+// member name, which holds oldCount components in the old document.
+func componentPatch[T any](ops []PatchOperation, name string, changes []ComponentChange[T], oldCount int) []PatchOperation {
+	var removed []int
+	var added []any
+	for _, change := range changes {
+		switch change.Kind {
+		case ChangeModified:
+			prefix := "/" + name + "/" + strconv.Itoa(change.oldIndex)
+			for _, field := range change.Fields {
+				ops = append(ops, fieldPatch(prefix, field))
+			}
+		case ChangeRemoved:
+			removed = append(removed, change.oldIndex)
+		case ChangeAdded:
+			added = append(added, change.New)
+		}
+	}
+
+	// Remove from the end so earlier indices stay valid
+	sort.Sort(sort.Reverse(sort.IntSlice(removed)))
+	for _, i := range removed {
+		ops = append(ops, PatchOperation{Op: "remove", Path: "/" + name + "/" + strconv.Itoa(i)})
+	}
+
+	if len(added) > 0 {
+		if oldCount == 0 {
+			// The old document has no member to append to
+			ops = append(ops, PatchOperation{Op: "add", Path: "/" + name, Value: added})
+		} else {
+			for _, c := range added {
+				ops = append(ops, PatchOperation{Op: "add", Path: "/" + name + "/-", Value: c})
+			}
+		}
+	}
+
+	return ops
+}
+
+// WriteText writes a human-readable summary of the changes.
+func (d *CalendarDiff) WriteText(w io.Writer) error {
+	if !d.HasChanges() {
+		_, err := fmt.Fprintln(w, "No changes.")
+		return err
+	}
+
+	for _, change := range d.Calendar {
+		if _, err := fmt.Fprintf(w, "~ calendar %s\n", formatFieldChange(change)); err != nil {
+			return err
+		}
+	}
+
+	if err := writeChanges(w, "", d.Events); err != nil {
+		return err
+	}
+	if err := writeChanges(w, "task ", d.Todos); err != nil {
+		return err
+	}
+	if err := writeChanges(w, "availability ", d.Availability); err != nil {
+		return err
+	}
+
+	_, err := fmt.Fprintf(w, "\n%d added, %d removed, %d modified\n",
+		d.Count(ChangeAdded), d.Count(ChangeRemoved), d.Count(ChangeModified))
+	return err
+}
+
+// writeChanges writes one line per change, followed by the modified fields,
+// with labels starting with prefix.
+func writeChanges[T any](w io.Writer, prefix string, changes []ComponentChange[T]) error {
+	for _, change := range changes {
+		label := prefix + change.UID
+		if change.RecurrenceID != "" {
+			label += " @ " + change.RecurrenceID
+		}
+		if change.Summary != "" {
+			label += fmt.Sprintf(" (%s)", change.Summary)
+		}
+
+		var err error
+		switch change.Kind {
+		case ChangeAdded:
+			_, err = fmt.Fprintf(w, "+ %s\n", label)
+		case ChangeRemoved:
+			_, err = fmt.Fprintf(w, "- %s\n", label)
+		case ChangeModified:
+			_, err = fmt.Fprintf(w, "~ %s\n", label)
+			for _, field := range change.Fields {
+				if err == nil {
+					_, err = fmt.Fprintf(w, "    %s\n", formatFieldChange(field))
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFieldChange(c FieldChange) string {
+	return fmt.Sprintf("%s: %s → %s", c.Field, formatDiffValue(c.Old), formatDiffValue(c.New))
+}
+
+func formatDiffValue(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return "∅"
+	case string:
+		return strconv.Quote(x)
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func fieldPatch(prefix string, change FieldChange) PatchOperation {
+	path := prefix + "/" + escapePointer(change.Field)
+	switch {
+	case change.Old == nil:
+		return PatchOperation{Op: "add", Path: path, Value: change.New}
+	case change.New == nil:
+		return PatchOperation{Op: "remove", Path: path}
+	}
+	return PatchOperation{Op: "replace", Path: path, Value: change.New}
+}
+
+// escapePointer escapes a JSON Pointer reference token (RFC 6901).
+func escapePointer(token string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(token)
+}
+
+func diffKey(e Event) string {
+	if e.UID == "" {
+		return "\x00" + e.Summary + "\x00" + e.Start
+	}
+	return e.UID + "\x00" + e.RecurrenceID
+}
+
+// toJSONMap returns the JSON object representation of v.
+func toJSONMap(v any) (map[string]any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, AppError{Message: "failed to marshal JSON", Value: err, Code: CodeInternal}
+	}
+	fields := map[string]any{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, AppError{Message: "failed to unmarshal JSON", Value: err, Code: CodeInternal}
+	}
+	return fields, nil
+}
+
+// componentFields returns the JSON object of an event or task without its source.
+func componentFields(v any) (map[string]any, error) {
+	fields, err := toJSONMap(v)
+	delete(fields, "source")
+	return fields, err
+}
+
+func calendarHeader(c *Calendar) (map[string]any, error) {
+	fields, err := toJSONMap(c)
+	delete(fields, "events")
+	delete(fields, "todos")
+	delete(fields, "availability")
+	delete(fields, "source")
+	return fields, err
+}
+
+// diffFields compares two JSON objects, reporting changes in sorted field order.
+func diffFields(before, after map[string]any) []FieldChange {
+	names := map[string]bool{}
+	for k := range before {
+		names[k] = true
+	}
+	for k := range after {
+		names[k] = true
+	}
+	sorted := make([]string, 0, len(names))
+	for k := range names {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	var changes []FieldChange
+	for _, name := range sorted {
+		if !reflect.DeepEqual(before[name], after[name]) {
+			changes = append(changes, FieldChange{Field: name, Old: before[name], New: after[name]})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,143 @@
+package icaljson
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/htmlindex"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+// Encoding names reported by DecodeInput.
+const (
+	EncodingUTF8    = "utf-8"
+	EncodingUTF16LE = "utf-16le"
+	EncodingUTF16BE = "utf-16be"
+	EncodingUTF32LE = "utf-32le"
+	EncodingUTF32BE = "utf-32be"
+)
+
+// fallbackEncoding is assumed for input that is neither UTF-8 nor declares a
+// CHARSET. Windows-1252 is what legacy Outlook and Lotus exports use, and it
+// decodes every byte.
+const fallbackEncoding = "windows-1252"
+
+// byteOrderMarks in detection order; UTF-32LE must be tested before UTF-16LE.
+var byteOrderMarks = []struct {
+	bom  []byte
+	name string
+}{
+	{[]byte{0x00, 0x00, 0xFE, 0xFF}, EncodingUTF32BE},
+	{[]byte{0xFF, 0xFE, 0x00, 0x00}, EncodingUTF32LE},
+	{[]byte{0xEF, 0xBB, 0xBF}, EncodingUTF8},
+	{[]byte{0xFE, 0xFF}, EncodingUTF16BE},
+	{[]byte{0xFF, 0xFE}, EncodingUTF16LE},
+}
+
+// charsetParam finds CHARSET parameters such as "SUMMARY;CHARSET=ISO-8859-1:".
+var charsetParam = regexp.MustCompile(`(?i);CHARSET="?([A-Za-z0-9._:-]+?)"?[;:]`)
+
+// LookupEncoding returns the decoder for an encoding name or label such as
+// "windows-1252", "latin1" or "utf-16le".
+func LookupEncoding(name string) (encoding.Encoding, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case EncodingUTF8, "utf8":
+		return unicode.UTF8, nil
+	case EncodingUTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), nil
+	case EncodingUTF16BE, "utf-16":
+		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM), nil
+	case EncodingUTF32LE:
+		return utf32.UTF32(utf32.LittleEndian, utf32.IgnoreBOM), nil
+	case EncodingUTF32BE, "utf-32":
+		return utf32.UTF32(utf32.BigEndian, utf32.IgnoreBOM), nil
+	case "mac", "macintosh", "macroman":
+		return charmap.Macintosh, nil
+	}
+	enc, err := htmlindex.Get(name)
+	if err != nil {
+		return nil, AppError{Message: "unknown encoding", Value: name, Code: CodeInvalidArgument}
+	}
+	return enc, nil
+}
+
+// DecodeInput converts raw calendar data to UTF-8 and strips any byte order
+// mark. With an empty name the encoding is detected: a BOM wins, then NUL
+// byte patterns of BOM-less UTF-16, then valid UTF-8, then the first CHARSET
+// parameter, and finally Windows-1252. It returns the data and the encoding used.
+func DecodeInput(data []byte, name string) ([]byte, string, error) {
+	for _, m := range byteOrderMarks {
+		if bytes.HasPrefix(data, m.bom) {
+			if name == "" {
+				name = m.name
+			}
+			data = data[len(m.bom):]
+			break
+		}
+	}
+	if name == "" {
+		name = detectEncoding(data)
+	}
+
+	enc, err := LookupEncoding(name)
+	if err != nil {
+		return nil, "", err
+	}
+	name = strings.ToLower(strings.TrimSpace(name))
+	if enc == unicode.UTF8 {
+		return bytes.TrimPrefix(data, byteOrderMarks[2].bom), EncodingUTF8, nil
+	}
+
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, "", AppError{Message: "failed to decode " + name + " input", Value: err, Code: CodeReadFailed}
+	}
+	return bytes.TrimPrefix(decoded, byteOrderMarks[2].bom), name, nil
+}
+
+// unfoldBytes returns data with all folds removed, see Unfold.
+func unfoldBytes(data []byte) []byte {
+	unfolded, _ := io.ReadAll(Unfold(bytes.NewReader(data)))
+	return unfolded
+}
+
+// detectEncoding guesses the encoding of BOM-less data.
+func detectEncoding(data []byte) string {
+	// "BEGIN:" in UTF-16 has a NUL byte next to every ASCII character
+	sample := data[:min(len(data), 64)]
+	var evenNUL, oddNUL int
+	for i, b := range sample {
+		if b == 0 {
+			if i%2 == 0 {
+				evenNUL++
+			} else {
+				oddNUL++
+			}
+		}
+	}
+	if half := len(sample) / 2; half > 0 {
+		switch {
+		case oddNUL > half*3/4 && evenNUL == 0:
+			return EncodingUTF16LE
+		case evenNUL > half*3/4 && oddNUL == 0:
+			return EncodingUTF16BE
+		}
+	}
+
+	// Writers that fold at 75 octets may split UTF-8 sequences
+	if utf8.Valid(data) || utf8.Valid(unfoldBytes(data)) {
+		return EncodingUTF8
+	}
+	if m := charsetParam.FindSubmatch(data); m != nil {
+		if _, err := LookupEncoding(string(m[1])); err == nil {
+			return strings.ToLower(string(m[1]))
+		}
+	}
+	return fallbackEncoding
+}
@@ -1,12 +1,89 @@
 package icaljson
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Every error returned by this package matches one of these with
+// errors.Is; the underlying cause (e.g. fs.ErrNotExist) stays reachable too.
+var (
+	// ErrParse is the parent of all errors about malformed input data.
+	ErrParse = errors.New("invalid input data")
+	// ErrNotICalendar reports input that is not an iCalendar stream at all.
+	ErrNotICalendar = fmt.Errorf("%w: not an iCalendar stream", ErrParse)
+	// ErrInvalidContentLine reports a line that is not NAME;PARAM=VALUE:value.
+	ErrInvalidContentLine = fmt.Errorf("%w: malformed content line", ErrParse)
+	// ErrUnbalancedComponent reports BEGIN and END lines that do not match.
+	ErrUnbalancedComponent = fmt.Errorf("%w: unbalanced BEGIN/END", ErrParse)
+	// ErrInvalidDateTime reports a malformed DATE or DATE-TIME value.
+	ErrInvalidDateTime = fmt.Errorf("%w: invalid date-time", ErrParse)
+	// ErrInvalidValue reports any other malformed property value.
+	ErrInvalidValue = fmt.Errorf("%w: invalid property value", ErrParse)
+	// ErrInvalidJSON reports a JSON document that is not a calendar.
+	ErrInvalidJSON = fmt.Errorf("%w: invalid JSON document", ErrParse)
+
+	// ErrRead reports a failure to open or read input.
+	ErrRead = errors.New("read failed")
+	// ErrWrite reports a failure to encode or write output.
+	ErrWrite = errors.New("write failed")
+	// ErrFetch reports a failure to download a calendar feed.
+	ErrFetch = errors.New("fetch failed")
+	// ErrInvalidArgument reports invalid options, queries or paths.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrConflict reports conflicting data, e.g. a merge with MergeFailOnConflict.
+	ErrConflict = errors.New("conflict")
+)
+
+// Error codes. They are stable and safe to match on; parse errors use the
+// diagnostic codes of ValidationRules (e.g. CodeNotICalendar).
+const (
+	CodeReadFailed      = "READ_FAILED"
+	CodeWriteFailed     = "WRITE_FAILED"
+	CodeFetchFailed     = "FETCH_FAILED"
+	CodeInvalidArgument = "INVALID_ARGUMENT"
+	CodeInvalidQuery    = "INVALID_QUERY"
+	CodeInvalidJSON     = "INVALID_JSON"
+	CodeMergeConflict   = "MERGE_CONFLICT"
+	CodeInternal        = "INTERNAL"
+)
+
+// errorKinds maps error codes to their sentinel error.
+var errorKinds = map[string]error{
+	CodeNotICalendar:        ErrNotICalendar,
+	CodeInvalidContentLine:  ErrInvalidContentLine,
+	CodeUnbalancedComponent: ErrUnbalancedComponent,
+	CodeInvalidDateTime:     ErrInvalidDateTime,
+	CodeInvalidJSON:         ErrInvalidJSON,
+	CodeReadFailed:          ErrRead,
+	CodeWriteFailed:         ErrWrite,
+	CodeFetchFailed:         ErrFetch,
+	CodeInvalidArgument:     ErrInvalidArgument,
+	CodeInvalidQuery:        ErrInvalidArgument,
+	CodeMergeConflict:       ErrConflict,
+}
+
+// errorKind returns the sentinel for an error code. Validation codes without
+// a dedicated sentinel are reported as ErrInvalidValue.
+func errorKind(code string) error {
+	if kind, ok := errorKinds[code]; ok {
+		return kind
+	}
+	for _, rule := range ValidationRules {
+		if rule.Code == code {
+			return ErrInvalidValue
+		}
+	}
+	return nil
+}
 
 type AppError struct {
 	// Message to show the user.
 	Message string
 	// Value to include with message
 	Value any
+	// Code is a stable identifier of the kind of error
+	Code string
 }
 
 func (e AppError) Error() string {
@@ -16,3 +93,46 @@ func (e AppError) Error() string {
 		return e.Message
 	}
 }
+
+// Unwrap returns the sentinel for the error code and, if Value is an error,
+// the underlying cause.
+func (e AppError) Unwrap() []error {
+	var errs []error
+	if kind := errorKind(e.Code); kind != nil {
+		errs = append(errs, kind)
+	}
+	if err, ok := e.Value.(error); ok {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// ErrorCode returns the code of the first AppError or ParseError in err's
+// chain, or "" if there is none.
+func ErrorCode(err error) string {
+	var perr *ParseError
+	if errors.As(err, &perr) {
+		return perr.Code
+	}
+	var aerr AppError
+	if errors.As(err, &aerr) {
+		return aerr.Code
+	}
+	return ""
+}
+
+// ParseError is a structural violation found while parsing, see ParseOptions.
+// Use errors.As to get the position, or errors.Is with a sentinel such as
+// ErrUnbalancedComponent to test for a kind of violation.
+type ParseError struct {
+	AppError
+	Line   int // 1-based physical line
+	Column int // 1-based byte offset within the unfolded line, 0 if unknown
+}
+
+func (e *ParseError) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.AppError.Error())
+	}
+	return fmt.Sprintf("line %d: %s", e.Line, e.AppError.Error())
+}
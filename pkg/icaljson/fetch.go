@@ -0,0 +1,350 @@
+package icaljson
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/beyondcivic/icaljson/pkg/version"
+)
+
+// Default limits used by Fetch when FetchOptions leaves them unset.
+const (
+	DefaultFetchTimeout = 30 * time.Second
+	DefaultMaxBodySize  = 20 << 20 // 20 MiB
+	DefaultMaxRedirects = 10
+)
+
+// FetchOptions configures how calendar feeds are downloaded.
+type FetchOptions struct {
+	// Client used for requests. Defaults to a client honouring Timeout and MaxRedirects.
+	// Tests can inject the client of an httptest.Server here.
+	Client *http.Client
+	// CacheDir stores feed bodies and validators (ETag, Last-Modified).
+	// Conditional requests are disabled when empty.
+	CacheDir string
+	// Timeout for the whole request, including reading the body.
+	Timeout time.Duration
+	// MaxBodySize is the maximum number of (decompressed) bytes accepted.
+	MaxBodySize int64
+	// MaxRedirects is the maximum number of redirects followed.
+	MaxRedirects int
+	// WebcalScheme is the scheme webcal:// URLs are rewritten to (http or https).
+	WebcalScheme string
+	// DeferCache leaves storing a downloaded feed in CacheDir to
+	// FetchResult.StoreCache, so that a caller can cache the feed once it has
+	// been processed successfully rather than get a 304 after a failed run.
+	DeferCache bool
+}
+
+// FetchResult describes a downloaded calendar feed.
+type FetchResult struct {
+	// URL that was requested, after webcal:// rewriting.
+	URL string
+	// FinalURL after following redirects.
+	FinalURL string
+	// StatusCode of the final response.
+	StatusCode int
+	// NotModified is true when the server answered 304 and Body comes from the cache.
+	NotModified bool
+	// Body of the feed, decompressed.
+	Body []byte
+	// Validators returned by the server.
+	ETag         string
+	LastModified string
+
+	// cacheDir is set when storing the feed was deferred to StoreCache.
+	cacheDir string
+}
+
+// StoreCache stores a feed fetched with FetchOptions.DeferCache in the cache,
+// so that the next Fetch is conditional. It does nothing for feeds served
+// from the cache or fetched without deferring.
+func (r *FetchResult) StoreCache() error {
+	if r.cacheDir == "" || r.NotModified {
+		return nil
+	}
+	return writeCacheEntry(r.cacheDir, r.URL, r)
+}
+
+// cacheEntry is the metadata stored next to a cached feed body.
+type cacheEntry struct {
+	URL          string    `json:"url"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+}
+
+// IsURL checks if the input refers to a remote calendar feed rather than a file
+func IsURL(input string) bool {
+	u, err := url.Parse(input)
+	if err != nil || u.Host == "" {
+		return false
+	}
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https", "webcal":
+		return true
+	}
+	return false
+}
+
+// DefaultCacheDir returns the per-user cache directory for downloaded feeds.
+func DefaultCacheDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, version.AppName, "feeds")
+}
+
+// Fetch downloads the calendar feed at rawURL.
+// When a cache directory is configured, the request is made conditional on the
+// cached ETag / Last-Modified validators and a 304 answer is served from the cache.
+func Fetch(ctx context.Context, rawURL string, opts FetchOptions) (*FetchResult, error) {
+	target, err := normalizeFeedURL(rawURL, opts.WebcalScheme)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+	maxBody := opts.MaxBodySize
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBodySize
+	}
+	client := opts.Client
+	if client == nil {
+		client = &http.Client{Timeout: timeout}
+	}
+	// Never mutate a caller-provided client
+	redirectClient := *client
+	redirectClient.CheckRedirect = redirectPolicy(opts.MaxRedirects)
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return nil, AppError{Message: "failed to create request", Value: err, Code: CodeInvalidArgument}
+	}
+	req.Header.Set("Accept", "text/calendar, application/ics;q=0.9, */*;q=0.5")
+	// Setting Accept-Encoding ourselves disables the transport's transparent
+	// decompression, so the body size limit applies to decompressed bytes.
+	req.Header.Set("Accept-Encoding", "gzip")
+	req.Header.Set("User-Agent", version.AppName+"/"+version.Version)
+
+	var cached *cacheEntry
+	if opts.CacheDir != "" {
+		cached = readCacheEntry(opts.CacheDir, target)
+		if cached != nil {
+			if cached.ETag != "" {
+				req.Header.Set("If-None-Match", cached.ETag)
+			}
+			if cached.LastModified != "" {
+				req.Header.Set("If-Modified-Since", cached.LastModified)
+			}
+		}
+	}
+
+	resp, err := redirectClient.Do(req)
+	if err != nil {
+		return nil, AppError{Message: fmt.Sprintf("failed to fetch %s", target), Value: err, Code: CodeFetchFailed}
+	}
+	defer resp.Body.Close()
+
+	result := &FetchResult{
+		URL:          target,
+		FinalURL:     resp.Request.URL.String(),
+		StatusCode:   resp.StatusCode,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && cached != nil:
+		body, err := os.ReadFile(cacheBodyPath(opts.CacheDir, target))
+		if err != nil {
+			return nil, AppError{Message: "failed to read cached feed", Value: err, Code: CodeReadFailed}
+		}
+		result.NotModified = true
+		result.Body = body
+		if result.ETag == "" {
+			result.ETag = cached.ETag
+		}
+		if result.LastModified == "" {
+			result.LastModified = cached.LastModified
+		}
+		return result, nil
+	case resp.StatusCode < 200 || resp.StatusCode > 299:
+		return nil, AppError{Message: fmt.Sprintf("failed to fetch %s", target), Value: resp.Status, Code: CodeFetchFailed}
+	}
+
+	body, err := readFeedBody(resp, maxBody)
+	if err != nil {
+		return nil, err
+	}
+	result.Body = body
+
+	switch {
+	case opts.CacheDir == "":
+	case opts.DeferCache:
+		result.cacheDir = opts.CacheDir
+	default:
+		if err := writeCacheEntry(opts.CacheDir, target, result); err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// normalizeFeedURL validates the URL and rewrites webcal:// to http(s)://
+func normalizeFeedURL(rawURL string, webcalScheme string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return "", AppError{Message: "invalid calendar URL", Value: rawURL, Code: CodeInvalidArgument}
+	}
+
+	switch strings.ToLower(u.Scheme) {
+	case "http", "https":
+	case "webcal":
+		if webcalScheme == "" {
+			webcalScheme = "https"
+		}
+		u.Scheme = webcalScheme
+	default:
+		return "", AppError{Message: "unsupported URL scheme", Value: u.Scheme, Code: CodeInvalidArgument}
+	}
+
+	return u.String(), nil
+}
+
+// redirectPolicy limits the number of redirects followed
+func redirectPolicy(maxRedirects int) func(*http.Request, []*http.Request) error {
+	if maxRedirects <= 0 {
+		maxRedirects = DefaultMaxRedirects
+	}
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return AppError{Message: "too many redirects", Value: len(via), Code: CodeFetchFailed}
+		}
+		return nil
+	}
+}
+
+// readFeedBody reads a response body, decompressing gzip and enforcing maxBody
+func readFeedBody(resp *http.Response, maxBody int64) ([]byte, error) {
+	buffered := bufio.NewReader(resp.Body)
+	magic, _ := buffered.Peek(2)
+	var reader io.Reader = buffered
+
+	isGzip := strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") ||
+		bytes.Equal(magic, []byte{0x1f, 0x8b})
+	if isGzip {
+		gz, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, AppError{Message: "failed to decompress feed", Value: err, Code: CodeFetchFailed}
+		}
+		defer gz.Close()
+		reader = gz
+	}
+
+	body, err := io.ReadAll(io.LimitReader(reader, maxBody+1))
+	if err != nil {
+		return nil, AppError{Message: "failed to read feed", Value: err, Code: CodeFetchFailed}
+	}
+	if int64(len(body)) > maxBody {
+		return nil, AppError{Message: fmt.Sprintf("feed exceeds maximum size of %d bytes", maxBody), Code: CodeFetchFailed}
+	}
+
+	return body, nil
+}
+
+// cacheKey derives a file-system safe name from the feed URL
+func cacheKey(target string) string {
+	sum := sha256.Sum256([]byte(target))
+	return hex.EncodeToString(sum[:])
+}
+
+func cacheBodyPath(cacheDir, target string) string {
+	return filepath.Join(cacheDir, cacheKey(target)+".ics")
+}
+
+func cacheMetaPath(cacheDir, target string) string {
+	return filepath.Join(cacheDir, cacheKey(target)+".json")
+}
+
+// readCacheEntry returns the cached validators, or nil if nothing usable is cached
+func readCacheEntry(cacheDir, target string) *cacheEntry {
+	data, err := os.ReadFile(cacheMetaPath(cacheDir, target))
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil || entry.URL != target {
+		return nil
+	}
+	if entry.ETag == "" && entry.LastModified == "" {
+		return nil
+	}
+	if _, err := os.Stat(cacheBodyPath(cacheDir, target)); err != nil {
+		return nil
+	}
+	return &entry
+}
+
+// writeCacheEntry stores the body and validators, replacing files atomically
+func writeCacheEntry(cacheDir, target string, result *FetchResult) error {
+	if err := os.MkdirAll(cacheDir, 0750); err != nil {
+		return AppError{Message: "failed to create cache directory", Value: err, Code: CodeWriteFailed}
+	}
+
+	entry := cacheEntry{
+		URL:          target,
+		ETag:         result.ETag,
+		LastModified: result.LastModified,
+		FetchedAt:    time.Now().UTC(),
+	}
+	meta, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return AppError{Message: "failed to marshal cache entry", Value: err, Code: CodeWriteFailed}
+	}
+
+	if err := writeFileAtomic(cacheBodyPath(cacheDir, target), result.Body); err != nil {
+		return err
+	}
+	return writeFileAtomic(cacheMetaPath(cacheDir, target), meta)
+}
+
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return AppError{Message: "failed to write cache", Value: err, Code: CodeWriteFailed}
+	}
+	_, writeErr := tmp.Write(data)
+	closeErr := tmp.Close()
+	if err := errors.Join(writeErr, closeErr); err != nil {
+		os.Remove(tmp.Name())
+		return AppError{Message: "failed to write cache", Value: err, Code: CodeWriteFailed}
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return AppError{Message: "failed to write cache", Value: err, Code: CodeWriteFailed}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package icaljson
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testFeed = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\nBEGIN:VEVENT\r\nUID:1\r\nDTSTART:20250101T090000Z\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func TestFetchFollowsRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/old", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/feed.ics", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/feed.ics", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testFeed))
+	})
+	mux.HandleFunc("/loop", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/loop", http.StatusFound)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	result, err := Fetch(context.Background(), srv.URL+"/old", FetchOptions{Client: srv.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FinalURL != srv.URL+"/feed.ics" || string(result.Body) != testFeed {
+		t.Errorf("got final URL %q and body %q", result.FinalURL, result.Body)
+	}
+
+	_, err = Fetch(context.Background(), srv.URL+"/loop", FetchOptions{Client: srv.Client(), MaxRedirects: 3})
+	if !errors.Is(err, ErrFetch) {
+		t.Errorf("redirect loop: got %v, want ErrFetch", err)
+	}
+}
+
+func TestFetchDecompressesGzip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept-Encoding") != "gzip" {
+			t.Errorf("Accept-Encoding = %q", r.Header.Get("Accept-Encoding"))
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write([]byte(testFeed))
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	result, err := Fetch(context.Background(), srv.URL, FetchOptions{Client: srv.Client()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(result.Body) != testFeed {
+		t.Errorf("body = %q", result.Body)
+	}
+}
+
+func TestFetchEnforcesMaxBodySize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Compresses well, so the limit must apply to the decompressed size
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(bytes.Repeat([]byte("X"), 4096))
+		gz.Close()
+	}))
+	defer srv.Close()
+
+	_, err := Fetch(context.Background(), srv.URL, FetchOptions{Client: srv.Client(), MaxBodySize: 1024})
+	if !errors.Is(err, ErrFetch) || !strings.Contains(err.Error(), "maximum size") {
+		t.Errorf("got %v, want a size limit error", err)
+	}
+}
+
+func TestFetchConditionalRequests(t *testing.T) {
+	requests := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(testFeed))
+	}))
+	defer srv.Close()
+
+	opts := FetchOptions{Client: srv.Client(), CacheDir: t.TempDir(), DeferCache: true}
+	fetch := func() *FetchResult {
+		t.Helper()
+		result, err := Fetch(context.Background(), srv.URL, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return result
+	}
+
+	// Deferred: nothing is cached until StoreCache, so the next request is unconditional
+	if result := fetch(); result.NotModified {
+		t.Fatal("first fetch answered from the cache")
+	}
+	result := fetch()
+	if result.NotModified {
+		t.Fatal("feed was cached before StoreCache")
+	}
+	if err := result.StoreCache(); err != nil {
+		t.Fatal(err)
+	}
+
+	result = fetch()
+	if !result.NotModified || string(result.Body) != testFeed {
+		t.Errorf("got NotModified=%v and body %q, want the cached feed", result.NotModified, result.Body)
+	}
+	if requests != 3 {
+		t.Errorf("server saw %d requests, want 3", requests)
+	}
+}
@@ -0,0 +1,278 @@
+package icaljson
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Filter selects events. Filters compose with All, Any and Not.
+type Filter func(Event) bool
+
+// All matches events accepted by every filter (and all events when empty).
+func All(filters ...Filter) Filter {
+	return func(e Event) bool {
+		for _, f := range filters {
+			if f != nil && !f(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any matches events accepted by at least one filter.
+func Any(filters ...Filter) Filter {
+	return func(e Event) bool {
+		for _, f := range filters {
+			if f != nil && f(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not inverts a filter.
+func Not(f Filter) Filter {
+	return func(e Event) bool {
+		return !f(e)
+	}
+}
+
+// DateRange matches events overlapping [from, to). A zero bound leaves that
+// side open. Recurring events match when any of their occurrences overlaps;
+// the expansion stops at the first one.
+func DateRange(from, to time.Time) Filter {
+	return func(e Event) bool {
+		found := false
+		err := e.eachOccurrence(from, to, func(Occurrence) bool {
+			found = true
+			return false
+		})
+		return err == nil && found
+	}
+}
+
+// CategoryFilter matches events with at least one of the categories (case-insensitive).
+func CategoryFilter(categories ...string) Filter {
+	return func(e Event) bool {
+		for _, c := range e.Categories {
+			if containsFold(categories, c.Value) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// StatusFilter matches events whose STATUS is one of statuses.
+func StatusFilter(statuses ...string) Filter {
+	return func(e Event) bool {
+		return containsFold(statuses, e.Status)
+	}
+}
+
+// ClassFilter matches events whose CLASS is one of classes.
+// Events without CLASS are PUBLIC (RFC 5545 §3.8.1.3).
+func ClassFilter(classes ...string) Filter {
+	return func(e Event) bool {
+		class := e.Class
+		if class == "" {
+			class = "PUBLIC"
+		}
+		return containsFold(classes, class)
+	}
+}
+
+// TextFilter matches events whose summary, description or location contains
+// text, ignoring case.
+func TextFilter(text string) Filter {
+	needle := strings.ToLower(text)
+	return func(e Event) bool {
+		for _, field := range []string{e.Summary, e.Description, e.Location} {
+			if strings.Contains(strings.ToLower(field), needle) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// RegexpFilter matches events whose summary, description or location matches re.
+func RegexpFilter(re *regexp.Regexp) Filter {
+	return func(e Event) bool {
+		return re.MatchString(e.Summary) || re.MatchString(e.Description) || re.MatchString(e.Location)
+	}
+}
+
+// NearFilter matches events whose GEO position is within radiusKm of the point.
+// Events without a position never match.
+func NearFilter(lat, lon, radiusKm float64) Filter {
+	return func(e Event) bool {
+		if e.Geo.Latitude == 0 && e.Geo.Longitude == 0 {
+			return false
+		}
+		return DistanceKm(lat, lon, e.Geo.Latitude, e.Geo.Longitude) <= radiusKm
+	}
+}
+
+// DistanceKm returns the great-circle distance between two points (haversine).
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadiusKm = 6371.0
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+}
+
+// Filtered returns a copy of the calendar containing only the events matching f.
+func (c *Calendar) Filtered(f Filter) *Calendar {
+	result := *c
+	result.Events = nil
+	for _, e := range c.Events {
+		if f == nil || f(e) {
+			result.Events = append(result.Events, e)
+		}
+	}
+	return &result
+}
+
+// FilterOptions describes the event selection offered by the CLI.
+// Empty fields do not restrict the selection.
+type FilterOptions struct {
+	From       time.Time
+	To         time.Time
+	Expand     bool // Replace recurring events by their instances within From/To; needs To for events that recur forever
+	Categories []string
+	Statuses   []string
+	Classes    []string
+	Text       string
+	Regexp     bool // Interpret Text as a regular expression
+	Near       *GeoRadius
+}
+
+// GeoRadius is a circle around a point, used by NearFilter.
+type GeoRadius struct {
+	Latitude  float64
+	Longitude float64
+	RadiusKm  float64
+}
+
+// ParseGeoRadius parses "lat,lon,radiusKm".
+func ParseGeoRadius(value string) (*GeoRadius, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return nil, AppError{Message: "expected lat,lon,radiusKm", Value: value, Code: CodeInvalidArgument}
+	}
+	var numbers [3]float64
+	for i, p := range parts {
+		n, err := strconv.ParseFloat(strings.TrimSpace(p), 64)
+		if err != nil {
+			return nil, AppError{Message: "expected lat,lon,radiusKm", Value: value, Code: CodeInvalidArgument}
+		}
+		numbers[i] = n
+	}
+	if numbers[0] < -90 || numbers[0] > 90 || numbers[1] < -180 || numbers[1] > 180 || numbers[2] < 0 {
+		return nil, AppError{Message: "coordinates out of range", Value: value, Code: CodeInvalidArgument}
+	}
+	return &GeoRadius{Latitude: numbers[0], Longitude: numbers[1], RadiusKm: numbers[2]}, nil
+}
+
+// Filter builds the composed filter described by the options.
+func (o FilterOptions) Filter() (Filter, error) {
+	var filters []Filter
+	if !o.From.IsZero() || !o.To.IsZero() {
+		filters = append(filters, DateRange(o.From, o.To))
+	}
+	if len(o.Categories) > 0 {
+		filters = append(filters, CategoryFilter(o.Categories...))
+	}
+	if len(o.Statuses) > 0 {
+		filters = append(filters, StatusFilter(o.Statuses...))
+	}
+	if len(o.Classes) > 0 {
+		filters = append(filters, ClassFilter(o.Classes...))
+	}
+	if o.Text != "" {
+		if o.Regexp {
+			re, err := regexp.Compile(o.Text)
+			if err != nil {
+				return nil, AppError{Message: "invalid text pattern", Value: err, Code: CodeInvalidArgument}
+			}
+			filters = append(filters, RegexpFilter(re))
+		} else {
+			filters = append(filters, TextFilter(o.Text))
+		}
+	}
+	if o.Near != nil {
+		filters = append(filters, NearFilter(o.Near.Latitude, o.Near.Longitude, o.Near.RadiusKm))
+	}
+	return All(filters...), nil
+}
+
+// Apply returns a copy of the calendar with the options applied,
+// expanding recurrences first when requested.
+func (o FilterOptions) Apply(c *Calendar) (*Calendar, error) {
+	filter, err := o.Filter()
+	if err != nil {
+		return nil, err
+	}
+
+	source := c
+	if o.Expand {
+		if err := requireWindowEnd(c.Events, o.To); err != nil {
+			return nil, err
+		}
+		events, err := ExpandEvents(c.Events, o.From, o.To)
+		if err != nil {
+			return nil, err
+		}
+		expanded := *c
+		expanded.Events = events
+		source = &expanded
+	}
+
+	return source.Filtered(filter), nil
+}
+
+// IsZero reports whether the options select every event unchanged.
+func (o FilterOptions) IsZero() bool {
+	return o.From.IsZero() && o.To.IsZero() && !o.Expand && len(o.Categories) == 0 &&
+		len(o.Statuses) == 0 && len(o.Classes) == 0 && o.Text == "" && o.Near == nil
+}
+
+// ParseTimeBound parses a --from/--to style value: an RFC 3339 timestamp,
+// a date, a floating date-time, "now" or "today". Values without a zone are
+// interpreted in loc (UTC when nil).
+func ParseTimeBound(value string, loc *time.Location) (time.Time, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "":
+		return time.Time{}, nil
+	case "now":
+		return time.Now().In(loc), nil
+	case "today":
+		now := time.Now().In(loc)
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc), nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02T15:04", value, loc); err == nil {
+		return t, nil
+	}
+	t, _, err := ParseDateTime(value, loc)
+	return t, err
+}
+
+func containsFold(list []string, value string) bool {
+	return slices.ContainsFunc(list, func(item string) bool {
+		return strings.EqualFold(strings.TrimSpace(item), strings.TrimSpace(value))
+	})
+}
@@ -0,0 +1,148 @@
+package icaljson
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"unicode/utf8"
+)
+
+// unfoldReader implements Unfold.
+type unfoldReader struct {
+	r *bufio.Reader
+}
+
+// Unfold returns a reader that joins folded content lines (RFC 5545 §3.1):
+// every line break immediately followed by a single space or horizontal tab
+// is removed together with that one whitespace character. Line breaks may be
+// CRLF, LF or a bare CR. All other bytes, including trailing whitespace and
+// the remaining line breaks, are passed through unchanged, so multi-octet
+// UTF-8 sequences split by a fold are joined back together.
+func Unfold(r io.Reader) io.Reader {
+	return &unfoldReader{r: bufio.NewReader(r)}
+}
+
+func (u *unfoldReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		b, err := u.r.ReadByte()
+		if err != nil {
+			if n > 0 && err == io.EOF {
+				return n, nil
+			}
+			return n, err
+		}
+
+		if b == '\r' || b == '\n' {
+			if skip := u.foldLength(b); skip > 0 {
+				u.r.Discard(skip)
+				continue
+			}
+		}
+		p[n] = b
+		n++
+	}
+	return n, nil
+}
+
+// foldLength returns how many bytes after the line break byte b belong to a
+// fold, or 0 if the line break ends a content line.
+func (u *unfoldReader) foldLength(b byte) int {
+	next, _ := u.r.Peek(2)
+	if b == '\r' && len(next) > 0 && next[0] == '\n' {
+		if len(next) > 1 && isFoldSpace(next[1]) {
+			return 2
+		}
+		return 0
+	}
+	if len(next) > 0 && isFoldSpace(next[0]) {
+		return 1
+	}
+	return 0
+}
+
+func isFoldSpace(b byte) bool {
+	return b == ' ' || b == '\t'
+}
+
+// foldWriter implements Fold.
+type foldWriter struct {
+	w    io.Writer
+	line []byte
+	cr   bool // The last line ended with CR, so a leading LF completes its CRLF
+}
+
+// Fold returns a writer that folds each line written to it so that no
+// physical line exceeds 75 octets (RFC 5545 §3.1), never splitting a UTF-8
+// sequence, and terminates every line with CRLF. Input lines may end with
+// CRLF, LF or a bare CR, like those passed through by Unfold. Close writes a
+// final unterminated line; it does not close w.
+func Fold(w io.Writer) io.WriteCloser {
+	return &foldWriter{w: w}
+}
+
+func (f *foldWriter) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		if f.cr && p[0] == '\n' {
+			f.cr = false
+			written++
+			p = p[1:]
+			continue
+		}
+		i := bytes.IndexAny(p, "\r\n")
+		if i < 0 {
+			f.line = append(f.line, p...)
+			f.cr = false
+			return written + len(p), nil
+		}
+		f.line = append(f.line, p[:i]...)
+		if err := f.flush(); err != nil {
+			return written, err
+		}
+		f.cr = p[i] == '\r'
+		written += i + 1
+		p = p[i+1:]
+	}
+	return written, nil
+}
+
+func (f *foldWriter) Close() error {
+	if len(f.line) == 0 {
+		return nil
+	}
+	return f.flush()
+}
+
+func (f *foldWriter) flush() error {
+	_, err := f.w.Write(FoldLine(f.line))
+	f.line = f.line[:0]
+	return err
+}
+
+// maxFoldOctets is the maximum length of a physical line, excluding CRLF.
+const maxFoldOctets = 75
+
+// FoldLine folds a single unfolded content line at 75 octets without splitting
+// UTF-8 sequences and returns it terminated by CRLF.
+func FoldLine(line []byte) []byte {
+	out := make([]byte, 0, len(line)+len(line)/maxFoldOctets*3+2)
+	limit := maxFoldOctets
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		if cut == 0 {
+			// Not UTF-8; cut at the octet limit
+			cut = limit
+		}
+		out = append(out, line[:cut]...)
+		out = append(out, '\r', '\n', ' ')
+		line = line[cut:]
+		// The leading space of continuation lines counts towards the limit
+		limit = maxFoldOctets - 1
+	}
+	out = append(out, line...)
+	return append(out, '\r', '\n')
+}
@@ -0,0 +1,108 @@
+package icaljson
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestFoldingCorpus(t *testing.T) {
+	files, err := filepath.Glob("../../samples/folding/*.ics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no samples found")
+	}
+	for _, icsPath := range files {
+		t.Run(filepath.Base(icsPath), func(t *testing.T) {
+			calendar, err := ParseFile(icsPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := json.MarshalIndent(calendar, "", "  ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, err := os.ReadFile(strings.TrimSuffix(icsPath, ".ics") + ".json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(bytes.TrimSpace(got), bytes.TrimSpace(want)) {
+				t.Errorf("JSON differs from the expected output:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestFoldUnfoldRoundTrip(t *testing.T) {
+	x := func(n int) string { return strings.Repeat("x", n) }
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "exactly 75 octets",
+			input: "SUMMARY:" + x(67) + "\r\n",
+			want:  "SUMMARY:" + x(67) + "\r\n",
+		},
+		{
+			name:  "76 octets",
+			input: "SUMMARY:" + x(67) + "\r\n " + x(1) + "\r\n",
+			want:  "SUMMARY:" + x(67) + "\r\n " + x(1) + "\r\n",
+		},
+		{
+			name:  "continuation lines hold 74 octets after the space",
+			input: "SUMMARY:" + x(142) + "\r\n",
+			want:  "SUMMARY:" + x(67) + "\r\n " + x(74) + "\r\n " + x(1) + "\r\n",
+		},
+		{
+			name:  "fold inside a 2-octet sequence",
+			input: "SUMMARY:" + x(66) + "\xc3\r\n \xa9 ok\r\n",
+			want:  "SUMMARY:" + x(66) + "\r\n é ok\r\n",
+		},
+		{
+			name:  "fold inside a 4-octet sequence",
+			input: "SUMMARY:" + x(65) + "\xf0\x9f\r\n \x8e\x89 ok\r\n",
+			want:  "SUMMARY:" + x(65) + "\r\n 🎉 ok\r\n",
+		},
+		{
+			name:  "bare CR line endings",
+			input: "BEGIN:VEVENT\rSUMMARY:Fol\r ded\rEND:VEVENT\r",
+			want:  "BEGIN:VEVENT\r\nSUMMARY:Folded\r\nEND:VEVENT\r\n",
+		},
+		{
+			name:  "LF line endings",
+			input: "BEGIN:VEVENT\nSUMMARY:Fol\n\tded\nEND:VEVENT\n",
+			want:  "BEGIN:VEVENT\r\nSUMMARY:Folded\r\nEND:VEVENT\r\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, oneByte := range []bool{false, true} {
+				var r io.Reader = Unfold(strings.NewReader(tt.input))
+				if oneByte {
+					// Single-byte writes split every CRLF across two calls
+					r = iotest.OneByteReader(r)
+				}
+				var out bytes.Buffer
+				w := Fold(&out)
+				if _, err := io.Copy(w, r); err != nil {
+					t.Fatal(err)
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				if out.String() != tt.want {
+					t.Errorf("one byte at a time %v: got %q, want %q", oneByte, out.String(), tt.want)
+				}
+			}
+		})
+	}
+}
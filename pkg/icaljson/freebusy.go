@@ -0,0 +1,252 @@
+package icaljson
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Free/busy time types (RFC 5545 §3.2.9).
+const (
+	FBTypeFree            = "FREE"
+	FBTypeBusy            = "BUSY"
+	FBTypeBusyUnavailable = "BUSY-UNAVAILABLE"
+	FBTypeBusyTentative   = "BUSY-TENTATIVE"
+)
+
+// BusyPeriod is a time interval [Start, End) in which the calendar owner is busy.
+type BusyPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Type  string    `json:"type"` // FBTYPE: BUSY, BUSY-TENTATIVE or BUSY-UNAVAILABLE
+}
+
+// FreeBusy is the busy time of a calendar within a window, without any
+// details of the events it was computed from.
+type FreeBusy struct {
+	Start     time.Time    `json:"start"`
+	End       time.Time    `json:"end"`
+	Organizer string       `json:"organizer,omitempty"` // Calendar user the free/busy time belongs to
+	Busy      []BusyPeriod `json:"busy"`
+}
+
+// FreeBusy computes the busy periods of the calendar's events within
+// [from, to). Recurring events are expanded, honouring overrides and EXDATEs.
+// Events marked TRANSP:TRANSPARENT or STATUS:CANCELLED are free time,
+// TENTATIVE events are BUSY-TENTATIVE and all others BUSY. Time made
+// unavailable by VAVAILABILITY components is reported with their BUSYTYPE,
+// see AvailableWindows. Periods of the same type that overlap or touch are
+// merged, each instant is reported with the strongest type only (BUSY, then
+// BUSY-UNAVAILABLE, then BUSY-TENTATIVE), and all periods are clipped to the window.
+// Floating date-times and all-day events are taken in the time zone of from.
+func (c *Calendar) FreeBusy(from, to time.Time) (*FreeBusy, error) {
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return nil, AppError{Message: "free/busy needs a window with a start before its end", Code: CodeInvalidArgument}
+	}
+
+	instances, err := ExpandEvents(c.Events, from.Add(-24*time.Hour), to.Add(24*time.Hour))
+	if err != nil {
+		return nil, err
+	}
+	var busy, tentative []BusyPeriod
+	for _, e := range instances {
+		if strings.EqualFold(e.Transp, "TRANSPARENT") || strings.EqualFold(e.Status, "CANCELLED") {
+			continue
+		}
+		start, end, err := eventInterval(e, from.Location())
+		if err != nil {
+			continue
+		}
+		period := BusyPeriod{Start: maxTime(start, from).UTC(), End: minTime(end, to).UTC(), Type: FBTypeBusy}
+		if !period.Start.Before(period.End) {
+			continue
+		}
+		if strings.EqualFold(e.Status, "TENTATIVE") {
+			period.Type = FBTypeBusyTentative
+			tentative = append(tentative, period)
+		} else {
+			busy = append(busy, period)
+		}
+	}
+
+	// Time outside the VAVAILABILITY office hours
+	var unavailable []BusyPeriod
+	for _, p := range c.availabilityPeriods(from, to) {
+		switch p.Type {
+		case FBTypeFree:
+		case FBTypeBusy:
+			busy = append(busy, p)
+		case FBTypeBusyTentative:
+			tentative = append(tentative, p)
+		default:
+			p.Type = FBTypeBusyUnavailable
+			unavailable = append(unavailable, p)
+		}
+	}
+
+	// BUSY takes precedence over BUSY-UNAVAILABLE, which takes precedence over BUSY-TENTATIVE
+	busy = mergePeriods(busy)
+	unavailable = subtractPeriods(mergePeriods(unavailable), busy)
+	periods := append([]BusyPeriod{}, busy...)
+	periods = append(periods, unavailable...)
+	periods = append(periods, subtractPeriods(subtractPeriods(mergePeriods(tentative), busy), unavailable)...)
+	slices.SortStableFunc(periods, func(a, b BusyPeriod) int {
+		return a.Start.Compare(b.Start)
+	})
+	return &FreeBusy{Start: from.UTC(), End: to.UTC(), Busy: periods}, nil
+}
+
+// eventInterval returns the start and end of an event instance. Floating
+// date-times and dates are interpreted in loc.
+func eventInterval(e Event, loc *time.Location) (time.Time, time.Time, error) {
+	start, err := e.StartTime()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	end, err := e.EndTime()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if _, err := time.Parse(time.RFC3339, e.Start); err != nil && e.TZID == "" {
+		start, end = inLocation(start, loc), inLocation(end, loc)
+	}
+	return start, end, nil
+}
+
+// inLocation returns the same wall-clock time in loc.
+func inLocation(t time.Time, loc *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), loc)
+}
+
+// mergePeriods sorts periods and merges those that overlap or touch. All
+// periods are expected to have the same type.
+func mergePeriods(periods []BusyPeriod) []BusyPeriod {
+	slices.SortFunc(periods, func(a, b BusyPeriod) int {
+		return a.Start.Compare(b.Start)
+	})
+	var merged []BusyPeriod
+	for _, p := range periods {
+		if n := len(merged); n > 0 && !p.Start.After(merged[n-1].End) {
+			merged[n-1].End = maxTime(merged[n-1].End, p.End)
+			continue
+		}
+		merged = append(merged, p)
+	}
+	return merged
+}
+
+// subtractPeriods removes the time covered by the sorted, merged periods in
+// cut from each of periods.
+func subtractPeriods(periods, cut []BusyPeriod) []BusyPeriod {
+	var result []BusyPeriod
+	for _, p := range periods {
+		for _, c := range cut {
+			if !c.End.After(p.Start) {
+				continue
+			}
+			if !c.Start.Before(p.End) {
+				break
+			}
+			if c.Start.After(p.Start) {
+				result = append(result, BusyPeriod{Start: p.Start, End: c.Start, Type: p.Type})
+			}
+			p.Start = c.End
+			if !p.Start.Before(p.End) {
+				break
+			}
+		}
+		if p.Start.Before(p.End) {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+// MarshalICS serializes the free/busy time as a VCALENDAR with one
+// VFREEBUSY component, see WriteFreeBusy.
+func (fb *FreeBusy) MarshalICS() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteFreeBusy(&buf, fb); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteFreeBusy writes the free/busy time as an RFC 5545 VFREEBUSY published
+// with METHOD:PUBLISH. Periods are written in UTC, one FREEBUSY property per
+// FBTYPE.
+func WriteFreeBusy(w io.Writer, fb *FreeBusy) error {
+	folded := Fold(w)
+	iw := &icsWriter{w: folded}
+	iw.line("BEGIN", nil, "VCALENDAR")
+	iw.line("PRODID", nil, DefaultProdID)
+	iw.line("VERSION", nil, "2.0")
+	iw.line("METHOD", nil, MethodPublish)
+	iw.line("BEGIN", nil, "VFREEBUSY")
+	iw.line("UID", nil, freeBusyUID())
+	iw.timestamp("DTSTAMP", itipTimestamp())
+	iw.line("DTSTART", nil, fb.Start.UTC().Format("20060102T150405Z"))
+	iw.line("DTEND", nil, fb.End.UTC().Format("20060102T150405Z"))
+	iw.raw("ORGANIZER", nil, fb.Organizer)
+	for _, fbType := range []string{FBTypeBusy, FBTypeBusyUnavailable, FBTypeBusyTentative} {
+		var periods []string
+		for _, p := range fb.Busy {
+			if p.Type == fbType {
+				periods = append(periods, p.Start.UTC().Format("20060102T150405Z")+"/"+p.End.UTC().Format("20060102T150405Z"))
+			}
+		}
+		if len(periods) > 0 {
+			iw.line("FREEBUSY", map[string]string{"FBTYPE": fbType}, strings.Join(periods, ","))
+		}
+	}
+	iw.line("END", nil, "VFREEBUSY")
+	iw.line("END", nil, "VCALENDAR")
+	if iw.err == nil {
+		iw.err = folded.Close()
+	}
+	if iw.err != nil {
+		return AppError{Message: "failed to write free/busy time", Value: iw.err, Code: CodeWriteFailed}
+	}
+	return nil
+}
+
+// WriteFreeBusyFile writes the free/busy time as iCalendar text to outputPath.
+func WriteFreeBusyFile(fb *FreeBusy, outputPath string) error {
+	data, err := fb.MarshalICS()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0750); err != nil {
+		return AppError{Message: "failed to create directory", Value: err, Code: CodeWriteFailed}
+	}
+	if err := os.WriteFile(outputPath, data, 0600); err != nil {
+		return AppError{Message: "failed to write file", Value: err, Code: CodeWriteFailed}
+	}
+	return nil
+}
+
+func freeBusyUID() string {
+	random := make([]byte, 12)
+	rand.Read(random)
+	return hex.EncodeToString(random) + "-freebusy"
+}
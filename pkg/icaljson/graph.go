@@ -0,0 +1,229 @@
+package icaljson
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// Relationship types of the RELTYPE parameter (RFC 5545 §3.2.15).
+const (
+	RelTypeParent  = "PARENT"
+	RelTypeChild   = "CHILD"
+	RelTypeSibling = "SIBLING"
+)
+
+// RelType returns the RELTYPE of a RELATED-TO property, PARENT by default.
+func (p Property) RelType() string {
+	if reltype := p.Param("RELTYPE"); reltype != "" {
+		return strings.ToUpper(reltype)
+	}
+	return RelTypeParent
+}
+
+// GraphNode is a component in the tree returned by Calendar.Graph.
+type GraphNode struct {
+	UID      string      `json:"uid"`
+	Type     string      `json:"type"` // VEVENT or VTODO
+	Summary  string      `json:"summary,omitempty"`
+	Status   string      `json:"status,omitempty"`
+	Siblings []string    `json:"siblings,omitempty"`
+	Children []GraphNode `json:"children,omitempty"`
+	// Cycle marks a reference back to an ancestor; its children are omitted.
+	Cycle bool `json:"cycle,omitempty"`
+}
+
+// DanglingReference is a RELATED-TO whose UID is not in the calendar.
+type DanglingReference struct {
+	UID     string `json:"uid"`    // Component holding the RELATED-TO property
+	Target  string `json:"target"` // Referenced UID
+	RelType string `json:"reltype"`
+}
+
+// Graph is the RELATED-TO hierarchy of a calendar's events and tasks.
+type Graph struct {
+	Roots    []GraphNode         `json:"roots"`
+	Dangling []DanglingReference `json:"dangling,omitempty"`
+	Cycles   [][]string          `json:"cycles,omitempty"` // UIDs along each cycle, first UID repeated at the end
+}
+
+// graphComponent is a node of the relation graph before it is laid out as a tree.
+type graphComponent struct {
+	node     GraphNode
+	children []string
+	parents  []string
+}
+
+// Graph resolves the RELATED-TO properties of events and tasks by UID and
+// returns the parent/child trees. PARENT and CHILD relations are merged, so
+// either side may declare them; components without a parent are roots.
+// Recurrence overrides share their master's UID and are not listed separately.
+// Components that are only reachable through a cycle are listed as roots.
+func (c *Calendar) Graph() *Graph {
+	components := map[string]*graphComponent{}
+	var order []string
+	add := func(e Event, kind string) {
+		if e.UID == "" || components[e.UID] != nil {
+			return
+		}
+		components[e.UID] = &graphComponent{node: GraphNode{UID: e.UID, Type: kind, Summary: e.Summary, Status: e.Status}}
+		order = append(order, e.UID)
+	}
+	for _, e := range c.Events {
+		add(e, "VEVENT")
+	}
+	for _, t := range c.Todos {
+		add(t.Event, "VTODO")
+	}
+
+	graph := &Graph{Roots: []GraphNode{}}
+	link := func(parent, child string) {
+		p, ch := components[parent], components[child]
+		if parent == child {
+			graph.Cycles = append(graph.Cycles, []string{parent, parent})
+			return
+		}
+		if !slices.Contains(p.children, child) {
+			p.children = append(p.children, child)
+			ch.parents = append(ch.parents, parent)
+		}
+	}
+	relations := func(e Event) {
+		if components[e.UID] == nil {
+			return
+		}
+		for _, rel := range e.RelatedTo {
+			target := strings.TrimSpace(rel.Value)
+			if components[target] == nil {
+				graph.Dangling = append(graph.Dangling, DanglingReference{UID: e.UID, Target: target, RelType: rel.RelType()})
+				continue
+			}
+			switch rel.RelType() {
+			case RelTypeParent:
+				link(target, e.UID)
+			case RelTypeChild:
+				link(e.UID, target)
+			case RelTypeSibling:
+				for _, pair := range [][2]string{{e.UID, target}, {target, e.UID}} {
+					node := &components[pair[0]].node
+					if !slices.Contains(node.Siblings, pair[1]) {
+						node.Siblings = append(node.Siblings, pair[1])
+					}
+				}
+			}
+		}
+	}
+	for _, e := range c.Events {
+		relations(e)
+	}
+	for _, t := range c.Todos {
+		relations(t.Event)
+	}
+
+	visited := map[string]bool{}
+	cycles := map[string]bool{}
+	var build func(uid string, path []string) GraphNode
+	build = func(uid string, path []string) GraphNode {
+		component := components[uid]
+		node := component.node
+		if i := slices.Index(path, uid); i >= 0 {
+			cycle := append(slices.Clone(path[i:]), uid)
+			if key := cycleKey(cycle); !cycles[key] {
+				cycles[key] = true
+				graph.Cycles = append(graph.Cycles, cycle)
+			}
+			node.Cycle = true
+			return node
+		}
+		visited[uid] = true
+		path = append(path, uid)
+		for _, child := range component.children {
+			node.Children = append(node.Children, build(child, path))
+		}
+		return node
+	}
+
+	for _, uid := range order {
+		if len(components[uid].parents) == 0 {
+			graph.Roots = append(graph.Roots, build(uid, nil))
+		}
+	}
+	for _, uid := range order {
+		if !visited[uid] {
+			graph.Roots = append(graph.Roots, build(uid, nil))
+		}
+	}
+
+	return graph
+}
+
+// cycleKey identifies a cycle independently of its starting point.
+func cycleKey(cycle []string) string {
+	members := slices.Clone(cycle[:len(cycle)-1])
+	start := 0
+	for i, uid := range members {
+		if uid < members[start] {
+			start = i
+		}
+	}
+	return strings.Join(append(members[start:], members[:start]...), "\x00")
+}
+
+// WriteText prints the trees with box-drawing characters, followed by any
+// dangling references and cycles.
+func (g *Graph) WriteText(w io.Writer) error {
+	var b strings.Builder
+	var write func(node GraphNode, prefix string, last bool, root bool)
+	write = func(node GraphNode, prefix string, last bool, root bool) {
+		branch, indent := "", ""
+		if !root {
+			branch, indent = "├── ", "│   "
+			if last {
+				branch, indent = "└── ", "    "
+			}
+		}
+		b.WriteString(prefix + branch + formatGraphNode(node) + "\n")
+		for i, child := range node.Children {
+			write(child, prefix+indent, i == len(node.Children)-1, false)
+		}
+	}
+	for _, root := range g.Roots {
+		write(root, "", true, true)
+	}
+
+	if len(g.Dangling) > 0 {
+		b.WriteString("\nDangling references:\n")
+		for _, d := range g.Dangling {
+			fmt.Fprintf(&b, "  %s → %s (%s)\n", d.UID, d.Target, d.RelType)
+		}
+	}
+	if len(g.Cycles) > 0 {
+		b.WriteString("\nCycles:\n")
+		for _, cycle := range g.Cycles {
+			fmt.Fprintf(&b, "  %s\n", strings.Join(cycle, " → "))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatGraphNode(node GraphNode) string {
+	label := node.UID
+	if node.Summary != "" {
+		label = fmt.Sprintf("%s (%s)", node.Summary, node.UID)
+	}
+	details := []string{node.Type}
+	if node.Status != "" {
+		details = append(details, node.Status)
+	}
+	label += " [" + strings.Join(details, ", ") + "]"
+	if len(node.Siblings) > 0 {
+		label += " siblings: " + strings.Join(node.Siblings, ", ")
+	}
+	if node.Cycle {
+		label += " ↻ cycle"
+	}
+	return label
+}
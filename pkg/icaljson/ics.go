@@ -0,0 +1,504 @@
+package icaljson
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beyondcivic/icaljson/pkg/version"
+)
+
+// DefaultProdID is written as PRODID when the calendar has none.
+var DefaultProdID = "-//beyondcivic//" + version.AppName + "//EN"
+
+// listParams may hold several comma-separated calendar addresses, each of
+// which is quoted on its own.
+var listParams = []string{"MEMBER", "DELEGATED-TO", "DELEGATED-FROM"}
+
+// MarshalICS serializes the calendar as iCalendar text, see WriteICS.
+func (c *Calendar) MarshalICS() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := WriteICS(&buf, c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// WriteICS writes the calendar as an RFC 5545 iCalendar stream: text values
+// are escaped, lines are folded at 75 octets and terminated by CRLF.
+// Date-times of events with a TZID are written as local times in that zone,
+// with a VTIMEZONE generated from the IANA time zone database; other
+// date-times are written in UTC, floating date-times and dates as they are.
+// Fields that were not parsed from the input (e.g. Source) are not written.
+func WriteICS(w io.Writer, calendar *Calendar) error {
+	folded := Fold(w)
+	iw := &icsWriter{w: folded}
+	iw.calendar(calendar)
+	if iw.err == nil {
+		iw.err = folded.Close()
+	}
+	if iw.err != nil {
+		return AppError{Message: "failed to write iCalendar", Value: iw.err, Code: CodeWriteFailed}
+	}
+	return nil
+}
+
+// WriteICSFile writes the calendar as iCalendar text to outputPath.
+func WriteICSFile(calendar *Calendar, outputPath string) error {
+	data, err := calendar.MarshalICS()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0750); err != nil {
+		return AppError{Message: "failed to create directory", Value: err, Code: CodeWriteFailed}
+	}
+	if err := os.WriteFile(outputPath, data, 0600); err != nil {
+		return AppError{Message: "failed to write file", Value: err, Code: CodeWriteFailed}
+	}
+	return nil
+}
+
+// icsWriter writes content lines, keeping the first error.
+type icsWriter struct {
+	w   io.Writer
+	err error
+
+	// Time zone of the component being written, see dateTime
+	tzid string
+	loc  *time.Location
+}
+
+// line writes one unfolded content line with its parameters in name order.
+func (iw *icsWriter) line(name string, params map[string]string, value string) {
+	if iw.err != nil {
+		return
+	}
+	var b strings.Builder
+	b.WriteString(name)
+	for _, param := range slices.Sorted(maps.Keys(params)) {
+		if params[param] == "" {
+			continue
+		}
+		b.WriteString(";" + param + "=" + formatParamValue(param, params[param]))
+	}
+	b.WriteString(":" + value + "\n")
+	_, iw.err = io.WriteString(iw.w, b.String())
+}
+
+// text writes an escaped TEXT property unless value is empty.
+func (iw *icsWriter) text(name string, params map[string]string, value string) {
+	if value != "" {
+		iw.line(name, params, EncodeText(value))
+	}
+}
+
+// raw writes a property whose value needs no escaping unless value is empty.
+func (iw *icsWriter) raw(name string, params map[string]string, value string) {
+	if value != "" {
+		iw.line(name, params, value)
+	}
+}
+
+// timestamp writes a UTC DATE-TIME property such as DTSTAMP unless value is empty.
+func (iw *icsWriter) timestamp(name string, value string) {
+	if value != "" {
+		formatted, _ := formatICSDateTime(value)
+		iw.line(name, nil, formatted)
+	}
+}
+
+// dateTime writes a DATE or DATE-TIME property unless value is empty,
+// as a local time when the component has a time zone.
+func (iw *icsWriter) dateTime(name string, value string) {
+	if value != "" {
+		iw.dateTimes(name, []string{value})
+	}
+}
+
+// dateTimes writes a list of date-times as one property per value, so that
+// dates, date-times and periods can be mixed.
+func (iw *icsWriter) dateTimes(name string, values []string) {
+	for _, value := range values {
+		start, end, isPeriod := strings.Cut(value, "/")
+		params := map[string]string{}
+		formatted, isDate := iw.localDateTime(start, params)
+		switch {
+		case isPeriod:
+			params["VALUE"] = "PERIOD"
+			if _, err := ParseDuration(end); err != nil {
+				end, _ = iw.localDateTime(end, nil)
+			}
+			formatted += "/" + end
+		case isDate:
+			params["VALUE"] = "DATE"
+		}
+		iw.line(name, params, formatted)
+	}
+}
+
+// localDateTime formats value in the component's time zone and sets the TZID
+// parameter, or falls back to formatICSDateTime.
+func (iw *icsWriter) localDateTime(value string, params map[string]string) (string, bool) {
+	if t, err := time.Parse(time.RFC3339, strings.TrimSpace(value)); err == nil && iw.loc != nil {
+		if params != nil {
+			params["TZID"] = iw.tzid
+		}
+		return t.In(iw.loc).Format("20060102T150405"), false
+	}
+	return formatICSDateTime(value)
+}
+
+func (iw *icsWriter) properties(name string, props []Property, encode bool) {
+	for _, prop := range props {
+		value := prop.Value
+		if encode {
+			value = EncodeText(value)
+		}
+		iw.line(name, prop.Params, value)
+	}
+}
+
+// textList writes a comma-separated TEXT list property, one line per run of
+// items with the same parameters.
+func (iw *icsWriter) textList(name string, props []Property) {
+	for start := 0; start < len(props); {
+		end := start + 1
+		for end < len(props) && maps.Equal(props[end].Params, props[start].Params) {
+			end++
+		}
+		values := make([]string, 0, end-start)
+		for _, prop := range props[start:end] {
+			values = append(values, prop.Value)
+		}
+		iw.line(name, props[start].Params, JoinText(values))
+		start = end
+	}
+}
+
+func (iw *icsWriter) geo(geo *Geolocation) {
+	if geo == nil || (geo.Latitude == 0 && geo.Longitude == 0) {
+		return
+	}
+	iw.line("GEO", nil, strconv.FormatFloat(geo.Latitude, 'f', -1, 64)+";"+strconv.FormatFloat(geo.Longitude, 'f', -1, 64))
+}
+
+func (iw *icsWriter) calendar(c *Calendar) {
+	iw.line("BEGIN", nil, "VCALENDAR")
+	iw.raw("PRODID", nil, cmp.Or(c.ProdID, DefaultProdID))
+	iw.raw("VERSION", nil, cmp.Or(c.Version, "2.0"))
+	iw.raw("CALSCALE", nil, c.CalScale)
+	iw.raw("METHOD", nil, c.Method)
+	iw.text("NAME", nil, c.Name)
+	iw.text("DESCRIPTION", nil, c.Description)
+	iw.text("UID", nil, c.UID)
+	iw.raw("URL", nil, c.URL)
+	iw.timestamp("LAST-MODIFIED", c.LastModified)
+	iw.textList("CATEGORIES", c.Categories)
+	iw.raw("COLOR", nil, c.Color)
+	for _, image := range c.Images {
+		iw.image(image)
+	}
+	iw.raw("REFRESH-INTERVAL", map[string]string{"VALUE": "DURATION"}, c.RefreshInterval)
+	iw.raw("SOURCE", map[string]string{"VALUE": "URI"}, c.SourceURL)
+	iw.text("X-WR-TIMEZONE", nil, c.Timezone)
+	for _, tz := range calendarTimeZones(c) {
+		iw.timezone(tz.loc, tz.year)
+	}
+
+	for _, e := range c.Events {
+		iw.line("BEGIN", nil, "VEVENT")
+		iw.eventProperties(e)
+		iw.subComponents(e)
+		iw.line("END", nil, "VEVENT")
+	}
+	for _, t := range c.Todos {
+		iw.line("BEGIN", nil, "VTODO")
+		iw.eventProperties(t.Event)
+		iw.dateTime("DUE", t.Due)
+		iw.timestamp("COMPLETED", t.Completed)
+		if t.PercentComplete > 0 {
+			iw.line("PERCENT-COMPLETE", nil, strconv.Itoa(t.PercentComplete))
+		}
+		iw.subComponents(t.Event)
+		iw.line("END", nil, "VTODO")
+	}
+	for _, a := range c.Availability {
+		iw.line("BEGIN", nil, "VAVAILABILITY")
+		iw.eventProperties(a.Event)
+		iw.raw("BUSYTYPE", nil, a.BusyType)
+		iw.subComponents(a.Event)
+		for _, available := range a.Available {
+			iw.line("BEGIN", nil, "AVAILABLE")
+			iw.event(available)
+			iw.line("END", nil, "AVAILABLE")
+		}
+		iw.line("END", nil, "VAVAILABILITY")
+	}
+	iw.line("END", nil, "VCALENDAR")
+}
+
+// event writes the properties and sub-components of a component that has no
+// properties of its own.
+func (iw *icsWriter) event(e Event) {
+	iw.eventProperties(e)
+	iw.subComponents(e)
+}
+
+// eventProperties writes the properties shared by VEVENT, VTODO and
+// VAVAILABILITY. Component-specific properties follow, then subComponents.
+func (iw *icsWriter) eventProperties(e Event) {
+	altrep := func(name string) map[string]string {
+		if uri := e.AltReps[name]; uri != "" {
+			return map[string]string{"ALTREP": uri}
+		}
+		return nil
+	}
+
+	iw.tzid, iw.loc = "", nil
+	if e.TZID != "" {
+		if loc, err := time.LoadLocation(e.TZID); err == nil {
+			iw.tzid, iw.loc = e.TZID, loc
+		}
+	}
+
+	iw.text("UID", nil, e.UID)
+	iw.timestamp("DTSTAMP", e.DTStamp)
+	iw.dateTime("DTSTART", e.Start)
+	iw.dateTime("DTEND", e.End)
+	iw.raw("DURATION", nil, e.Duration)
+	iw.dateTime("RECURRENCE-ID", e.RecurrenceID)
+	iw.raw("RRULE", nil, e.RRule)
+	iw.dateTimes("RDATE", e.RDates)
+	iw.dateTimes("EXDATE", e.ExDates)
+
+	iw.text("SUMMARY", altrep("SUMMARY"), e.Summary)
+	iw.text("DESCRIPTION", altrep("DESCRIPTION"), e.Description)
+	iw.text("LOCATION", altrep("LOCATION"), e.Location)
+	if _, _, inline := decodeDataURI(e.AltReps["DESCRIPTION"]); !inline {
+		iw.text("X-ALT-DESC", map[string]string{"FMTTYPE": "text/html"}, e.DescriptionHTML)
+	}
+	iw.raw("URL", nil, e.URL)
+	iw.raw("STATUS", nil, e.Status)
+	iw.textList("CATEGORIES", e.Categories)
+	iw.raw("CLASS", nil, e.Class)
+	iw.raw("TRANSP", nil, e.Transp)
+
+	iw.raw("ORGANIZER", nil, e.Organizer)
+	iw.properties("ATTENDEE", e.Attendees, false)
+
+	if e.Priority > 0 {
+		iw.line("PRIORITY", nil, strconv.Itoa(e.Priority))
+	}
+	if e.Sequence > 0 {
+		iw.line("SEQUENCE", nil, strconv.Itoa(e.Sequence))
+	}
+	iw.timestamp("CREATED", e.Created)
+	iw.timestamp("LAST-MODIFIED", e.LastModified)
+
+	iw.geo(&e.Geo)
+	iw.textList("RESOURCES", e.Resources)
+	iw.raw("COLOR", nil, e.Color)
+	for _, image := range e.Images {
+		iw.image(image)
+	}
+	for _, conference := range e.Conferences {
+		params := cloneParams(conference.Params)
+		params["VALUE"] = "URI"
+		params["FEATURE"] = strings.Join(conference.Features, ",")
+		params["LABEL"] = conference.Label
+		iw.line("CONFERENCE", params, conference.URI)
+	}
+	iw.structuredData(e.StructuredData)
+
+	iw.properties("CONTACT", e.Contacts, true)
+	iw.properties("RELATED-TO", e.RelatedTo, true)
+	iw.properties("COMMENT", e.Comments, true)
+	for _, attachment := range e.Attachments {
+		iw.attachment("ATTACH", attachment, nil)
+	}
+	iw.properties("REQUEST-STATUS", e.RequestStatus, false)
+}
+
+// subComponents writes the PARTICIPANT, VLOCATION, VRESOURCE and VALARM
+// components nested in an event or task.
+func (iw *icsWriter) subComponents(e Event) {
+	for _, participant := range e.Participants {
+		iw.participant(participant)
+	}
+	for _, location := range e.VLocations {
+		iw.location(location)
+	}
+	for _, resource := range e.VResources {
+		iw.resource(resource)
+	}
+	for _, alarm := range e.Alarms {
+		iw.alarm(alarm)
+	}
+}
+
+// attachment writes an ATTACH or IMAGE property with the given extra parameters.
+func (iw *icsWriter) attachment(name string, a Attachment, extra map[string]string) {
+	params := cloneParams(a.Params)
+	maps.Copy(params, extra)
+	params["FMTTYPE"] = a.FmtType
+	params["FILENAME"] = a.Filename
+	if a.IsInline() {
+		params["ENCODING"] = "BASE64"
+		params["VALUE"] = "BINARY"
+		iw.line(name, params, a.Data)
+		return
+	}
+	if a.Size > 0 {
+		params["SIZE"] = strconv.FormatInt(a.Size, 10)
+	}
+	iw.raw(name, params, a.URI)
+}
+
+func (iw *icsWriter) image(image Image) {
+	extra := map[string]string{"DISPLAY": image.Display, "ALTREP": image.AltRep}
+	if !image.IsInline() {
+		extra["VALUE"] = "URI"
+	}
+	iw.attachment("IMAGE", image.Attachment, extra)
+}
+
+func (iw *icsWriter) structuredData(data []StructuredData) {
+	for _, d := range data {
+		params := cloneParams(d.Params)
+		params["FMTTYPE"] = d.FmtType
+		params["SCHEMA"] = d.Schema
+		switch d.Type {
+		case "BINARY":
+			params["VALUE"] = "BINARY"
+			params["ENCODING"] = "BASE64"
+			iw.line("STRUCTURED-DATA", params, d.Value)
+		case "URI":
+			params["VALUE"] = "URI"
+			iw.line("STRUCTURED-DATA", params, d.Value)
+		default:
+			params["VALUE"] = "TEXT"
+			iw.line("STRUCTURED-DATA", params, EncodeText(d.Value))
+		}
+	}
+}
+
+func (iw *icsWriter) participant(p Participant) {
+	iw.line("BEGIN", nil, "PARTICIPANT")
+	iw.text("UID", nil, p.UID)
+	iw.raw("PARTICIPANT-TYPE", nil, p.ParticipantType)
+	iw.raw("CALENDAR-ADDRESS", nil, p.CalendarAddress)
+	iw.text("SUMMARY", nil, p.Summary)
+	iw.text("DESCRIPTION", nil, p.Description)
+	iw.raw("URL", nil, p.URL)
+	iw.raw("STATUS", nil, p.Status)
+	iw.geo(p.Geo)
+	iw.properties("CONTACT", p.Contacts, true)
+	iw.properties("COMMENT", p.Comments, true)
+	iw.structuredData(p.StructuredData)
+	for _, location := range p.VLocations {
+		iw.location(location)
+	}
+	for _, resource := range p.VResources {
+		iw.resource(resource)
+	}
+	iw.line("END", nil, "PARTICIPANT")
+}
+
+func (iw *icsWriter) location(l VLocation) {
+	iw.line("BEGIN", nil, "VLOCATION")
+	iw.text("UID", nil, l.UID)
+	iw.text("NAME", nil, l.Name)
+	iw.text("DESCRIPTION", nil, l.Description)
+	if len(l.LocationTypes) > 0 {
+		iw.line("LOCATION-TYPE", nil, JoinText(l.LocationTypes))
+	}
+	iw.raw("URL", nil, l.URL)
+	iw.geo(l.Geo)
+	iw.structuredData(l.StructuredData)
+	iw.line("END", nil, "VLOCATION")
+}
+
+func (iw *icsWriter) resource(r VResource) {
+	iw.line("BEGIN", nil, "VRESOURCE")
+	iw.text("UID", nil, r.UID)
+	iw.text("NAME", nil, r.Name)
+	iw.text("DESCRIPTION", nil, r.Description)
+	iw.raw("RESOURCE-TYPE", nil, r.ResourceType)
+	iw.geo(r.Geo)
+	iw.structuredData(r.StructuredData)
+	iw.line("END", nil, "VRESOURCE")
+}
+
+func (iw *icsWriter) alarm(a Alarm) {
+	iw.line("BEGIN", nil, "VALARM")
+	iw.text("UID", nil, a.UID)
+	iw.raw("ACTION", nil, a.Action)
+	if _, err := ParseDuration(a.Trigger); err == nil || a.Trigger == "" {
+		iw.raw("TRIGGER", map[string]string{"RELATED": a.Related}, a.Trigger)
+	} else if formatted, _ := formatICSDateTime(a.Trigger); formatted != "" {
+		iw.line("TRIGGER", map[string]string{"VALUE": "DATE-TIME"}, formatted)
+	}
+	iw.raw("DURATION", nil, a.Duration)
+	if a.Repeat > 0 {
+		iw.line("REPEAT", nil, strconv.Itoa(a.Repeat))
+	}
+	iw.text("SUMMARY", nil, a.Summary)
+	iw.text("DESCRIPTION", nil, a.Description)
+	iw.properties("ATTENDEE", a.Attendees, false)
+	for _, attachment := range a.Attachments {
+		iw.attachment("ATTACH", attachment, nil)
+	}
+	iw.timestamp("ACKNOWLEDGED", a.Acknowledged)
+	iw.line("END", nil, "VALARM")
+}
+
+// formatICSDateTime converts a date/time of the JSON model back to its
+// iCalendar form and reports whether it is a DATE value. Values that cannot
+// be parsed are returned unchanged.
+func formatICSDateTime(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC().Format("20060102T150405Z"), false
+	}
+	t, allDay, err := ParseDateTime(value, nil)
+	switch {
+	case err != nil:
+		return value, false
+	case allDay:
+		return t.Format("20060102"), true
+	case strings.HasSuffix(value, "Z"):
+		return t.Format("20060102T150405Z"), false
+	}
+	return t.Format("20060102T150405"), false
+}
+
+// formatParamValue quotes a parameter value that contains ':', ';' or ','
+// (RFC 5545 §3.2). Double quotes cannot be escaped and are dropped.
+func formatParamValue(name, value string) string {
+	value = strings.ReplaceAll(value, `"`, "")
+	if slices.Contains(listParams, name) {
+		values := strings.Split(value, ",")
+		for i, v := range values {
+			values[i] = `"` + strings.TrimSpace(v) + `"`
+		}
+		return strings.Join(values, ",")
+	}
+	if strings.ContainsAny(value, ":;,") {
+		return `"` + value + `"`
+	}
+	return value
+}
+
+// cloneParams returns a writable copy of params.
+func cloneParams(params map[string]string) map[string]string {
+	clone := make(map[string]string, len(params)+4)
+	maps.Copy(clone, params)
+	return clone
+}
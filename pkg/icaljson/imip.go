@@ -0,0 +1,245 @@
+package icaljson
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// IMIPOptions sets the e-mail headers of an iMIP message. Empty fields are
+// derived from the iTIP message, see WriteIMIP.
+type IMIPOptions struct {
+	From    string    // Sender address, optionally with a display name
+	To      []string  // Recipient addresses
+	Subject string    // Subject line
+	Date    time.Time // Date header, the current time when zero
+	Body    string    // Plain text part shown by clients without iMIP support
+}
+
+// WriteIMIP wraps an iTIP message in an RFC 6047 iMIP e-mail: a
+// multipart/alternative message with a plain text summary and the calendar
+// as "text/calendar; method=...". Without explicit options, requests and
+// cancellations are sent by the ORGANIZER to the attendees and replies by
+// the replying attendee to the ORGANIZER; the subject and body describe the
+// first event.
+func WriteIMIP(w io.Writer, calendar *Calendar, opts IMIPOptions) error {
+	if calendar == nil || calendar.Method == "" || len(calendar.Events)+len(calendar.Todos) == 0 {
+		return AppError{Message: "iMIP needs an iTIP message with a METHOD and a component", Code: CodeInvalidArgument}
+	}
+	event := firstComponent(calendar)
+	method := strings.ToUpper(calendar.Method)
+
+	from, to := imipParties(method, event)
+	if opts.From != "" {
+		from = opts.From
+	}
+	if len(opts.To) > 0 {
+		to = opts.To
+	}
+	if from == "" || len(to) == 0 {
+		return AppError{Message: "iMIP needs a sender and at least one recipient", Value: method, Code: CodeInvalidArgument}
+	}
+	sender, err := mail.ParseAddress(from)
+	if err != nil {
+		return AppError{Message: "invalid sender address", Value: from, Code: CodeInvalidArgument}
+	}
+	recipients := make([]string, len(to))
+	for i, address := range to {
+		recipient, err := mail.ParseAddress(address)
+		if err != nil {
+			return AppError{Message: "invalid recipient address", Value: address, Code: CodeInvalidArgument}
+		}
+		recipients[i] = recipient.String()
+	}
+	date := opts.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	subject := opts.Subject
+	if subject == "" {
+		subject = imipSubject(method, event)
+	}
+	body := opts.Body
+	if body == "" {
+		body = imipBody(event)
+	}
+
+	ics, err := calendar.MarshalICS()
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+	fmt.Fprintf(&buf, "From: %s\r\n", sender)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: %s\r\n", messageID(sender.Address))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: %s\r\n\r\n", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": parts.Boundary()}))
+
+	text, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=UTF-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return AppError{Message: "failed to write iMIP message", Value: err, Code: CodeWriteFailed}
+	}
+	qp := quotedprintable.NewWriter(text)
+	qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	qp.Close()
+
+	part, err := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType("text/calendar", map[string]string{"charset": "UTF-8", "method": method})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return AppError{Message: "failed to write iMIP message", Value: err, Code: CodeWriteFailed}
+	}
+	writeBase64Lines(part, ics)
+	parts.Close()
+
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		return AppError{Message: "failed to write iMIP message", Value: err, Code: CodeWriteFailed}
+	}
+	return nil
+}
+
+// WriteIMIPFile writes an iMIP message to outputPath, usually an .eml file.
+func WriteIMIPFile(calendar *Calendar, outputPath string, opts IMIPOptions) error {
+	var buf bytes.Buffer
+	if err := WriteIMIP(&buf, calendar, opts); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outputPath), 0750); err != nil {
+		return AppError{Message: "failed to create directory", Value: err, Code: CodeWriteFailed}
+	}
+	if err := os.WriteFile(outputPath, buf.Bytes(), 0600); err != nil {
+		return AppError{Message: "failed to write file", Value: err, Code: CodeWriteFailed}
+	}
+	return nil
+}
+
+func firstComponent(calendar *Calendar) Event {
+	if len(calendar.Events) > 0 {
+		return calendar.Events[0]
+	}
+	return calendar.Todos[0].Event
+}
+
+// imipParties returns the default sender and recipients of an iTIP message.
+func imipParties(method string, event Event) (string, []string) {
+	organizer := mailAddress(event.Organizer, "")
+	if method == MethodReply || method == MethodRefresh || method == MethodCounter {
+		if len(event.Attendees) == 0 || organizer == "" {
+			return "", nil
+		}
+		return mailAddress(event.Attendees[0].Value, event.Attendees[0].Param("CN")), []string{organizer}
+	}
+	var to []string
+	for _, attendee := range event.Attendees {
+		if !sameCalAddress(attendee.Value, event.Organizer) {
+			to = append(to, mailAddress(attendee.Value, attendee.Param("CN")))
+		}
+	}
+	return organizer, to
+}
+
+// mailAddress formats a mailto: calendar address as an e-mail address.
+func mailAddress(address, name string) string {
+	address = calAddress(address)
+	if address == "" || strings.Contains(address, ":") {
+		return ""
+	}
+	return (&mail.Address{Name: name, Address: address}).String()
+}
+
+func imipSubject(method string, event Event) string {
+	summary := cmp.Or(event.Summary, event.UID)
+	switch method {
+	case MethodRequest:
+		if event.Sequence > 0 {
+			return "Updated invitation: " + summary
+		}
+		return "Invitation: " + summary
+	case MethodCancel:
+		return "Cancelled: " + summary
+	case MethodCounter:
+		return "New time proposed: " + summary
+	case MethodReply:
+		if len(event.Attendees) > 0 {
+			switch event.Attendees[0].Param("PARTSTAT") {
+			case PartStatAccepted:
+				return "Accepted: " + summary
+			case PartStatDeclined:
+				return "Declined: " + summary
+			case PartStatTentative:
+				return "Tentative: " + summary
+			case PartStatDelegated:
+				return "Delegated: " + summary
+			}
+		}
+	}
+	return summary
+}
+
+func imipBody(event Event) string {
+	var b strings.Builder
+	b.WriteString(cmp.Or(event.Summary, event.UID) + "\n")
+	if start, err := event.StartTime(); err == nil {
+		layout := "Monday, 2 January 2006 15:04 MST"
+		if event.IsAllDay() {
+			layout = "Monday, 2 January 2006"
+		}
+		fmt.Fprintf(&b, "\nWhen: %s", start.Format(layout))
+		if end, err := event.EndTime(); err == nil && end.After(start) && !event.IsAllDay() {
+			fmt.Fprintf(&b, " - %s", end.Format("15:04 MST"))
+		}
+		b.WriteString("\n")
+	}
+	if event.Location != "" {
+		fmt.Fprintf(&b, "Where: %s\n", event.Location)
+	}
+	if organizer := calAddress(event.Organizer); organizer != "" {
+		fmt.Fprintf(&b, "Organizer: %s\n", organizer)
+	}
+	if event.Status == "CANCELLED" {
+		b.WriteString("\nThis event has been cancelled.\n")
+	}
+	return b.String()
+}
+
+// messageID returns a unique Message-ID in the domain of the sender.
+func messageID(sender string) string {
+	domain := "localhost"
+	if _, host, ok := strings.Cut(sender, "@"); ok && host != "" {
+		domain = host
+	}
+	random := make([]byte, 12)
+	rand.Read(random)
+	return "<" + hex.EncodeToString(random) + "@" + domain + ">"
+}
+
+// writeBase64Lines writes data base64-encoded in lines of 76 characters (RFC 2045).
+func writeBase64Lines(w io.Writer, data []byte) {
+	encoded := base64.StdEncoding.EncodeToString(data)
+	for len(encoded) > 76 {
+		io.WriteString(w, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(w, encoded+"\r\n")
+}
@@ -0,0 +1,426 @@
+package icaljson
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+)
+
+// iTIP methods (RFC 5546 §1.4).
+const (
+	MethodPublish        = "PUBLISH"
+	MethodRequest        = "REQUEST"
+	MethodReply          = "REPLY"
+	MethodAdd            = "ADD"
+	MethodCancel         = "CANCEL"
+	MethodRefresh        = "REFRESH"
+	MethodCounter        = "COUNTER"
+	MethodDeclineCounter = "DECLINECOUNTER"
+)
+
+// Kinds of changes made by ApplyITIP, in addition to ChangeAdded and ChangeModified.
+const (
+	ChangeCancelled ChangeKind = "cancelled"
+	ChangeReplied   ChangeKind = "replied"
+	ChangeCountered ChangeKind = "countered" // Proposal recorded, the store is unchanged
+	ChangeRefresh   ChangeKind = "refresh"   // The attendee asks for the current version
+	ChangeIgnored   ChangeKind = "ignored"   // Stale or unknown, see Reason
+)
+
+// replyStampParam records on an attendee the DTSTAMP of the last REPLY
+// applied, so that replies arriving out of order are ignored.
+const replyStampParam = "X-RESPONSE-DTSTAMP"
+
+// Change is one effect of an iTIP message on the stored calendar.
+type Change struct {
+	Kind         ChangeKind    `json:"kind"`
+	Method       string        `json:"method"`
+	UID          string        `json:"uid,omitempty"`
+	RecurrenceID string        `json:"recurrence_id,omitempty"`
+	Summary      string        `json:"summary,omitempty"`
+	Attendee     string        `json:"attendee,omitempty"` // Replying or refreshing attendee
+	OldPartStat  string        `json:"old_partstat,omitempty"`
+	PartStat     string        `json:"partstat,omitempty"`
+	Fields       []FieldChange `json:"fields,omitempty"` // Updated or proposed properties
+	Reason       string        `json:"reason,omitempty"`
+}
+
+// ITIPOptions controls ApplyITIPWithOptions.
+type ITIPOptions struct {
+	// RemoveCancelled deletes cancelled events instead of setting their
+	// STATUS to CANCELLED.
+	RemoveCancelled bool
+}
+
+// ApplyITIP applies an iTIP message (RFC 5546) to a stored calendar and
+// returns the updated copy together with the changes made; store itself is
+// not modified. See ApplyITIPWithOptions.
+func ApplyITIP(store *Calendar, msg *Calendar) (*Calendar, []Change, error) {
+	return ApplyITIPWithOptions(store, msg, ITIPOptions{})
+}
+
+// ApplyITIPWithOptions applies an iTIP message to a stored calendar, matching
+// components by UID and RECURRENCE-ID:
+//
+//   - PUBLISH, REQUEST and ADD create or replace events. An existing event is
+//     only replaced by a higher SEQUENCE, or the same SEQUENCE with a later DTSTAMP.
+//   - REPLY updates the PARTSTAT of the replying attendees. Replies to an older
+//     SEQUENCE, or older than the last reply of the same attendee, are ignored.
+//   - CANCEL sets STATUS:CANCELLED (or removes the events with RemoveCancelled).
+//     Cancelling a single occurrence without an override adds an EXDATE.
+//   - COUNTER, DECLINECOUNTER and REFRESH are reported but change nothing.
+//
+// Events and tasks are handled alike.
+func ApplyITIPWithOptions(store *Calendar, msg *Calendar, opts ITIPOptions) (*Calendar, []Change, error) {
+	if store == nil || msg == nil {
+		return nil, nil, AppError{Message: "iTIP needs a stored calendar and a message", Code: CodeInvalidArgument}
+	}
+	method := strings.ToUpper(strings.TrimSpace(msg.Method))
+	switch method {
+	case MethodPublish, MethodRequest, MethodReply, MethodAdd, MethodCancel, MethodRefresh, MethodCounter, MethodDeclineCounter:
+	case "":
+		return nil, nil, AppError{Message: "iTIP message has no METHOD", Code: CodeInvalidArgument}
+	default:
+		return nil, nil, AppError{Message: "unsupported iTIP method", Value: msg.Method, Code: CodeInvalidArgument}
+	}
+
+	result := *store
+	result.Events = slices.Clone(store.Events)
+	result.Todos = slices.Clone(store.Todos)
+	a := &itipApplier{method: method, opts: opts}
+
+	events := &itipComponents{
+		len: func() int { return len(result.Events) },
+		at:  func(i int) *Event { return &result.Events[i] },
+		add: func(i int) { result.Events = append(result.Events, msg.Events[i]) },
+		remove: func(i int) {
+			result.Events = slices.Delete(result.Events, i, i+1)
+		},
+	}
+	for i, e := range msg.Events {
+		if err := a.apply(events, e, i); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	todos := &itipComponents{
+		len: func() int { return len(result.Todos) },
+		at:  func(i int) *Event { return &result.Todos[i].Event },
+		add: func(i int) { result.Todos = append(result.Todos, msg.Todos[i]) },
+		remove: func(i int) {
+			result.Todos = slices.Delete(result.Todos, i, i+1)
+		},
+	}
+	for i, t := range msg.Todos {
+		if err := a.apply(todos, t.Event, i); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return &result, a.changes, nil
+}
+
+// itipComponents gives uniform access to the events or tasks of the result.
+type itipComponents struct {
+	len    func() int
+	at     func(i int) *Event
+	add    func(msgIndex int) // appends the i-th component of the message
+	remove func(i int)
+}
+
+// find returns the index of the component with uid and recurrenceID, or -1.
+func (c *itipComponents) find(uid, recurrenceID string) int {
+	for i := 0; i < c.len(); i++ {
+		if e := c.at(i); e.UID == uid && sameInstant(e.RecurrenceID, recurrenceID) {
+			return i
+		}
+	}
+	return -1
+}
+
+type itipApplier struct {
+	method  string
+	opts    ITIPOptions
+	changes []Change
+}
+
+func (a *itipApplier) change(kind ChangeKind, e Event, reason string) *Change {
+	a.changes = append(a.changes, Change{
+		Kind:         kind,
+		Method:       a.method,
+		UID:          e.UID,
+		RecurrenceID: e.RecurrenceID,
+		Summary:      e.Summary,
+		Reason:       reason,
+	})
+	return &a.changes[len(a.changes)-1]
+}
+
+func (a *itipApplier) apply(components *itipComponents, incoming Event, msgIndex int) error {
+	if incoming.UID == "" {
+		a.change(ChangeIgnored, incoming, "component has no UID")
+		return nil
+	}
+	idx := components.find(incoming.UID, incoming.RecurrenceID)
+	master := components.find(incoming.UID, "")
+
+	switch a.method {
+	case MethodPublish, MethodRequest, MethodAdd:
+		if idx < 0 {
+			if a.method == MethodAdd && master < 0 {
+				a.change(ChangeIgnored, incoming, "ADD for an unknown event, a REFRESH is needed")
+				return nil
+			}
+			components.add(msgIndex)
+			a.change(ChangeAdded, incoming, "")
+			return nil
+		}
+		stored := components.at(idx)
+		if reason, newer := itipNewer(incoming, *stored); !newer {
+			a.change(ChangeIgnored, incoming, reason)
+			return nil
+		}
+		fields, err := eventFieldChanges(*stored, incoming)
+		if err != nil {
+			return err
+		}
+		incoming.Source = stored.Source
+		*stored = incoming
+		a.change(ChangeModified, incoming, "").Fields = fields
+
+	case MethodReply:
+		if idx < 0 && master >= 0 && incoming.RecurrenceID != "" {
+			// The reply is for one occurrence: record it on a new override
+			instance, err := occurrenceOf(*components.at(master), incoming.RecurrenceID)
+			if err != nil {
+				return err
+			}
+			components.add(msgIndex)
+			idx = components.len() - 1
+			*components.at(idx) = instance
+		}
+		if idx < 0 {
+			a.change(ChangeIgnored, incoming, "REPLY for an unknown event")
+			return nil
+		}
+		a.reply(components.at(idx), incoming)
+
+	case MethodCancel:
+		if idx < 0 && master >= 0 && incoming.RecurrenceID != "" {
+			stored := components.at(master)
+			if incoming.Sequence < stored.Sequence {
+				a.change(ChangeIgnored, incoming, fmt.Sprintf("SEQUENCE %d is older than %d", incoming.Sequence, stored.Sequence))
+				return nil
+			}
+			stored.ExDates = append(slices.Clone(stored.ExDates), incoming.RecurrenceID)
+			a.change(ChangeCancelled, incoming, "occurrence excluded with EXDATE")
+			return nil
+		}
+		if idx < 0 {
+			a.change(ChangeIgnored, incoming, "CANCEL for an unknown event")
+			return nil
+		}
+		if stored := components.at(idx); incoming.Sequence < stored.Sequence {
+			a.change(ChangeIgnored, incoming, fmt.Sprintf("SEQUENCE %d is older than %d", incoming.Sequence, stored.Sequence))
+			return nil
+		}
+		a.cancel(components, incoming)
+
+	case MethodCounter:
+		if idx < 0 {
+			a.change(ChangeIgnored, incoming, "COUNTER for an unknown event")
+			return nil
+		}
+		fields, err := eventFieldChanges(*components.at(idx), incoming)
+		if err != nil {
+			return err
+		}
+		c := a.change(ChangeCountered, incoming, "proposal not applied, answer with REQUEST or DECLINECOUNTER")
+		c.Fields = fields
+		c.Attendee = firstAttendee(incoming)
+
+	case MethodDeclineCounter:
+		a.change(ChangeIgnored, incoming, "DECLINECOUNTER is addressed to an attendee")
+
+	case MethodRefresh:
+		if idx < 0 {
+			a.change(ChangeIgnored, incoming, "REFRESH for an unknown event")
+			return nil
+		}
+		c := a.change(ChangeRefresh, *components.at(idx), "send the current version as REQUEST")
+		c.Attendee = firstAttendee(incoming)
+	}
+	return nil
+}
+
+// reply applies the PARTSTAT of every attendee in a REPLY.
+func (a *itipApplier) reply(stored *Event, incoming Event) {
+	if incoming.Sequence < stored.Sequence {
+		a.change(ChangeIgnored, incoming, fmt.Sprintf("reply to SEQUENCE %d, current is %d", incoming.Sequence, stored.Sequence))
+		return
+	}
+	if len(incoming.Attendees) == 0 {
+		a.change(ChangeIgnored, incoming, "REPLY without ATTENDEE")
+		return
+	}
+
+	stored.Attendees = slices.Clone(stored.Attendees)
+	for _, replying := range incoming.Attendees {
+		partstat := strings.ToUpper(replying.Param("PARTSTAT"))
+		if partstat == "" {
+			partstat = "NEEDS-ACTION"
+		}
+		i := slices.IndexFunc(stored.Attendees, func(p Property) bool {
+			return sameCalAddress(p.Value, replying.Value)
+		})
+
+		c := a.change(ChangeReplied, *stored, "")
+		c.Attendee = replying.Value
+		c.PartStat = partstat
+		if i < 0 {
+			// RFC 5546 §3.2.3: the organizer may accept uninvited attendees
+			attendee := Property{Value: replying.Value, Params: maps.Clone(replying.Params)}
+			if incoming.DTStamp != "" {
+				attendee.Params = setParam(attendee.Params, replyStampParam, incoming.DTStamp)
+			}
+			stored.Attendees = append(stored.Attendees, attendee)
+			c.Reason = "attendee was not invited and has been added"
+			continue
+		}
+
+		attendee := stored.Attendees[i]
+		c.OldPartStat = attendee.Param("PARTSTAT")
+		if last := attendee.Param(replyStampParam); last != "" {
+			switch compareTimestamps(incoming.DTStamp, last) {
+			case -1:
+				c.Kind, c.Reason = ChangeIgnored, "a later reply of this attendee was already applied"
+				continue
+			case 0:
+				c.Kind, c.Reason = ChangeIgnored, "reply was already applied"
+				continue
+			}
+		}
+		params := maps.Clone(attendee.Params)
+		params = setParam(params, "PARTSTAT", partstat)
+		for _, name := range []string{"DELEGATED-TO", "DELEGATED-FROM"} {
+			if value := replying.Param(name); value != "" {
+				params = setParam(params, name, value)
+			}
+		}
+		delete(params, "RSVP")
+		if incoming.DTStamp != "" {
+			params = setParam(params, replyStampParam, incoming.DTStamp)
+		}
+		stored.Attendees[i] = Property{Value: attendee.Value, Params: params}
+	}
+	stored.RequestStatus = append(slices.Clone(stored.RequestStatus), incoming.RequestStatus...)
+}
+
+// cancel cancels an event, or with an empty RECURRENCE-ID also all its overrides.
+func (a *itipApplier) cancel(components *itipComponents, incoming Event) {
+	for i := components.len() - 1; i >= 0; i-- {
+		e := components.at(i)
+		if e.UID != incoming.UID || (incoming.RecurrenceID != "" && !sameInstant(e.RecurrenceID, incoming.RecurrenceID)) {
+			continue
+		}
+		cancelled := *e
+		if a.opts.RemoveCancelled {
+			components.remove(i)
+			a.change(ChangeRemoved, cancelled, "")
+			continue
+		}
+		old := e.Status
+		e.Status = "CANCELLED"
+		e.Sequence = max(e.Sequence, incoming.Sequence)
+		c := a.change(ChangeCancelled, *e, "")
+		c.Fields = []FieldChange{{Field: "status", New: e.Status}}
+		if old != "" {
+			c.Fields[0].Old = old
+		}
+	}
+}
+
+// itipNewer applies the RFC 5546 §2.1.5 precedence: the higher SEQUENCE wins,
+// and for equal SEQUENCE the later DTSTAMP.
+func itipNewer(incoming, stored Event) (string, bool) {
+	switch {
+	case incoming.Sequence > stored.Sequence:
+		return "", true
+	case incoming.Sequence < stored.Sequence:
+		return fmt.Sprintf("SEQUENCE %d is older than %d", incoming.Sequence, stored.Sequence), false
+	}
+	if compareTimestamps(incoming.DTStamp, stored.DTStamp) > 0 {
+		return "", true
+	}
+	return fmt.Sprintf("SEQUENCE %d with DTSTAMP %s is not newer than the stored revision", incoming.Sequence, incoming.DTStamp), false
+}
+
+func eventFieldChanges(old, new Event) ([]FieldChange, error) {
+	before, err := componentFields(old)
+	if err != nil {
+		return nil, err
+	}
+	after, err := componentFields(new)
+	if err != nil {
+		return nil, err
+	}
+	return diffFields(before, after), nil
+}
+
+// occurrenceOf returns a copy of master for the occurrence at recurrenceID.
+func occurrenceOf(master Event, recurrenceID string) (Event, error) {
+	instance := master
+	instance.RRule, instance.RDates, instance.ExDates = "", nil, nil
+
+	start, _, errStart := ParseDateTime(master.Start, nil)
+	occurrence, _, err := ParseDateTime(recurrenceID, nil)
+	if err != nil {
+		return Event{}, AppError{Message: "invalid RECURRENCE-ID", Value: recurrenceID, Code: CodeInvalidDateTime}
+	}
+	instance.RecurrenceID = formatLike(occurrence, master.Start)
+	instance.Start = instance.RecurrenceID
+	if end, _, errEnd := ParseDateTime(master.End, nil); errStart == nil && errEnd == nil {
+		instance.End = occurrence.Add(end.Sub(start)).UTC().Format(time.RFC3339)
+	}
+	return instance, nil
+}
+
+// sameInstant compares two optional date-times by the instant they denote.
+func sameInstant(a, b string) bool {
+	if a == "" || b == "" {
+		return a == b
+	}
+	return a == b || compareTimestamps(a, b) == 0
+}
+
+// sameCalAddress compares calendar user addresses case-insensitively,
+// ignoring a mailto: prefix.
+func sameCalAddress(a, b string) bool {
+	return strings.EqualFold(calAddress(a), calAddress(b))
+}
+
+func calAddress(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 7 && strings.EqualFold(s[:7], "mailto:") {
+		s = s[7:]
+	}
+	return s
+}
+
+func firstAttendee(e Event) string {
+	if len(e.Attendees) > 0 {
+		return e.Attendees[0].Value
+	}
+	return ""
+}
+
+// setParam returns params with name set to value, allocating the map if needed.
+func setParam(params map[string]string, name, value string) map[string]string {
+	if params == nil {
+		params = map[string]string{}
+	}
+	params[name] = value
+	return params
+}
@@ -0,0 +1,268 @@
+package icaljson
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// calendarMediaTypes are the MIME types of calendar parts in e-mails.
+var calendarMediaTypes = []string{"text/calendar", "application/ics", "text/x-vcalendar", "application/x-ics"}
+
+// MailHeaders are the headers of the e-mail a calendar was extracted from.
+type MailHeaders struct {
+	From      string `json:"from,omitempty"`
+	To        string `json:"to,omitempty"`
+	Subject   string `json:"subject,omitempty"`
+	Date      string `json:"date,omitempty"` // RFC 3339
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// IsMailFile reports whether a file appears to be an e-mail (.eml) or a
+// mailbox (.mbox, .mbx) based on its extension.
+func IsMailFile(filePath string) bool {
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".eml", ".mbox", ".mbx":
+		return true
+	}
+	return false
+}
+
+// ParseMailFile reads an .eml message or an mbox mailbox and parses the
+// calendars it contains, see ParseMail and ParseMbox.
+func ParseMailFile(path string, opts ParseOptions) ([]*Calendar, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, AppError{Message: "failed to read e-mail file", Value: err, Code: CodeReadFailed}
+	}
+	if bytes.HasPrefix(data, []byte("From ")) {
+		return ParseMbox(bytes.NewReader(data), opts)
+	}
+	return ParseMail(bytes.NewReader(data), opts)
+}
+
+// ParseMbox splits an mbox mailbox into its messages and parses the
+// calendars of each, see ParseMail. Messages without a calendar are skipped.
+func ParseMbox(r io.Reader, opts ParseOptions) ([]*Calendar, error) {
+	var calendars []*Calendar
+	parse := func(message []byte) error {
+		if len(bytes.TrimSpace(message)) == 0 {
+			return nil
+		}
+		found, err := ParseMail(bytes.NewReader(message), opts)
+		if errors.Is(err, ErrNotICalendar) {
+			return nil
+		}
+		calendars = append(calendars, found...)
+		return err
+	}
+
+	var message []byte
+	br := bufio.NewReader(r)
+	previousBlank := true
+	for {
+		line, err := br.ReadBytes('\n')
+		if bytes.HasPrefix(line, []byte("From ")) && previousBlank {
+			if err := parse(message); err != nil {
+				return calendars, err
+			}
+			message = message[:0]
+		} else if len(line) > 0 {
+			// mboxrd escapes body lines starting with "From " as ">From "
+			if unquoted := bytes.TrimLeft(line, ">"); len(unquoted) < len(line) && bytes.HasPrefix(unquoted, []byte("From ")) {
+				line = line[1:]
+			}
+			message = append(message, line...)
+		}
+		previousBlank = len(bytes.TrimRight(line, "\r\n")) == 0
+		if err != nil {
+			break
+		}
+	}
+	if err := parse(message); err != nil {
+		return calendars, err
+	}
+	if len(calendars) == 0 {
+		return nil, AppError{Message: "no calendar found in mailbox", Code: CodeNotICalendar}
+	}
+	return calendars, nil
+}
+
+// ParseMail parses an RFC 5322 e-mail such as an iMIP invitation (RFC 6047).
+// It walks the MIME tree, including attached messages, and parses every
+// text/calendar and application/ics part, or attachment named *.ics, after
+// undoing its transfer encoding and charset. Identical parts, e.g. an invitation
+// sent both inline and as attachment, are parsed once. Each calendar
+// carries the From, To, Subject, Date and Message-ID headers in Message.
+func ParseMail(r io.Reader, opts ParseOptions) ([]*Calendar, error) {
+	msg, err := mail.ReadMessage(r)
+	if err != nil {
+		return nil, AppError{Message: "failed to read e-mail", Value: err, Code: CodeReadFailed}
+	}
+	headers := mailHeaders(msg.Header)
+
+	var parts []calendarPart
+	if err := collectCalendarParts(mimeHeader(msg.Header), msg.Body, &parts); err != nil {
+		return nil, err
+	}
+	if len(parts) == 0 {
+		return nil, AppError{Message: "no calendar found in e-mail", Value: headers.Subject, Code: CodeNotICalendar}
+	}
+
+	calendars := make([]*Calendar, 0, len(parts))
+	for _, part := range parts {
+		partOpts := opts
+		if partOpts.Encoding == "" {
+			partOpts.Encoding = part.charset
+		}
+		calendar, err := ParseWithOptions(bytes.NewReader(part.data), partOpts)
+		if err != nil {
+			return calendars, err
+		}
+		if part.charset != "" {
+			// The MIME charset is authoritative for e-mail (RFC 6047 §2.4)
+			calendar.Diagnostics = slices.DeleteFunc(calendar.Diagnostics, func(d Diagnostic) bool {
+				return d.Code == CodeNonUTF8Encoding
+			})
+		}
+		calendar.Message = &headers
+		calendars = append(calendars, calendar)
+	}
+	return calendars, nil
+}
+
+// calendarPart is the decoded content of a calendar MIME part.
+type calendarPart struct {
+	data    []byte
+	charset string
+}
+
+// mimeHeader is the subset of MIME part headers needed to find calendars.
+type mimeHeader interface {
+	Get(key string) string
+}
+
+// collectCalendarParts appends the calendar parts of an entity to parts,
+// recursing into multipart and message/rfc822 content.
+func collectCalendarParts(header mimeHeader, body io.Reader, parts *[]calendarPart) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", map[string]string{}
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return AppError{Message: "malformed MIME message", Value: err, Code: CodeReadFailed}
+			}
+			if err := collectCalendarParts(part.Header, part, parts); err != nil {
+				return err
+			}
+		}
+	case mediaType == "message/rfc822":
+		inner, err := mail.ReadMessage(transferDecoder(header, body))
+		if err != nil {
+			return nil
+		}
+		return collectCalendarParts(mimeHeader(inner.Header), inner.Body, parts)
+	case !isCalendarPart(mediaType, params, header):
+		return nil
+	}
+
+	data, err := io.ReadAll(transferDecoder(header, body))
+	if err != nil {
+		return AppError{Message: "failed to decode calendar part", Value: err, Code: CodeReadFailed}
+	}
+	for _, existing := range *parts {
+		if bytes.Equal(existing.data, data) {
+			return nil
+		}
+	}
+	*parts = append(*parts, calendarPart{data: data, charset: params["charset"]})
+	return nil
+}
+
+// isCalendarPart reports whether a leaf part holds a calendar.
+func isCalendarPart(mediaType string, params map[string]string, header mimeHeader) bool {
+	if slices.Contains(calendarMediaTypes, mediaType) {
+		return true
+	}
+	filename := params["name"]
+	if _, dispositionParams, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil && dispositionParams["filename"] != "" {
+		filename = dispositionParams["filename"]
+	}
+	return IsICalFile(filename) && filepath.Ext(strings.ToLower(filename)) != ".txt"
+}
+
+// transferDecoder undoes the Content-Transfer-Encoding of a part.
+func transferDecoder(header mimeHeader, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(header.Get("Content-Transfer-Encoding"))) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, base64Cleaner{body})
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
+
+// base64Cleaner drops the line breaks and whitespace that base64.NewDecoder
+// does not skip itself.
+type base64Cleaner struct{ r io.Reader }
+
+func (c base64Cleaner) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	kept := 0
+	for _, b := range p[:n] {
+		if b != ' ' && b != '\t' {
+			p[kept] = b
+			kept++
+		}
+	}
+	return kept, err
+}
+
+// mailHeaders extracts the context headers, decoding RFC 2047 encoded words.
+func mailHeaders(header mail.Header) MailHeaders {
+	decoder := mime.WordDecoder{CharsetReader: func(charset string, input io.Reader) (io.Reader, error) {
+		enc, err := LookupEncoding(charset)
+		if err != nil {
+			return nil, err
+		}
+		return enc.NewDecoder().Reader(input), nil
+	}}
+	decode := func(name string) string {
+		value := header.Get(name)
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			return strings.TrimSpace(decoded)
+		}
+		return strings.TrimSpace(value)
+	}
+
+	headers := MailHeaders{
+		From:      decode("From"),
+		To:        decode("To"),
+		Subject:   decode("Subject"),
+		MessageID: strings.Trim(header.Get("Message-ID"), "<> "),
+	}
+	if date, err := header.Date(); err == nil {
+		headers.Date = date.Format(time.RFC3339)
+	}
+	return headers
+}
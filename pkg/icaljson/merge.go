@@ -0,0 +1,450 @@
+package icaljson
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MergeStrategy decides which event wins when several calendars contain the
+// same UID (and RECURRENCE-ID).
+type MergeStrategy string
+
+const (
+	// MergeKeepNewest keeps the event with the highest SEQUENCE, then the latest
+	// LAST-MODIFIED, then the latest DTSTAMP. Ties keep the first event.
+	MergeKeepNewest MergeStrategy = "newest"
+	// MergeKeepFirst keeps the event from the first calendar that has it.
+	MergeKeepFirst MergeStrategy = "first"
+	// MergeFailOnConflict returns an error when events with the same UID differ.
+	MergeFailOnConflict MergeStrategy = "fail"
+)
+
+// Default near-duplicate detection settings.
+const (
+	DefaultDuplicateThreshold = 0.8
+	DefaultDuplicateWindow    = 30 * time.Minute
+)
+
+// MergePolicy configures Merge.
+type MergePolicy struct {
+	Strategy MergeStrategy
+	// DetectDuplicates reports events without a shared UID that look alike.
+	DetectDuplicates bool
+	// DropDuplicates removes the later event of each near-duplicate pair.
+	DropDuplicates bool
+	// DuplicateThreshold is the minimum similarity score (0..1) of a near-duplicate.
+	DuplicateThreshold float64
+	// DuplicateWindow is the maximum start time difference of a near-duplicate.
+	DuplicateWindow time.Duration
+}
+
+// EventRef identifies an event in merge and diff reports.
+type EventRef struct {
+	UID          string `json:"uid,omitempty"`
+	RecurrenceID string `json:"recurrence_id,omitempty"`
+	Summary      string `json:"summary,omitempty"`
+	Start        string `json:"start,omitempty"`
+	Source       string `json:"source,omitempty"`
+}
+
+// MergeConflict records how events sharing a UID were resolved.
+type MergeConflict struct {
+	UID          string     `json:"uid"`
+	RecurrenceID string     `json:"recurrence_id,omitempty"`
+	Kept         EventRef   `json:"kept"`
+	Discarded    []EventRef `json:"discarded"`
+	Reason       string     `json:"reason"`
+}
+
+// DuplicatePair is a pair of similar events with different UIDs.
+type DuplicatePair struct {
+	First   EventRef `json:"first"`
+	Second  EventRef `json:"second"`
+	Score   float64  `json:"score"`
+	Dropped bool     `json:"dropped,omitempty"`
+}
+
+// MergeReport describes the decisions taken by Merge.
+type MergeReport struct {
+	Sources    []string        `json:"sources"`
+	Events     int             `json:"events"`
+	Todos      int             `json:"todos,omitempty"`
+	Conflicts  []MergeConflict `json:"conflicts,omitempty"`
+	Duplicates []DuplicatePair `json:"duplicates,omitempty"`
+}
+
+// Merge combines calendars into one. Events, tasks and availability
+// components sharing UID and RECURRENCE-ID are resolved according to
+// policy.Strategy; every component records the calendar it came from in
+// Source (Calendar.Source, or "calendar-N" when unset). Calendar properties (PRODID, CALSCALE and the
+// RFC 7986 NAME, DESCRIPTION, COLOR, IMAGE, ...) are taken from the first
+// calendar.
+func Merge(policy MergePolicy, cals ...*Calendar) (*Calendar, *MergeReport, error) {
+	if policy.Strategy == "" {
+		policy.Strategy = MergeKeepNewest
+	}
+	switch policy.Strategy {
+	case MergeKeepNewest, MergeKeepFirst, MergeFailOnConflict:
+	default:
+		return nil, nil, AppError{Message: "unknown merge strategy", Value: policy.Strategy, Code: CodeInvalidArgument}
+	}
+
+	merged := &Calendar{Version: "2.0"}
+	report := &MergeReport{}
+	sources := make([]string, len(cals))
+
+	for i, cal := range cals {
+		if cal == nil {
+			continue
+		}
+		sources[i] = cal.Source
+		if sources[i] == "" {
+			sources[i] = fmt.Sprintf("calendar-%d", i+1)
+		}
+		report.Sources = append(report.Sources, sources[i])
+		if len(report.Sources) == 1 {
+			copyCalendarProperties(merged, cal)
+		}
+	}
+
+	var err error
+	var conflicts []MergeConflict
+	merged.Events, report.Conflicts, err = mergeComponents(policy.Strategy, cals, sources,
+		func(c *Calendar) []Event { return c.Events }, func(e *Event) *Event { return e })
+	if err != nil {
+		return nil, nil, err
+	}
+	merged.Todos, conflicts, err = mergeComponents(policy.Strategy, cals, sources,
+		func(c *Calendar) []Todo { return c.Todos }, func(t *Todo) *Event { return &t.Event })
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Conflicts = append(report.Conflicts, conflicts...)
+	merged.Availability, conflicts, err = mergeComponents(policy.Strategy, cals, sources,
+		func(c *Calendar) []Availability { return c.Availability }, func(a *Availability) *Event { return &a.Event })
+	if err != nil {
+		return nil, nil, err
+	}
+	report.Conflicts = append(report.Conflicts, conflicts...)
+	sort.Slice(report.Conflicts, func(i, j int) bool {
+		return report.Conflicts[i].UID+report.Conflicts[i].RecurrenceID < report.Conflicts[j].UID+report.Conflicts[j].RecurrenceID
+	})
+
+	if policy.DetectDuplicates || policy.DropDuplicates {
+		pairs := findDuplicates(merged.Events, policy.DuplicateThreshold, policy.DuplicateWindow)
+		if policy.DropDuplicates {
+			drop := map[int]bool{}
+			for i := range pairs {
+				if !drop[pairs[i].firstIndex] {
+					drop[pairs[i].secondIndex] = true
+					pairs[i].Dropped = true
+				}
+			}
+			var kept []Event
+			for i, e := range merged.Events {
+				if !drop[i] {
+					kept = append(kept, e)
+				}
+			}
+			merged.Events = kept
+		}
+		for _, p := range pairs {
+			report.Duplicates = append(report.Duplicates, p.DuplicatePair)
+		}
+	}
+
+	report.Events = len(merged.Events)
+	report.Todos = len(merged.Todos)
+	return merged, report, nil
+}
+
+// copyCalendarProperties copies the calendar properties of src to dst,
+// leaving out its components, source and iTIP method.
+func copyCalendarProperties(dst, src *Calendar) {
+	dst.ProdID = src.ProdID
+	dst.CalScale = src.CalScale
+	dst.Name = src.Name
+	dst.Description = src.Description
+	dst.UID = src.UID
+	dst.URL = src.URL
+	dst.LastModified = src.LastModified
+	dst.Categories = src.Categories
+	dst.Color = src.Color
+	dst.Images = src.Images
+	dst.RefreshInterval = src.RefreshInterval
+	dst.SourceURL = src.SourceURL
+	dst.Timezone = src.Timezone
+}
+
+// mergeComponents combines the components that list returns from each
+// calendar, resolving those with the same UID and RECURRENCE-ID by strategy.
+// event returns the event properties of a component.
+func mergeComponents[T any](strategy MergeStrategy, cals []*Calendar, sources []string, list func(*Calendar) []T, event func(*T) *Event) ([]T, []MergeConflict, error) {
+	var merged []T
+	index := map[string]int{}     // UID key -> position in merged
+	discarded := map[string][]T{} // UID key -> losing components
+
+	for i, cal := range cals {
+		if cal == nil {
+			continue
+		}
+		for _, item := range list(cal) {
+			e := event(&item)
+			if e.Source == "" {
+				e.Source = sources[i]
+			}
+			if e.UID == "" {
+				merged = append(merged, item)
+				continue
+			}
+
+			key := e.UID + "\x00" + e.RecurrenceID
+			pos, exists := index[key]
+			if !exists {
+				index[key] = len(merged)
+				merged = append(merged, item)
+				continue
+			}
+
+			current := merged[pos]
+			if sameComponentContent(current, item, event) {
+				continue
+			}
+			switch strategy {
+			case MergeFailOnConflict:
+				return nil, nil, AppError{
+					Message: fmt.Sprintf("conflicting components for UID %q in %s and %s", e.UID, event(&current).Source, e.Source),
+					Code:    CodeMergeConflict,
+				}
+			case MergeKeepNewest:
+				if CompareRevision(*e, *event(&current)) > 0 {
+					merged[pos] = item
+					discarded[key] = append(discarded[key], current)
+					continue
+				}
+			}
+			discarded[key] = append(discarded[key], item)
+		}
+	}
+
+	var conflicts []MergeConflict
+	for key, losers := range discarded {
+		kept := *event(&merged[index[key]])
+		var losingEvents []Event
+		for i := range losers {
+			losingEvents = append(losingEvents, *event(&losers[i]))
+		}
+		conflict := MergeConflict{
+			UID:          kept.UID,
+			RecurrenceID: kept.RecurrenceID,
+			Kept:         eventRef(kept),
+			Reason:       revisionReason(strategy, kept, losingEvents),
+		}
+		for _, loser := range losingEvents {
+			conflict.Discarded = append(conflict.Discarded, eventRef(loser))
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return merged, conflicts, nil
+}
+
+// CompareRevision orders two revisions of the same event by SEQUENCE, then
+// LAST-MODIFIED, then DTSTAMP. It returns a positive number when a is newer.
+func CompareRevision(a, b Event) int {
+	if a.Sequence != b.Sequence {
+		return a.Sequence - b.Sequence
+	}
+	if c := compareTimestamps(a.LastModified, b.LastModified); c != 0 {
+		return c
+	}
+	return compareTimestamps(a.DTStamp, b.DTStamp)
+}
+
+// compareTimestamps compares two optional date-times; a set value is newer than an unset one.
+func compareTimestamps(a, b string) int {
+	ta, _, errA := ParseDateTime(a, nil)
+	tb, _, errB := ParseDateTime(b, nil)
+	switch {
+	case errA != nil && errB != nil:
+		return 0
+	case errA != nil:
+		return -1
+	case errB != nil:
+		return 1
+	}
+	return ta.Compare(tb)
+}
+
+func revisionReason(strategy MergeStrategy, kept Event, losers []Event) string {
+	if strategy == MergeKeepFirst {
+		return "kept first occurrence"
+	}
+	for _, loser := range losers {
+		if CompareRevision(kept, loser) == 0 {
+			return "same revision, kept first occurrence"
+		}
+	}
+	for _, loser := range losers {
+		switch {
+		case kept.Sequence != loser.Sequence:
+			return "higher SEQUENCE"
+		case compareTimestamps(kept.LastModified, loser.LastModified) != 0:
+			return "later LAST-MODIFIED"
+		}
+	}
+	return "later DTSTAMP"
+}
+
+// sameComponentContent compares components ignoring their source.
+func sameComponentContent[T any](a, b T, event func(*T) *Event) bool {
+	event(&a).Source, event(&b).Source = "", ""
+	return reflect.DeepEqual(a, b)
+}
+
+func eventRef(e Event) EventRef {
+	return EventRef{
+		UID:          e.UID,
+		RecurrenceID: e.RecurrenceID,
+		Summary:      e.Summary,
+		Start:        e.Start,
+		Source:       e.Source,
+	}
+}
+
+type indexedDuplicate struct {
+	DuplicatePair
+	firstIndex  int
+	secondIndex int
+}
+
+// FindDuplicates returns pairs of events with different UIDs whose starts are
+// within window and whose summary/location similarity reaches threshold.
+// Zero values select DefaultDuplicateThreshold and DefaultDuplicateWindow.
+func FindDuplicates(events []Event, threshold float64, window time.Duration) []DuplicatePair {
+	var pairs []DuplicatePair
+	for _, p := range findDuplicates(events, threshold, window) {
+		pairs = append(pairs, p.DuplicatePair)
+	}
+	return pairs
+}
+
+func findDuplicates(events []Event, threshold float64, window time.Duration) []indexedDuplicate {
+	if threshold <= 0 {
+		threshold = DefaultDuplicateThreshold
+	}
+	if window <= 0 {
+		window = DefaultDuplicateWindow
+	}
+
+	type timed struct {
+		index int
+		start time.Time
+	}
+	var ordered []timed
+	for i, e := range events {
+		if start, err := e.StartTime(); err == nil {
+			ordered = append(ordered, timed{i, start})
+		}
+	}
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].start.Before(ordered[j].start) })
+
+	var pairs []indexedDuplicate
+	for i := range ordered {
+		for j := i + 1; j < len(ordered) && ordered[j].start.Sub(ordered[i].start) <= window; j++ {
+			first, second := ordered[i].index, ordered[j].index
+			if first > second {
+				first, second = second, first
+			}
+			a, b := events[first], events[second]
+			if a.UID != "" && a.UID == b.UID {
+				continue
+			}
+			score := EventSimilarity(a, b)
+			if score >= threshold {
+				pairs = append(pairs, indexedDuplicate{
+					DuplicatePair: DuplicatePair{First: eventRef(a), Second: eventRef(b), Score: score},
+					firstIndex:    first,
+					secondIndex:   second,
+				})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool {
+		if pairs[i].firstIndex != pairs[j].firstIndex {
+			return pairs[i].firstIndex < pairs[j].firstIndex
+		}
+		return pairs[i].secondIndex < pairs[j].secondIndex
+	})
+	return pairs
+}
+
+// EventSimilarity scores how alike two events' summaries and locations are (0..1).
+// The summary weighs 70%, the location 30%; a missing location counts as half similar.
+func EventSimilarity(a, b Event) float64 {
+	summary := StringSimilarity(a.Summary, b.Summary)
+	var location float64
+	switch {
+	case a.Location == "" && b.Location == "":
+		location = 1
+	case a.Location == "" || b.Location == "":
+		location = 0.5
+	default:
+		location = StringSimilarity(a.Location, b.Location)
+	}
+	return 0.7*summary + 0.3*location
+}
+
+// StringSimilarity is the Sørensen–Dice coefficient of the character bigrams of
+// the normalised strings (lower case, punctuation removed, whitespace collapsed).
+func StringSimilarity(a, b string) float64 {
+	a, b = NormalizeText(a), NormalizeText(b)
+	if a == b {
+		return 1
+	}
+	if len([]rune(a)) < 2 || len([]rune(b)) < 2 {
+		return 0
+	}
+
+	bigrams := func(s string) map[string]int {
+		runes := []rune(s)
+		counts := map[string]int{}
+		for i := 0; i+1 < len(runes); i++ {
+			counts[string(runes[i:i+2])]++
+		}
+		return counts
+	}
+	ba, bb := bigrams(a), bigrams(b)
+
+	overlap, total := 0, 0
+	for gram, n := range ba {
+		overlap += min(n, bb[gram])
+		total += n
+	}
+	for _, n := range bb {
+		total += n
+	}
+	return 2 * float64(overlap) / float64(total)
+}
+
+// NormalizeText lower-cases s, drops punctuation and collapses whitespace.
+func NormalizeText(s string) string {
+	var b strings.Builder
+	space := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if space && b.Len() > 0 {
+				b.WriteRune(' ')
+			}
+			space = false
+			b.WriteRune(r)
+		default:
+			space = true
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,216 @@
+package icaljson
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"text/tabwriter"
+)
+
+// Output formats for event listings.
+const (
+	FormatJSON   = "json"
+	FormatNDJSON = "ndjson"
+	FormatCSV    = "csv"
+	FormatTable  = "table"
+)
+
+// OutputFormats lists the formats accepted by WriteRecords.
+var OutputFormats = []string{FormatJSON, FormatNDJSON, FormatCSV, FormatTable}
+
+// DefaultListFields are projected for tabular output when no fields are selected.
+var DefaultListFields = []string{"uid", "start", "end", "summary", "location"}
+
+// Record is an ordered set of named values, as produced by Project.
+type Record struct {
+	Fields []string
+	Values []any
+}
+
+// MarshalJSON encodes the record as an object, keeping the field order.
+func (r Record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, field := range r.Fields {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(field)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(r.Values[i])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// CheckFields returns an error for the first name that is not an event field
+// (JSON names, dots for nested fields).
+func CheckFields(fields []string) error {
+	for _, field := range fields {
+		if !isEventField(field) {
+			return AppError{Message: "unknown field", Value: field, Code: CodeInvalidQuery}
+		}
+	}
+	return nil
+}
+
+// Project extracts the named fields (JSON names, dots for nested fields) from events.
+// Unknown fields are reported even when there are no events.
+func Project(events []Event, fields []string) ([]Record, error) {
+	if err := CheckFields(fields); err != nil {
+		return nil, err
+	}
+
+	records := make([]Record, 0, len(events))
+	for _, e := range events {
+		record := Record{Fields: fields, Values: make([]any, len(fields))}
+		for i, field := range fields {
+			// Fields behind a nil pointer are null
+			if v, ok := lookupJSONField(reflect.ValueOf(e), strings.Split(field, ".")); ok {
+				record.Values[i] = v.Interface()
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// ParseFieldList splits a comma-separated field list.
+func ParseFieldList(value string) []string {
+	var fields []string
+	for _, field := range strings.Split(value, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// WriteEvents writes events in the given output format, projected to fields.
+// Without fields, JSON formats write complete events and tabular formats
+// fall back to DefaultListFields.
+func WriteEvents(w io.Writer, events []Event, fields []string, format string) error {
+	if len(fields) == 0 {
+		switch format {
+		case FormatJSON, "":
+			if events == nil {
+				events = []Event{}
+			}
+			data, err := json.MarshalIndent(events, "", "  ")
+			if err != nil {
+				return AppError{Message: "failed to marshal JSON", Value: err, Code: CodeWriteFailed}
+			}
+			_, err = fmt.Fprintf(w, "%s\n", data)
+			return err
+		case FormatNDJSON:
+			enc := json.NewEncoder(w)
+			for _, e := range events {
+				if err := enc.Encode(e); err != nil {
+					return AppError{Message: "failed to marshal JSON", Value: err, Code: CodeWriteFailed}
+				}
+			}
+			return nil
+		}
+		fields = DefaultListFields
+	}
+
+	records, err := Project(events, fields)
+	if err != nil {
+		return err
+	}
+	return WriteRecords(w, records, fields, format)
+}
+
+// WriteRecords writes projected records in the given output format.
+func WriteRecords(w io.Writer, records []Record, fields []string, format string) error {
+	switch format {
+	case FormatJSON, "":
+		if records == nil {
+			records = []Record{}
+		}
+		data, err := json.MarshalIndent(records, "", "  ")
+		if err != nil {
+			return AppError{Message: "failed to marshal JSON", Value: err, Code: CodeWriteFailed}
+		}
+		_, err = fmt.Fprintf(w, "%s\n", data)
+		return err
+	case FormatNDJSON:
+		enc := json.NewEncoder(w)
+		for _, r := range records {
+			if err := enc.Encode(r); err != nil {
+				return AppError{Message: "failed to marshal JSON", Value: err, Code: CodeWriteFailed}
+			}
+		}
+		return nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(fields); err != nil {
+			return err
+		}
+		for _, r := range records {
+			row := make([]string, len(r.Values))
+			for i, v := range r.Values {
+				row[i] = formatCell(v)
+			}
+			if err := cw.Write(row); err != nil {
+				return err
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatTable:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.ToUpper(strings.Join(fields, "\t")))
+		for _, r := range records {
+			row := make([]string, len(r.Values))
+			for i, v := range r.Values {
+				row[i] = strings.ReplaceAll(formatCell(v), "\n", " ")
+			}
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+		return tw.Flush()
+	}
+	return AppError{Message: "unsupported output format", Value: format, Code: CodeInvalidArgument}
+}
+
+// formatCell renders a field value for CSV and table output.
+func formatCell(v any) string {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return ""
+	case reflect.String:
+		return rv.String()
+	case reflect.Slice, reflect.Array:
+		items := make([]string, rv.Len())
+		for i := range items {
+			items[i] = formatCell(rv.Index(i).Interface())
+		}
+		return strings.Join(items, ", ")
+	case reflect.Struct, reflect.Map, reflect.Pointer:
+		if rv.IsZero() {
+			return ""
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(data)
+	}
+	if rv.IsZero() {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,885 @@
+package icaljson
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Query is a parsed event query such as
+//
+//	start >= 2025-10-01 and "music" in categories and location ~ "Zurich" order by start limit 10
+//
+// Fields are addressed by their JSON names (nested fields with dots, e.g. geo.latitude).
+// Supported operators: = != < <= > >= (typed comparison), ~ and !~ (case-insensitive
+// regular expression), contains, in, and, or, not and parentheses. A bare field is
+// true when it is set. Comparisons against a list field are true when any element matches.
+// Dates without a zone, now and today are taken in the local time zone, like
+// ParseTimeBound with time.Local.
+type Query struct {
+	where   queryNode
+	OrderBy []SortKey
+	Limit   int
+}
+
+// SortKey orders query results by a field.
+type SortKey struct {
+	Field      string
+	Descending bool
+}
+
+// ParseQuery parses a query expression.
+func ParseQuery(src string) (*Query, error) {
+	tokens, err := lexQuery(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &queryParser{tokens: tokens}
+	q := &Query{}
+
+	if !p.peekKeyword("order") && !p.peekKeyword("limit") && p.peek().kind != tokEOF {
+		q.where, err = p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if p.acceptKeyword("order") {
+		if !p.acceptKeyword("by") {
+			return nil, p.errorf("expected 'by' after 'order'")
+		}
+		for {
+			field := p.next()
+			if field.kind != tokIdent {
+				return nil, p.errorf("expected field name in 'order by'")
+			}
+			if !isEventField(field.text) {
+				return nil, AppError{Message: fmt.Sprintf("unknown field %q at offset %d", field.text, field.pos), Code: CodeInvalidQuery}
+			}
+			key := SortKey{Field: field.text}
+			if p.acceptKeyword("desc") {
+				key.Descending = true
+			} else {
+				p.acceptKeyword("asc")
+			}
+			q.OrderBy = append(q.OrderBy, key)
+			if !p.accept(tokComma) {
+				break
+			}
+		}
+	}
+	if p.acceptKeyword("limit") {
+		n := p.next()
+		limit, err := strconv.Atoi(n.text)
+		if n.kind != tokNumber || err != nil || limit < 0 {
+			return nil, p.errorf("expected a non-negative integer after 'limit'")
+		}
+		q.Limit = limit
+	}
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, p.errorf("unexpected %q", tok.text)
+	}
+
+	return q, nil
+}
+
+// ParseSortKeys parses "start,-priority" style sort specifications.
+func ParseSortKeys(value string) ([]SortKey, error) {
+	var keys []SortKey
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		key := SortKey{Field: strings.TrimPrefix(field, "-")}
+		key.Descending = strings.HasPrefix(field, "-")
+		if !isEventField(key.Field) {
+			return nil, AppError{Message: "unknown field", Value: key.Field, Code: CodeInvalidQuery}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Match reports whether the event satisfies the query's condition.
+func (q *Query) Match(e Event) (bool, error) {
+	if q.where == nil {
+		return true, nil
+	}
+	v, err := q.where.eval(e)
+	if err != nil {
+		return false, err
+	}
+	return truthy(v), nil
+}
+
+// Run selects, sorts and limits events.
+func (q *Query) Run(events []Event) ([]Event, error) {
+	var result []Event
+	for _, e := range events {
+		ok, err := q.Match(e)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			result = append(result, e)
+		}
+	}
+
+	if len(q.OrderBy) > 0 {
+		sort.SliceStable(result, func(i, j int) bool {
+			for _, key := range q.OrderBy {
+				a, _ := EventField(result[i], key.Field)
+				b, _ := EventField(result[j], key.Field)
+				c := compareForSort(a, b)
+				if c == 0 {
+					continue
+				}
+				if key.Descending {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+
+	if q.Limit > 0 && len(result) > q.Limit {
+		result = result[:q.Limit]
+	}
+	return result, nil
+}
+
+// EventField returns the value of the field with the given JSON name (dots
+// address nested fields). Strings holding dates are returned as time.Time and
+// integers as float64, so values compare naturally.
+func EventField(e Event, name string) (any, bool) {
+	v, ok := lookupJSONField(reflect.ValueOf(e), strings.Split(name, "."))
+	if !ok {
+		return nil, false
+	}
+	return queryValue(v), true
+}
+
+// FieldNames returns the queryable JSON field names of Event.
+func FieldNames() []string {
+	var names []string
+	t := reflect.TypeOf(Event{})
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// lookupJSONField follows path by JSON field names. At a slice of structs the
+// rest of the path is projected over the elements, e.g. "comments.value".
+func lookupJSONField(v reflect.Value, path []string) (reflect.Value, bool) {
+	for i, part := range path {
+		for v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct {
+			// Project the remaining path over the elements, e.g. comments.value
+			if !isJSONPath(v.Type().Elem(), path[i:]) {
+				return reflect.Value{}, false
+			}
+			// List fields are flattened, e.g. conferences.features
+			items := []any{}
+			for j := 0; j < v.Len(); j++ {
+				item, ok := lookupJSONField(v.Index(j), path[i:])
+				if !ok {
+					continue // e.g. a nil pointer on the way
+				}
+				if item.Kind() == reflect.Slice {
+					for k := 0; k < item.Len(); k++ {
+						items = append(items, item.Index(k).Interface())
+					}
+					continue
+				}
+				items = append(items, item.Interface())
+			}
+			return reflect.ValueOf(items), true
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, false
+		}
+		index, found := fieldIndexByJSONName(v.Type(), part)
+		if !found {
+			return reflect.Value{}, false
+		}
+		v = v.FieldByIndex(index)
+	}
+	return v, true
+}
+
+// isEventField reports whether field is the JSON name of an event field,
+// with dots for nested fields.
+func isEventField(field string) bool {
+	return isJSONPath(reflect.TypeOf(Event{}), strings.Split(field, "."))
+}
+
+// isJSONPath reports whether path names a field of t, following pointers
+// and projecting over slices of structs like lookupJSONField. It checks types
+// only, so paths through nil pointers such as vlocations.geo are valid.
+func isJSONPath(t reflect.Type, path []string) bool {
+	for _, part := range path {
+		for t.Kind() == reflect.Pointer {
+			t = t.Elem()
+		}
+		if t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Struct {
+			t = t.Elem()
+		}
+		if t.Kind() != reflect.Struct {
+			return false
+		}
+		index, found := fieldIndexByJSONName(t, part)
+		if !found {
+			return false
+		}
+		t = t.FieldByIndex(index).Type
+	}
+	return true
+}
+
+// fieldIndexByJSONName finds a struct field by its JSON name, including the
+// promoted fields of embedded structs such as Todo.Event.
+func fieldIndexByJSONName(t reflect.Type, name string) ([]int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			if index, ok := fieldIndexByJSONName(f.Type, name); ok {
+				return append([]int{i}, index...), true
+			}
+			continue
+		}
+		if jsonName(f) == name {
+			return []int{i}, true
+		}
+	}
+	return nil, false
+}
+
+func jsonName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
+
+// queryValue converts a struct field to the query's value domain.
+func queryValue(v reflect.Value) any {
+	switch v.Kind() {
+	case reflect.String:
+		s := v.String()
+		if t, _, err := ParseDateTime(s, nil); err == nil && looksLikeDate(s) {
+			return t
+		}
+		return s
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		return v.Float()
+	case reflect.Bool:
+		return v.Bool()
+	case reflect.Slice, reflect.Array:
+		list := make([]any, v.Len())
+		for i := range list {
+			list[i] = queryValue(v.Index(i))
+		}
+		return list
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return queryValue(v.Elem())
+	case reflect.Struct:
+		// A property compares by its value, e.g. "work" in categories
+		if p, ok := v.Interface().(Property); ok {
+			return p.Value
+		}
+		return v.Interface()
+	default:
+		return v.Interface()
+	}
+}
+
+// looksLikeDate avoids treating numeric strings (e.g. UID "20251004") as dates
+// unless they have the shape of a date value.
+func looksLikeDate(s string) bool {
+	return len(s) >= len(layoutDate) && (strings.Contains(s, "-") || strings.Contains(s, "T"))
+}
+
+// Query AST
+
+type queryNode interface {
+	eval(e Event) (any, error)
+}
+
+type literalNode struct{ value any }
+
+func (n literalNode) eval(Event) (any, error) { return n.value, nil }
+
+type fieldNode struct{ name string }
+
+func (n fieldNode) eval(e Event) (any, error) {
+	v, ok := EventField(e, n.name)
+	if !ok {
+		return nil, AppError{Message: "unknown field", Value: n.name, Code: CodeInvalidQuery}
+	}
+	return v, nil
+}
+
+type listNode struct{ items []queryNode }
+
+func (n listNode) eval(e Event) (any, error) {
+	list := make([]any, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(e)
+		if err != nil {
+			return nil, err
+		}
+		list[i] = v
+	}
+	return list, nil
+}
+
+type notNode struct{ operand queryNode }
+
+func (n notNode) eval(e Event) (any, error) {
+	v, err := n.operand.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return !truthy(v), nil
+}
+
+type logicalNode struct {
+	op          string // "and" or "or"
+	left, right queryNode
+}
+
+func (n logicalNode) eval(e Event) (any, error) {
+	l, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "and" && !truthy(l) {
+		return false, nil
+	}
+	if n.op == "or" && truthy(l) {
+		return true, nil
+	}
+	r, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	return truthy(r), nil
+}
+
+type compareNode struct {
+	op          string
+	left, right queryNode
+	pattern     *regexp.Regexp // compiled right side of ~ and !~
+}
+
+func (n compareNode) eval(e Event) (any, error) {
+	l, err := n.left.eval(e)
+	if err != nil {
+		return nil, err
+	}
+	r, err := n.right.eval(e)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "~", "!~":
+		matched := anyElement(l, func(item any) bool {
+			return n.pattern.MatchString(valueString(item))
+		})
+		return matched == (n.op == "~"), nil
+	case "contains":
+		if list, ok := l.([]any); ok {
+			return anyElement(list, func(item any) bool { return equalValues(item, r) }), nil
+		}
+		return strings.Contains(strings.ToLower(valueString(l)), strings.ToLower(valueString(r))), nil
+	case "in":
+		if list, ok := r.([]any); ok {
+			return anyElement(l, func(item any) bool {
+				return anyElement(list, func(candidate any) bool { return equalValues(item, candidate) })
+			}), nil
+		}
+		return strings.Contains(strings.ToLower(valueString(r)), strings.ToLower(valueString(l))), nil
+	case "=":
+		return anyElement(l, func(item any) bool { return equalValues(item, r) }), nil
+	case "!=":
+		return !anyElement(l, func(item any) bool { return equalValues(item, r) }), nil
+	}
+
+	return anyElement(l, func(item any) bool {
+		c, ok := compareValues(item, r)
+		if !ok {
+			return false
+		}
+		switch n.op {
+		case "<":
+			return c < 0
+		case "<=":
+			return c <= 0
+		case ">":
+			return c > 0
+		default:
+			return c >= 0
+		}
+	}), nil
+}
+
+// anyElement applies pred to v, or to each element when v is a list.
+func anyElement(v any, pred func(any) bool) bool {
+	if list, ok := v.([]any); ok {
+		for _, item := range list {
+			if pred(item) {
+				return true
+			}
+		}
+		return false
+	}
+	return pred(v)
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	case float64:
+		return x != 0
+	case time.Time:
+		return !x.IsZero()
+	case []any:
+		return len(x) > 0
+	}
+	return !reflect.ValueOf(v).IsZero()
+}
+
+func valueString(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return x
+	case time.Time:
+		return x.UTC().Format(time.RFC3339)
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func equalValues(a, b any) bool {
+	if c, ok := compareValues(a, b); ok {
+		return c == 0
+	}
+	return false
+}
+
+// compareValues compares two values, coercing strings to the other side's type.
+func compareValues(a, b any) (int, bool) {
+	switch x := a.(type) {
+	case time.Time:
+		y, ok := toTime(b)
+		if !ok {
+			return 0, false
+		}
+		return x.Compare(y), true
+	case float64:
+		y, ok := toNumber(b)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		return compareOrdered(boolInt(x), boolInt(y)), true
+	case string:
+		switch b.(type) {
+		case time.Time, float64:
+			c, ok := compareValues(b, a)
+			return -c, ok
+		case string:
+			return strings.Compare(strings.ToLower(x), strings.ToLower(b.(string))), true
+		}
+	case nil:
+		if b == nil {
+			return 0, true
+		}
+		return compareValues("", b)
+	}
+	return 0, false
+}
+
+// compareForSort orders values of any type, placing unset values last.
+func compareForSort(a, b any) int {
+	if !truthy(a) || !truthy(b) {
+		return compareOrdered(boolInt(!truthy(a)), boolInt(!truthy(b)))
+	}
+	if c, ok := compareValues(a, b); ok {
+		return c
+	}
+	return strings.Compare(valueString(a), valueString(b))
+}
+
+func toTime(v any) (time.Time, bool) {
+	switch x := v.(type) {
+	case time.Time:
+		return x, true
+	case string:
+		t, _, err := ParseDateTime(x, nil)
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+func toNumber(v any) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(x), 64)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func compareOrdered[T int | float64](a, b T) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Lexer
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokDate
+	tokOperator
+	tokLParen
+	tokRParen
+	tokLBracket
+	tokRBracket
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lexQuery(src string) ([]token, error) {
+	var tokens []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		start := i
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			tokens = append(tokens, token{tokLParen, "(", start})
+			i++
+		case r == ')':
+			tokens = append(tokens, token{tokRParen, ")", start})
+			i++
+		case r == '[':
+			tokens = append(tokens, token{tokLBracket, "[", start})
+			i++
+		case r == ']':
+			tokens = append(tokens, token{tokRBracket, "]", start})
+			i++
+		case r == ',':
+			tokens = append(tokens, token{tokComma, ",", start})
+			i++
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i >= len(runes) {
+				return nil, AppError{Message: fmt.Sprintf("unterminated string at offset %d", start), Code: CodeInvalidQuery}
+			}
+			i++
+			tokens = append(tokens, token{tokString, b.String(), start})
+		case strings.ContainsRune("=!<>~", r):
+			op := string(r)
+			if i+1 < len(runes) {
+				switch two := string(runes[i : i+2]); two {
+				case "==", "!=", "<=", ">=", "!~":
+					op = two
+				}
+			}
+			if op == "!" {
+				return nil, AppError{Message: fmt.Sprintf("unknown operator %q at offset %d", op, start), Code: CodeInvalidQuery}
+			}
+			i += len(op)
+			if op == "==" {
+				op = "="
+			}
+			tokens = append(tokens, token{tokOperator, op, start})
+		case unicode.IsDigit(r) || (r == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			i++
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune(".:-+", runes[i])) {
+				i++
+			}
+			text := string(runes[start:i])
+			if _, err := strconv.ParseFloat(text, 64); err == nil {
+				tokens = append(tokens, token{tokNumber, text, start})
+			} else if _, _, err := ParseDateTime(text, nil); err == nil {
+				tokens = append(tokens, token{tokDate, text, start})
+			} else if _, err := time.Parse("2006-01-02T15:04", text); err == nil {
+				tokens = append(tokens, token{tokDate, text, start})
+			} else {
+				return nil, AppError{Message: fmt.Sprintf("invalid literal %q at offset %d", text, start), Code: CodeInvalidQuery}
+			}
+		case unicode.IsLetter(r) || r == '_':
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || strings.ContainsRune("_.-", runes[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokIdent, string(runes[start:i]), start})
+		default:
+			return nil, AppError{Message: fmt.Sprintf("unexpected character %q at offset %d", r, start), Code: CodeInvalidQuery}
+		}
+	}
+	return append(tokens, token{tokEOF, "", len(runes)}), nil
+}
+
+// Parser
+
+type queryParser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *queryParser) peek() token { return p.tokens[p.pos] }
+
+func (p *queryParser) next() token {
+	tok := p.tokens[p.pos]
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *queryParser) accept(kind tokenKind) bool {
+	if p.peek().kind == kind {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) peekKeyword(word string) bool {
+	tok := p.peek()
+	return tok.kind == tokIdent && strings.EqualFold(tok.text, word)
+}
+
+func (p *queryParser) acceptKeyword(word string) bool {
+	if p.peekKeyword(word) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *queryParser) errorf(format string, args ...any) error {
+	return AppError{Message: fmt.Sprintf(format, args...) + fmt.Sprintf(" at offset %d", p.peek().pos), Code: CodeInvalidQuery}
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("or") {
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "or", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.acceptKeyword("and") {
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{op: "and", left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseNot() (queryNode, error) {
+	if p.acceptKeyword("not") {
+		operand, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: operand}, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *queryParser) parseComparison() (queryNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+
+	var op string
+	switch {
+	case p.peek().kind == tokOperator:
+		op = p.next().text
+	case p.acceptKeyword("contains"):
+		op = "contains"
+	case p.acceptKeyword("in"):
+		op = "in"
+	case p.peekKeyword("not") && p.pos+1 < len(p.tokens) &&
+		p.tokens[p.pos+1].kind == tokIdent && strings.EqualFold(p.tokens[p.pos+1].text, "in"):
+		p.pos += 2
+		right, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{operand: compareNode{op: "in", left: left, right: right}}, nil
+	default:
+		return left, nil
+	}
+
+	right, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	node := compareNode{op: op, left: left, right: right}
+	if op == "~" || op == "!~" {
+		lit, ok := right.(literalNode)
+		if !ok {
+			return nil, p.errorf("the right side of %s must be a string", op)
+		}
+		node.pattern, err = regexp.Compile("(?i)" + valueString(lit.value))
+		if err != nil {
+			return nil, AppError{Message: "invalid regular expression", Value: err, Code: CodeInvalidQuery}
+		}
+	}
+	return node, nil
+}
+
+func (p *queryParser) parseOperand() (queryNode, error) {
+	tok := p.next()
+	switch tok.kind {
+	case tokLParen:
+		expr, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(tokRParen) {
+			return nil, p.errorf("expected ')'")
+		}
+		return expr, nil
+	case tokLBracket:
+		var items []queryNode
+		for !p.accept(tokRBracket) {
+			item, err := p.parseOperand()
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+			if !p.accept(tokComma) && p.peek().kind != tokRBracket {
+				return nil, p.errorf("expected ',' or ']'")
+			}
+		}
+		return listNode{items: items}, nil
+	case tokString:
+		return literalNode{value: tok.text}, nil
+	case tokNumber:
+		n, _ := strconv.ParseFloat(tok.text, 64)
+		return literalNode{value: n}, nil
+	case tokDate:
+		t, err := ParseTimeBound(tok.text, time.Local)
+		if err != nil {
+			return nil, err
+		}
+		return literalNode{value: t}, nil
+	case tokIdent:
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return literalNode{value: true}, nil
+		case "false":
+			return literalNode{value: false}, nil
+		case "null":
+			return literalNode{value: nil}, nil
+		case "now", "today":
+			t, _ := ParseTimeBound(tok.text, time.Local)
+			return literalNode{value: t}, nil
+		}
+		if !isEventField(tok.text) {
+			return nil, AppError{Message: fmt.Sprintf("unknown field %q at offset %d", tok.text, tok.pos), Code: CodeInvalidQuery}
+		}
+		return fieldNode{name: tok.text}, nil
+	case tokEOF:
+		return nil, p.errorf("unexpected end of query")
+	}
+	return nil, AppError{Message: fmt.Sprintf("unexpected %q at offset %d", tok.text, tok.pos), Code: CodeInvalidQuery}
+}
@@ -0,0 +1,743 @@
+package icaljson
+
+import (
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence frequencies (RFC 5545 §3.3.10).
+const (
+	FreqSecondly = "SECONDLY"
+	FreqMinutely = "MINUTELY"
+	FreqHourly   = "HOURLY"
+	FreqDaily    = "DAILY"
+	FreqWeekly   = "WEEKLY"
+	FreqMonthly  = "MONTHLY"
+	FreqYearly   = "YEARLY"
+)
+
+// maxRecurrencePeriods bounds the number of periods examined when expanding a
+// rule, so that rules which never match (e.g. BYMONTHDAY=30;BYMONTH=2) terminate.
+const maxRecurrencePeriods = 100000
+
+// WeekdayNum is a BYDAY entry such as "MO", "2TU" or "-1SU".
+type WeekdayNum struct {
+	Ordinal int // 0 means every such weekday in the period
+	Weekday time.Weekday
+}
+
+// Recur is a parsed RRULE value (RFC 5545 §3.3.10).
+type Recur struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	UntilDate  bool // UNTIL was given as a DATE value
+	BySecond   []int
+	ByMinute   []int
+	ByHour     []int
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByYearDay  []int
+	ByWeekNo   []int
+	ByMonth    []int
+	BySetPos   []int
+	WeekStart  time.Weekday
+}
+
+var weekdayCodes = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRecur parses an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;COUNT=10".
+// A floating or date UNTIL is interpreted in loc (UTC when nil).
+func ParseRecur(value string, loc *time.Location) (*Recur, error) {
+	if loc == nil {
+		loc = time.UTC
+	}
+	r := &Recur{Interval: 1, WeekStart: time.Monday}
+
+	for _, part := range strings.Split(strings.TrimPrefix(strings.TrimSpace(value), "RRULE:"), ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, AppError{Message: "invalid RRULE part", Value: part, Code: CodeInvalidRRule}
+		}
+		key = strings.ToUpper(key)
+		val = strings.ToUpper(val)
+
+		var err error
+		switch key {
+		case "FREQ":
+			switch val {
+			case FreqSecondly, FreqMinutely, FreqHourly, FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+				r.Freq = val
+			default:
+				return nil, AppError{Message: "invalid RRULE frequency", Value: val, Code: CodeInvalidRRule}
+			}
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(val)
+			if err == nil && r.Interval < 1 {
+				err = AppError{Message: "must be positive"}
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			var t time.Time
+			t, r.UntilDate, err = ParseDateTime(val, loc)
+			if r.UntilDate {
+				// A DATE UNTIL includes the whole day
+				t = t.AddDate(0, 0, 1).Add(-time.Second)
+			}
+			r.Until = t
+		case "BYSECOND":
+			r.BySecond, err = parseIntList(val, 0, 60, false)
+		case "BYMINUTE":
+			r.ByMinute, err = parseIntList(val, 0, 59, false)
+		case "BYHOUR":
+			r.ByHour, err = parseIntList(val, 0, 23, false)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseIntList(val, 1, 31, true)
+		case "BYYEARDAY":
+			r.ByYearDay, err = parseIntList(val, 1, 366, true)
+		case "BYWEEKNO":
+			r.ByWeekNo, err = parseIntList(val, 1, 53, true)
+		case "BYMONTH":
+			r.ByMonth, err = parseIntList(val, 1, 12, false)
+		case "BYSETPOS":
+			r.BySetPos, err = parseIntList(val, 1, 366, true)
+		case "BYDAY":
+			r.ByDay, err = parseWeekdayList(val)
+		case "WKST":
+			wd, ok := weekdayCodes[val]
+			if !ok {
+				err = AppError{Message: "unknown weekday"}
+			}
+			r.WeekStart = wd
+		}
+		if err != nil {
+			return nil, AppError{Message: "invalid RRULE " + key, Value: err, Code: CodeInvalidRRule}
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, AppError{Message: "RRULE without FREQ", Value: value, Code: CodeInvalidRRule}
+	}
+
+	return r, nil
+}
+
+func parseIntList(value string, lo, hi int, allowNegative bool) ([]int, error) {
+	var result []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimPrefix(item, "+"))
+		if err != nil {
+			return nil, err
+		}
+		abs := n
+		if allowNegative && n < 0 {
+			abs = -n
+		}
+		if abs < lo || abs > hi {
+			return nil, AppError{Message: "value out of range", Value: n}
+		}
+		result = append(result, n)
+	}
+	return result, nil
+}
+
+func parseWeekdayList(value string) ([]WeekdayNum, error) {
+	var result []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		if len(item) < 2 {
+			return nil, AppError{Message: "invalid weekday", Value: item}
+		}
+		wd, ok := weekdayCodes[item[len(item)-2:]]
+		if !ok {
+			return nil, AppError{Message: "invalid weekday", Value: item}
+		}
+		ordinal := 0
+		if prefix := strings.TrimPrefix(item[:len(item)-2], "+"); prefix != "" {
+			n, err := strconv.Atoi(prefix)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, AppError{Message: "invalid weekday ordinal", Value: item}
+			}
+			ordinal = n
+		}
+		result = append(result, WeekdayNum{Ordinal: ordinal, Weekday: wd})
+	}
+	return result, nil
+}
+
+// Iterate calls yield for each occurrence of the rule, in chronological order,
+// starting with dtstart, until the rule is exhausted or yield returns false.
+// Occurrences are computed in dtstart's location, so they keep their local
+// wall-clock time across DST changes.
+func (r *Recur) Iterate(dtstart time.Time, yield func(time.Time) bool) {
+	rule := r.normalized(dtstart)
+	emitted := 0
+	period := rule.periodStart(dtstart)
+
+	for i := 0; i < maxRecurrencePeriods; i++ {
+		candidates := rule.expandPeriod(period, dtstart)
+		for _, t := range candidates {
+			if t.Before(dtstart) {
+				continue
+			}
+			if !rule.Until.IsZero() && t.After(rule.Until) {
+				return
+			}
+			if !yield(t) {
+				return
+			}
+			emitted++
+			if rule.Count > 0 && emitted >= rule.Count {
+				return
+			}
+		}
+		period = rule.nextPeriod(period)
+		if period.Year() > 9999 {
+			return
+		}
+	}
+}
+
+// Between returns the occurrences starting in [from, to).
+func (r *Recur) Between(dtstart, from, to time.Time) []time.Time {
+	var result []time.Time
+	r.Iterate(dtstart, func(t time.Time) bool {
+		if !t.Before(to) {
+			return false
+		}
+		if !t.Before(from) {
+			result = append(result, t)
+		}
+		return true
+	})
+	return result
+}
+
+// normalized fills in the implicit BYxxx parts derived from dtstart.
+func (r *Recur) normalized(dtstart time.Time) Recur {
+	rule := *r
+	if rule.Interval < 1 {
+		rule.Interval = 1
+	}
+	if !rule.Until.IsZero() && !rule.UntilDate {
+		rule.Until = rule.Until.In(dtstart.Location())
+	}
+
+	if len(rule.ByWeekNo) == 0 && len(rule.ByYearDay) == 0 && len(rule.ByMonthDay) == 0 && len(rule.ByDay) == 0 {
+		switch rule.Freq {
+		case FreqYearly:
+			if len(rule.ByMonth) == 0 {
+				rule.ByMonth = []int{int(dtstart.Month())}
+			}
+			rule.ByMonthDay = []int{dtstart.Day()}
+		case FreqMonthly:
+			rule.ByMonthDay = []int{dtstart.Day()}
+		case FreqWeekly:
+			rule.ByDay = []WeekdayNum{{Weekday: dtstart.Weekday()}}
+		}
+	}
+	return rule
+}
+
+// periodStart truncates t to the start of its FREQ period.
+func (r *Recur) periodStart(t time.Time) time.Time {
+	loc := t.Location()
+	switch r.Freq {
+	case FreqYearly:
+		return time.Date(t.Year(), 1, 1, 0, 0, 0, 0, loc)
+	case FreqMonthly:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+	case FreqWeekly:
+		offset := (int(t.Weekday()) - int(r.WeekStart) + 7) % 7
+		return time.Date(t.Year(), t.Month(), t.Day()-offset, 0, 0, 0, 0, loc)
+	case FreqDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+	case FreqHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+	case FreqMinutely:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, loc)
+	default:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, loc)
+	}
+}
+
+func (r *Recur) nextPeriod(period time.Time) time.Time {
+	switch r.Freq {
+	case FreqYearly:
+		return period.AddDate(r.Interval, 0, 0)
+	case FreqMonthly:
+		return period.AddDate(0, r.Interval, 0)
+	case FreqWeekly:
+		return period.AddDate(0, 0, 7*r.Interval)
+	case FreqDaily:
+		return period.AddDate(0, 0, r.Interval)
+	case FreqHourly:
+		return period.Add(time.Duration(r.Interval) * time.Hour)
+	case FreqMinutely:
+		return period.Add(time.Duration(r.Interval) * time.Minute)
+	default:
+		return period.Add(time.Duration(r.Interval) * time.Second)
+	}
+}
+
+// expandPeriod returns the sorted occurrences within one FREQ period,
+// after BYSETPOS has been applied.
+func (r *Recur) expandPeriod(period, dtstart time.Time) []time.Time {
+	loc := dtstart.Location()
+
+	// Days covered by the period
+	var days []time.Time
+	switch r.Freq {
+	case FreqYearly:
+		for d := period; d.Year() == period.Year(); d = d.AddDate(0, 0, 1) {
+			days = append(days, d)
+		}
+	case FreqMonthly:
+		for d := period; d.Month() == period.Month(); d = d.AddDate(0, 0, 1) {
+			days = append(days, d)
+		}
+	case FreqWeekly:
+		for i := 0; i < 7; i++ {
+			days = append(days, period.AddDate(0, 0, i))
+		}
+	default:
+		days = append(days, time.Date(period.Year(), period.Month(), period.Day(), 0, 0, 0, 0, loc))
+	}
+
+	var result []time.Time
+	for _, day := range days {
+		if !r.matchesDay(day) {
+			continue
+		}
+		for _, h := range r.expandField(r.ByHour, FreqHourly, period.Hour(), dtstart.Hour()) {
+			for _, m := range r.expandField(r.ByMinute, FreqMinutely, period.Minute(), dtstart.Minute()) {
+				for _, s := range r.expandField(r.BySecond, FreqSecondly, period.Second(), dtstart.Second()) {
+					t := time.Date(day.Year(), day.Month(), day.Day(), h, m, s, 0, loc)
+					// Skip times that do not exist on this day (DST gaps shift them)
+					if t.Hour() != h && r.Freq != FreqHourly {
+						continue
+					}
+					result = append(result, t)
+				}
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+
+	return applySetPos(result, r.BySetPos)
+}
+
+// expandField returns the values of an hour/minute/second field for the period.
+// Frequencies at or below the field's own granularity fix it to the period's
+// value (limited by the BYxxx list), higher frequencies expand the BYxxx list.
+func (r *Recur) expandField(by []int, granularity string, periodValue, startValue int) []int {
+	if freqRank(r.Freq) <= freqRank(granularity) {
+		if len(by) > 0 && !slices.Contains(by, periodValue) {
+			return nil
+		}
+		return []int{periodValue}
+	}
+	if len(by) > 0 {
+		return by
+	}
+	return []int{startValue}
+}
+
+func freqRank(freq string) int {
+	switch freq {
+	case FreqSecondly:
+		return 0
+	case FreqMinutely:
+		return 1
+	case FreqHourly:
+		return 2
+	case FreqDaily:
+		return 3
+	case FreqWeekly:
+		return 4
+	case FreqMonthly:
+		return 5
+	default:
+		return 6
+	}
+}
+
+// matchesDay applies the date-level BYxxx rules to day.
+func (r *Recur) matchesDay(day time.Time) bool {
+	if len(r.ByMonth) > 0 && !slices.Contains(r.ByMonth, int(day.Month())) {
+		return false
+	}
+
+	if len(r.ByWeekNo) > 0 {
+		week, weeksInYear := weekNumber(day, r.WeekStart)
+		if !matchesOrdinal(r.ByWeekNo, week, weeksInYear) {
+			return false
+		}
+	}
+
+	if len(r.ByYearDay) > 0 {
+		daysInYear := time.Date(day.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		if !matchesOrdinal(r.ByYearDay, day.YearDay(), daysInYear) {
+			return false
+		}
+	}
+
+	if len(r.ByMonthDay) > 0 {
+		daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		if !matchesOrdinal(r.ByMonthDay, day.Day(), daysInMonth) {
+			return false
+		}
+	}
+
+	if len(r.ByDay) > 0 {
+		matched := false
+		for _, wd := range r.ByDay {
+			if wd.Weekday != day.Weekday() {
+				continue
+			}
+			if wd.Ordinal == 0 || r.matchesWeekdayOrdinal(day, wd.Ordinal) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	return true
+}
+
+// matchesWeekdayOrdinal checks "2TU"-style BYDAY entries. The ordinal counts
+// within the month for MONTHLY rules and YEARLY rules with BYMONTH, and within
+// the year otherwise. Other frequencies ignore ordinals.
+func (r *Recur) matchesWeekdayOrdinal(day time.Time, ordinal int) bool {
+	switch {
+	case r.Freq == FreqMonthly || (r.Freq == FreqYearly && len(r.ByMonth) > 0):
+		daysInMonth := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+		return ordinalWithin(day.Day()) == ordinal ||
+			negativeOrdinalWithin(day.Day(), daysInMonth) == ordinal
+	case r.Freq == FreqYearly:
+		daysInYear := time.Date(day.Year(), 12, 31, 0, 0, 0, 0, time.UTC).YearDay()
+		return ordinalWithin(day.YearDay()) == ordinal ||
+			negativeOrdinalWithin(day.YearDay(), daysInYear) == ordinal
+	default:
+		return true
+	}
+}
+
+// ordinalWithin returns n for the n-th occurrence of a weekday at position pos.
+func ordinalWithin(pos int) int {
+	return (pos-1)/7 + 1
+}
+
+// negativeOrdinalWithin returns -n for the n-th last occurrence of a weekday.
+func negativeOrdinalWithin(pos, length int) int {
+	return -((length-pos)/7 + 1)
+}
+
+// matchesOrdinal checks value (1-based) against a list that may contain
+// negative entries counting from the end of a range of the given length.
+func matchesOrdinal(list []int, value, length int) bool {
+	for _, n := range list {
+		if n == value || (n < 0 && length+n+1 == value) {
+			return true
+		}
+	}
+	return false
+}
+
+// weekNumber returns the RFC 5545 week number of day (week 1 is the first week
+// with at least four days in the year, weeks starting on wkst) and the number
+// of weeks in day's year.
+func weekNumber(day time.Time, wkst time.Weekday) (int, int) {
+	firstWeekStart := func(year int) time.Time {
+		jan1 := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+		offset := (int(jan1.Weekday()) - int(wkst) + 7) % 7
+		start := jan1.AddDate(0, 0, -offset)
+		if offset > 3 {
+			// Fewer than four days of the year in this week
+			start = start.AddDate(0, 0, 7)
+		}
+		return start
+	}
+
+	d := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	start := firstWeekStart(d.Year())
+	next := firstWeekStart(d.Year() + 1)
+	weeksInYear := int(next.Sub(start).Hours()/24) / 7
+
+	switch {
+	case d.Before(start):
+		prev := firstWeekStart(d.Year() - 1)
+		return int(d.Sub(prev).Hours()/24)/7 + 1, weeksInYear
+	case !d.Before(next):
+		return 1, weeksInYear
+	}
+	return int(d.Sub(start).Hours()/24)/7 + 1, weeksInYear
+}
+
+// applySetPos selects the BYSETPOS entries from the sorted period set.
+func applySetPos(set []time.Time, positions []int) []time.Time {
+	if len(positions) == 0 || len(set) == 0 {
+		return set
+	}
+
+	var result []time.Time
+	for _, pos := range positions {
+		idx := pos - 1
+		if pos < 0 {
+			idx = len(set) + pos
+		}
+		if idx >= 0 && idx < len(set) && !slices.ContainsFunc(result, set[idx].Equal) {
+			result = append(result, set[idx])
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Before(result[j]) })
+	return result
+}
+
+// Occurrence is a single instance of a (possibly recurring) event.
+type Occurrence struct {
+	Start time.Time
+	End   time.Time
+	// RecurrenceID identifies the instance within its series (the original start).
+	RecurrenceID time.Time
+}
+
+// IsRecurring reports whether the event has an RRULE or RDATEs.
+func (e Event) IsRecurring() bool {
+	return e.RRule != "" || len(e.RDates) > 0
+}
+
+// IsInfinite reports whether the event recurs forever: it has an RRULE with
+// neither COUNT nor UNTIL.
+func (e Event) IsInfinite() bool {
+	if e.RRule == "" {
+		return false
+	}
+	rule, err := ParseRecur(e.RRule, nil)
+	return err == nil && rule.Count == 0 && rule.Until.IsZero()
+}
+
+// requireWindowEnd rejects an expansion of events without an end of the
+// window when one of them recurs forever; it would only stop at the
+// maxRecurrencePeriods cap.
+func requireWindowEnd(events []Event, to time.Time) error {
+	if !to.IsZero() {
+		return nil
+	}
+	for _, e := range events {
+		if e.RecurrenceID == "" && e.IsInfinite() {
+			return AppError{Message: "expanding an event that recurs forever needs an end of the window (--to)", Value: e.UID, Code: CodeInvalidArgument}
+		}
+	}
+	return nil
+}
+
+// Occurrences returns the instances of the event that overlap [from, to),
+// expanding RRULE and RDATE and removing EXDATE instances.
+// Zero from/to values leave that side of the window open; an open-ended
+// window on an infinite rule is cut off after a bounded number of periods.
+func (e Event) Occurrences(from, to time.Time) ([]Occurrence, error) {
+	var result []Occurrence
+	err := e.eachOccurrence(from, to, func(o Occurrence) bool {
+		result = append(result, o)
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Start.Before(result[j].Start) })
+	return result, nil
+}
+
+// eachOccurrence calls yield for the instances of the event that overlap
+// [from, to), see Occurrences, until yield returns false. RRULE instances come
+// in chronological order, followed by RDATE instances.
+func (e Event) eachOccurrence(from, to time.Time, yield func(Occurrence) bool) error {
+	start, err := e.StartTime()
+	if err != nil {
+		return err
+	}
+	end, err := e.EndTime()
+	if err != nil {
+		return err
+	}
+	length := end.Sub(start)
+	allDay := e.IsAllDay()
+
+	overlaps := func(s, e time.Time) bool {
+		if !to.IsZero() && !s.Before(to) {
+			return false
+		}
+		if from.IsZero() {
+			return true
+		}
+		return e.After(from) || (s.Equal(e) && !s.Before(from))
+	}
+	occurrence := func(s time.Time) Occurrence {
+		if allDay {
+			return Occurrence{Start: s, End: s.AddDate(0, 0, int(length/(24*time.Hour))), RecurrenceID: s}
+		}
+		return Occurrence{Start: s, End: addDuration(s, length), RecurrenceID: s}
+	}
+
+	if !e.IsRecurring() {
+		if overlaps(start, end) {
+			yield(occurrence(start))
+		}
+		return nil
+	}
+
+	excluded := e.exceptionMatcher()
+	seen := map[int64]struct{}{}
+	stopped := false
+	// add reports whether the expansion should continue
+	add := func(s time.Time) bool {
+		if excluded(s) {
+			return true
+		}
+		if _, ok := seen[s.UnixNano()]; ok {
+			return true
+		}
+		seen[s.UnixNano()] = struct{}{}
+		if o := occurrence(s); overlaps(o.Start, o.End) && !yield(o) {
+			stopped = true
+		}
+		return !stopped
+	}
+
+	if e.RRule != "" {
+		rule, err := ParseRecur(e.RRule, start.Location())
+		if err != nil {
+			return err
+		}
+		rule.Iterate(start, func(s time.Time) bool {
+			if !to.IsZero() && !s.Before(to) {
+				return false
+			}
+			return add(s)
+		})
+	} else {
+		add(start)
+	}
+
+	for _, rdate := range e.RDates {
+		if stopped {
+			break
+		}
+		value, _, _ := strings.Cut(rdate, "/")
+		if t, _, err := ParseDateTime(value, start.Location()); err == nil {
+			add(t.In(start.Location()))
+		}
+	}
+	return nil
+}
+
+// exceptionMatcher returns a predicate matching EXDATE instances.
+// Date-only EXDATEs exclude every instance on that day.
+func (e Event) exceptionMatcher() func(time.Time) bool {
+	loc := e.TimeZone()
+	var instants []time.Time
+	var dates []string
+	for _, exdate := range e.ExDates {
+		t, allDay, err := ParseDateTime(exdate, loc)
+		if err != nil {
+			continue
+		}
+		if allDay {
+			dates = append(dates, t.Format(layoutDate))
+		} else {
+			instants = append(instants, t)
+		}
+	}
+
+	return func(t time.Time) bool {
+		for _, ex := range instants {
+			if ex.Equal(t) {
+				return true
+			}
+		}
+		return slices.Contains(dates, t.Format(layoutDate))
+	}
+}
+
+// ExpandEvents replaces recurring events with their individual instances
+// overlapping [from, to). Instances keep the master's properties, carry a
+// RECURRENCE-ID and are replaced by overriding components (same UID with a
+// RECURRENCE-ID) where present. Events whose start cannot be parsed are dropped.
+func ExpandEvents(events []Event, from, to time.Time) ([]Event, error) {
+	overrides := map[string][]Event{}
+	for _, event := range events {
+		if event.RecurrenceID != "" {
+			overrides[event.UID] = append(overrides[event.UID], event)
+		}
+	}
+
+	var result []Event
+	for _, event := range events {
+		if event.RecurrenceID != "" {
+			// Overrides are emitted on their own schedule
+			if occ, err := event.Occurrences(from, to); err == nil && len(occ) > 0 {
+				result = append(result, event)
+			}
+			continue
+		}
+
+		occurrences, err := event.Occurrences(from, to)
+		if err != nil {
+			continue
+		}
+		for _, occ := range occurrences {
+			if event.IsRecurring() && isOverridden(overrides[event.UID], occ.RecurrenceID) {
+				continue
+			}
+			result = append(result, event.instance(occ))
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		a, _ := result[i].StartTime()
+		b, _ := result[j].StartTime()
+		return a.Before(b)
+	})
+	return result, nil
+}
+
+func isOverridden(overrides []Event, recurrenceID time.Time) bool {
+	for _, override := range overrides {
+		t, _, err := ParseDateTime(override.RecurrenceID, override.TimeZone())
+		if err == nil && t.Equal(recurrenceID) {
+			return true
+		}
+	}
+	return false
+}
+
+// instance materialises one occurrence of a recurring event.
+func (e Event) instance(occ Occurrence) Event {
+	if !e.IsRecurring() {
+		return e
+	}
+	inst := e
+	inst.Start = formatLike(occ.Start, e.Start)
+	if e.End != "" {
+		inst.End = formatLike(occ.End, e.End)
+	}
+	inst.RecurrenceID = formatLike(occ.RecurrenceID, e.Start)
+	inst.RRule = ""
+	inst.RDates = nil
+	inst.ExDates = nil
+	return inst
+}
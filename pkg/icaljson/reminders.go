@@ -0,0 +1,499 @@
+package icaljson
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/beyondcivic/icaljson/pkg/version"
+)
+
+// Default reminder scheduler settings.
+const (
+	DefaultReminderInterval = time.Minute
+	DefaultReminderGrace    = 15 * time.Minute
+)
+
+// Reminder is one firing of a VALARM: its trigger or one of its repetitions.
+type Reminder struct {
+	ID          string    `json:"id"`                    // Stable identifier, used to fire each reminder once
+	Time        time.Time `json:"time"`                  // When the alarm fires
+	Repetition  int       `json:"repetition,omitempty"`  // 0 for the trigger, 1 to REPEAT for its repetitions
+	Action      string    `json:"action"`                // AUDIO, DISPLAY, EMAIL, ...
+	Summary     string    `json:"summary,omitempty"`     // Alarm SUMMARY, or the summary of the event
+	Description string    `json:"description,omitempty"` // Alarm DESCRIPTION, or the summary of the event
+	Attendees   []string  `json:"attendees,omitempty"`   // Addresses of EMAIL alarms
+	Start       time.Time `json:"start"`                 // Start of the occurrence the alarm belongs to
+	End         time.Time `json:"end"`                   // End of the occurrence, or the DUE time of a task
+	Event       EventRef  `json:"event"`
+}
+
+// Reminders returns the alarms of the calendar's events and tasks that fire
+// within [from, to), sorted by time. Recurring events are expanded, honouring
+// overrides and EXDATEs, and each occurrence gets its own reminders. Relative
+// triggers are computed from the start of the occurrence, or from its end
+// (the DUE time of a task) with RELATED=END; absolute triggers fire once.
+// REPEAT and DURATION add repetitions after the trigger. Cancelled events,
+// completed or cancelled tasks and firings before the alarm's ACKNOWLEDGED
+// time are skipped. Floating date-times are taken in the time zone of from.
+func (c *Calendar) Reminders(from, to time.Time) ([]Reminder, error) {
+	if from.IsZero() || to.IsZero() || !from.Before(to) {
+		return nil, AppError{Message: "reminders need a window with a start before its end", Code: CodeInvalidArgument}
+	}
+
+	components := slices.Clone(c.Events)
+	for _, t := range c.Todos {
+		if strings.EqualFold(t.Status, "COMPLETED") || t.Completed != "" {
+			continue
+		}
+		// Tasks are expanded like events that end when they are due
+		e := t.Event
+		if t.Due != "" {
+			e.Start = cmp.Or(e.Start, t.Due)
+			e.End, e.Duration = t.Due, ""
+		}
+		components = append(components, e)
+	}
+
+	// Expand far enough to catch the occurrences whose alarms fire in the window
+	var span time.Duration
+	for _, e := range components {
+		for _, a := range e.Alarms {
+			if offset, err := ParseDuration(a.Trigger); err == nil {
+				span = max(span, offset.Abs()+alarmRepetitions(a))
+			}
+		}
+	}
+	instances, err := ExpandEvents(components, from.Add(-span), to.Add(span))
+	if err != nil {
+		return nil, err
+	}
+
+	reminders := []Reminder{}
+	add := func(e Event, a Alarm, trigger time.Time, start, end time.Time) {
+		interval, _ := ParseDuration(a.Duration)
+		if a.Repeat == 0 || interval <= 0 {
+			a.Repeat = 0
+		}
+		acknowledged, _ := time.Parse(time.RFC3339, a.Acknowledged)
+		for i := 0; i <= a.Repeat; i++ {
+			at := trigger.Add(time.Duration(i) * interval)
+			if at.Before(from) || !at.Before(to) || !at.After(acknowledged) {
+				continue
+			}
+			reminders = append(reminders, Reminder{
+				ID:          reminderID(e, a, at),
+				Time:        at.UTC(),
+				Repetition:  i,
+				Action:      a.Action,
+				Summary:     cmp.Or(a.Summary, e.Summary),
+				Description: cmp.Or(a.Description, e.Summary),
+				Attendees:   alarmAddresses(a),
+				Start:       start.UTC(),
+				End:         end.UTC(),
+				Event:       eventRef(e),
+			})
+		}
+	}
+
+	for _, e := range instances {
+		if strings.EqualFold(e.Status, "CANCELLED") {
+			continue
+		}
+		start, end, err := eventInterval(e, from.Location())
+		if err != nil {
+			continue
+		}
+		for _, a := range e.Alarms {
+			offset, err := ParseDuration(a.Trigger)
+			if err != nil {
+				continue // Absolute triggers are handled below
+			}
+			related := start
+			if strings.EqualFold(a.Related, "END") {
+				related = end
+			}
+			if e.TZID != "" {
+				// Days are nominal, so that reminders keep their wall-clock time across DST changes
+				related = related.In(e.TimeZone())
+			}
+			add(e, a, addDuration(related, offset), start, end)
+		}
+	}
+
+	// Absolute triggers fire once, whichever occurrence is in the window
+	for _, e := range components {
+		if strings.EqualFold(e.Status, "CANCELLED") {
+			continue
+		}
+		for _, a := range e.Alarms {
+			trigger, err := time.Parse(time.RFC3339, a.Trigger)
+			if err != nil {
+				continue
+			}
+			start, end, _ := eventInterval(e, from.Location())
+			add(e, a, trigger, start, end)
+		}
+	}
+
+	slices.SortStableFunc(reminders, func(a, b Reminder) int {
+		return a.Time.Compare(b.Time)
+	})
+	return reminders, nil
+}
+
+// alarmRepetitions returns the time between an alarm's trigger and its last repetition.
+func alarmRepetitions(a Alarm) time.Duration {
+	interval, err := ParseDuration(a.Duration)
+	if err != nil || interval <= 0 {
+		return 0
+	}
+	return time.Duration(a.Repeat) * interval
+}
+
+// alarmAddresses returns the addresses of an alarm's attendees.
+func alarmAddresses(a Alarm) []string {
+	var addresses []string
+	for _, attendee := range a.Attendees {
+		addresses = append(addresses, calAddress(attendee.Value))
+	}
+	return addresses
+}
+
+// reminderID identifies a firing by the event occurrence, the alarm and the
+// time, so that a rescheduled event fires again but a reloaded one does not.
+func reminderID(e Event, a Alarm, at time.Time) string {
+	alarm := cmp.Or(a.UID, a.Action+"|"+a.Related+"|"+a.Trigger)
+	occurrence := cmp.Or(e.RecurrenceID, e.Start)
+	sum := sha256.Sum256([]byte(strings.Join([]string{e.UID, occurrence, alarm, at.UTC().Format(time.RFC3339)}, "\x00")))
+	return hex.EncodeToString(sum[:16])
+}
+
+// WriteRemindersText writes reminders as a human-readable list.
+func WriteRemindersText(w io.Writer, reminders []Reminder) error {
+	if len(reminders) == 0 {
+		_, err := fmt.Fprintln(w, "No reminders.")
+		return err
+	}
+	for _, r := range reminders {
+		line := fmt.Sprintf("%s  %-8s %s", r.Time.Format("Mon 02 Jan 2006 15:04 MST"), r.Action, cmp.Or(r.Summary, r.Event.UID))
+		if r.Repetition > 0 {
+			line += fmt.Sprintf(" (repeat %d)", r.Repetition)
+		}
+		line += "  starts " + r.Start.In(r.Time.Location()).Format("Mon 02 Jan 15:04")
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DefaultReminderStatePath returns the per-user file recording fired reminders.
+func DefaultReminderStatePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, version.AppName, "reminders.json")
+}
+
+// ReminderState records the reminders that have fired, so that no reminder
+// fires twice, even across restarts when it is saved to a file.
+type ReminderState struct {
+	Fired map[string]time.Time `json:"fired"` // Firing time by reminder ID
+
+	path string
+}
+
+// LoadReminderState reads the state saved at path. A missing file is an
+// empty state; an empty path keeps the state in memory only.
+func LoadReminderState(path string) (*ReminderState, error) {
+	state := &ReminderState{Fired: map[string]time.Time{}, path: path}
+	if path == "" {
+		return state, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, AppError{Message: "failed to read reminder state", Value: err, Code: CodeReadFailed}
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, AppError{Message: "failed to parse reminder state", Value: err, Code: CodeInvalidJSON}
+	}
+	if state.Fired == nil {
+		state.Fired = map[string]time.Time{}
+	}
+	return state, nil
+}
+
+// HasFired reports whether the reminder with the given ID has fired.
+func (s *ReminderState) HasFired(id string) bool {
+	_, ok := s.Fired[id]
+	return ok
+}
+
+// MarkFired records that a reminder has fired.
+func (s *ReminderState) MarkFired(r Reminder) {
+	s.Fired[r.ID] = r.Time
+}
+
+// Prune forgets reminders that fired before t and reports whether any were removed.
+func (s *ReminderState) Prune(t time.Time) bool {
+	n := len(s.Fired)
+	for id, fired := range s.Fired {
+		if fired.Before(t) {
+			delete(s.Fired, id)
+		}
+	}
+	return len(s.Fired) != n
+}
+
+// Save writes the state to the file it was loaded from, if any.
+func (s *ReminderState) Save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return AppError{Message: "failed to marshal reminder state", Value: err, Code: CodeWriteFailed}
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0750); err != nil {
+		return AppError{Message: "failed to create directory", Value: err, Code: CodeWriteFailed}
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// ReminderHook acts on a due reminder.
+type ReminderHook func(ctx context.Context, r Reminder) error
+
+// JSONHook writes each reminder to w as one line of JSON.
+func JSONHook(w io.Writer) ReminderHook {
+	return func(ctx context.Context, r Reminder) error {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return AppError{Message: "failed to marshal reminder", Value: err, Code: CodeWriteFailed}
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			return AppError{Message: "failed to write reminder", Value: err, Code: CodeWriteFailed}
+		}
+		return nil
+	}
+}
+
+// CommandHook runs command with the shell (cmd on Windows) for each reminder. The reminder is
+// passed as JSON on standard input and in ICALJSON_REMINDER_* environment
+// variables (ID, TIME, ACTION, SUMMARY, DESCRIPTION, START, UID). The
+// command's output goes to stdout and stderr.
+func CommandHook(command string, stdout, stderr io.Writer) ReminderHook {
+	return func(ctx context.Context, r Reminder) error {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return AppError{Message: "failed to marshal reminder", Value: err, Code: CodeWriteFailed}
+		}
+		shell := []string{"sh", "-c"}
+		if runtime.GOOS == "windows" {
+			shell = []string{"cmd", "/C"}
+		}
+		cmd := exec.CommandContext(ctx, shell[0], shell[1], command)
+		cmd.Stdin = bytes.NewReader(data)
+		cmd.Stdout, cmd.Stderr = stdout, stderr
+		cmd.Env = append(os.Environ(),
+			"ICALJSON_REMINDER_ID="+r.ID,
+			"ICALJSON_REMINDER_TIME="+r.Time.Format(time.RFC3339),
+			"ICALJSON_REMINDER_ACTION="+r.Action,
+			"ICALJSON_REMINDER_SUMMARY="+r.Summary,
+			"ICALJSON_REMINDER_DESCRIPTION="+r.Description,
+			"ICALJSON_REMINDER_START="+r.Start.Format(time.RFC3339),
+			"ICALJSON_REMINDER_UID="+r.Event.UID,
+		)
+		if err := cmd.Run(); err != nil {
+			return AppError{Message: "reminder command failed", Value: err, Code: CodeWriteFailed}
+		}
+		return nil
+	}
+}
+
+// WebhookHook POSTs each reminder as JSON to url. Responses other than 2xx
+// are errors. A nil client uses one with DefaultFetchTimeout.
+func WebhookHook(url string, client *http.Client) ReminderHook {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultFetchTimeout}
+	}
+	return func(ctx context.Context, r Reminder) error {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return AppError{Message: "failed to marshal reminder", Value: err, Code: CodeWriteFailed}
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+		if err != nil {
+			return AppError{Message: "failed to create webhook request", Value: err, Code: CodeInvalidArgument}
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", version.AppName+"/"+version.Version)
+		resp, err := client.Do(req)
+		if err != nil {
+			return AppError{Message: "webhook request failed", Value: err, Code: CodeWriteFailed}
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return AppError{Message: "webhook request failed", Value: resp.Status, Code: CodeWriteFailed}
+		}
+		return nil
+	}
+}
+
+// ReminderScheduler fires the reminders of calendars when they are due.
+type ReminderScheduler struct {
+	// Load returns the calendars to watch. It is called on start and every
+	// Interval, so that changes to the source files or feeds are picked up.
+	Load func(ctx context.Context) ([]*Calendar, error)
+	Hook ReminderHook
+	// State records the fired reminders (default: an in-memory state).
+	State *ReminderState
+	// Interval between reloads of the calendars (default: DefaultReminderInterval).
+	Interval time.Duration
+	// Grace is how late a reminder still fires, e.g. one that was due while
+	// the scheduler was stopped or whose hook failed (default: DefaultReminderGrace).
+	Grace time.Duration
+	// Location is the time zone of floating date-times (default: local time).
+	Location *time.Location
+	// OnError reports errors that do not stop the scheduler: failed reloads
+	// (the previous calendars are kept) and failed hooks (retried on reload).
+	OnError func(error)
+	// Now returns the current time (default: time.Now).
+	Now func() time.Time
+}
+
+// Run fires reminders as they become due until ctx is cancelled. It returns
+// nil on cancellation, or the error of the first Load or of saving the state.
+func (s *ReminderScheduler) Run(ctx context.Context) error {
+	s.defaults()
+	var calendars []*Calendar
+	var loaded time.Time
+	for {
+		now := s.Now()
+		if loaded.IsZero() || !now.Before(loaded.Add(s.Interval)) {
+			reloaded, err := s.Load(ctx)
+			switch {
+			case err == nil:
+				calendars = reloaded
+			case loaded.IsZero():
+				return err
+			default:
+				s.OnError(err)
+			}
+			loaded = now
+		}
+
+		next, err := s.fire(ctx, calendars, now)
+		if err != nil {
+			return err
+		}
+		wake := loaded.Add(s.Interval)
+		if !next.IsZero() && next.Before(wake) {
+			wake = next
+		}
+		timer := time.NewTimer(wake.Sub(s.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+	}
+}
+
+// Poll loads the calendars once and fires the reminders that are due, e.g.
+// from cron. It returns the time of the next reminder within Interval, or the
+// zero time.
+func (s *ReminderScheduler) Poll(ctx context.Context) (time.Time, error) {
+	s.defaults()
+	calendars, err := s.Load(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.fire(ctx, calendars, s.Now())
+}
+
+func (s *ReminderScheduler) defaults() {
+	if s.State == nil {
+		s.State, _ = LoadReminderState("")
+	}
+	if s.Interval <= 0 {
+		s.Interval = DefaultReminderInterval
+	}
+	if s.Grace <= 0 {
+		s.Grace = DefaultReminderGrace
+	}
+	if s.Location == nil {
+		s.Location = time.Local
+	}
+	if s.OnError == nil {
+		s.OnError = func(error) {}
+	}
+	if s.Now == nil {
+		s.Now = time.Now
+	}
+}
+
+// fire runs the hook for the reminders due at now that have not fired yet,
+// and returns the time of the next one within Interval.
+func (s *ReminderScheduler) fire(ctx context.Context, calendars []*Calendar, now time.Time) (time.Time, error) {
+	var reminders []Reminder
+	for _, calendar := range calendars {
+		found, err := calendar.Reminders(now.Add(-s.Grace).In(s.Location), now.Add(s.Interval).In(s.Location))
+		if err != nil {
+			return time.Time{}, err
+		}
+		reminders = append(reminders, found...)
+	}
+	slices.SortStableFunc(reminders, func(a, b Reminder) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	var next time.Time
+	for _, r := range reminders {
+		if s.State.HasFired(r.ID) {
+			continue
+		}
+		if r.Time.After(now) {
+			if next.IsZero() {
+				next = r.Time
+			}
+			continue
+		}
+		if err := s.Hook(ctx, r); err != nil {
+			s.OnError(err)
+			continue
+		}
+		s.State.MarkFired(r)
+		if err := s.State.Save(); err != nil {
+			return time.Time{}, err
+		}
+	}
+	// Reminders older than the grace period can no longer fire
+	if s.State.Prune(now.Add(-s.Grace)) {
+		if err := s.State.Save(); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return next, nil
+}
@@ -0,0 +1,203 @@
+package icaljson
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testReminders = "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//test//EN\r\n" +
+	"BEGIN:VEVENT\r\nUID:standup\r\nSUMMARY:Standup\r\nDTSTART:20251006T090000Z\r\nDTEND:20251006T100000Z\r\n" +
+	"BEGIN:VALARM\r\nACTION:AUDIO\r\nTRIGGER:-PT15M\r\nREPEAT:2\r\nDURATION:PT5M\r\nEND:VALARM\r\n" +
+	"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Wrap up\r\nTRIGGER;RELATED=END:-PT5M\r\nEND:VALARM\r\n" +
+	"END:VEVENT\r\nEND:VCALENDAR\r\n"
+
+func parseTestReminders(t *testing.T) *Calendar {
+	t.Helper()
+	calendar, err := ParseWithOptions(strings.NewReader(testReminders), ParseOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return calendar
+}
+
+func testTime(t *testing.T, value string) time.Time {
+	t.Helper()
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return at
+}
+
+func TestRemindersRelatedEndAndRepeat(t *testing.T) {
+	calendar := parseTestReminders(t)
+	reminders, err := calendar.Reminders(testTime(t, "2025-10-06T00:00:00Z"), testTime(t, "2025-10-07T00:00:00Z"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct {
+		time       string
+		action     string
+		repetition int
+	}{
+		{"2025-10-06T08:45:00Z", "AUDIO", 0},
+		{"2025-10-06T08:50:00Z", "AUDIO", 1},
+		{"2025-10-06T08:55:00Z", "AUDIO", 2},
+		{"2025-10-06T09:55:00Z", "DISPLAY", 0},
+	}
+	if len(reminders) != len(want) {
+		t.Fatalf("got %d reminders, want %d: %+v", len(reminders), len(want), reminders)
+	}
+	for i, w := range want {
+		r := reminders[i]
+		if r.Time.Format(time.RFC3339) != w.time || r.Action != w.action || r.Repetition != w.repetition {
+			t.Errorf("reminder %d: got %s %s repeat %d, want %s %s repeat %d", i, r.Time.Format(time.RFC3339), r.Action, r.Repetition, w.time, w.action, w.repetition)
+		}
+	}
+}
+
+func TestWebhookHook(t *testing.T) {
+	var received []Reminder
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var reminder Reminder
+		if err := json.NewDecoder(r.Body).Decode(&reminder); err != nil {
+			t.Error(err)
+		}
+		received = append(received, reminder)
+		if r.URL.Path == "/down" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	reminder := Reminder{ID: "1", Action: "DISPLAY", Summary: "Standup", Event: EventRef{UID: "standup"}}
+	if err := WebhookHook(srv.URL+"/hook", srv.Client())(context.Background(), reminder); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].ID != "1" || received[0].Event.UID != "standup" {
+		t.Errorf("received %+v", received)
+	}
+
+	err := WebhookHook(srv.URL+"/down", srv.Client())(context.Background(), reminder)
+	if !errors.Is(err, ErrWrite) || !strings.Contains(err.Error(), "503") {
+		t.Errorf("got %v, want a write error with the status", err)
+	}
+}
+
+func TestPollFiresOnceAcrossRuns(t *testing.T) {
+	calendar := parseTestReminders(t)
+	statePath := filepath.Join(t.TempDir(), "reminders.json")
+	now := testTime(t, "2025-10-06T09:00:00Z")
+
+	poll := func() []Reminder {
+		t.Helper()
+		state, err := LoadReminderState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var fired []Reminder
+		s := &ReminderScheduler{
+			Load: func(ctx context.Context) ([]*Calendar, error) {
+				return []*Calendar{calendar}, nil
+			},
+			Hook: func(ctx context.Context, r Reminder) error {
+				fired = append(fired, r)
+				return nil
+			},
+			State:    state,
+			Interval: time.Hour,
+			Location: time.UTC,
+			Now:      func() time.Time { return now },
+		}
+		next, err := s.Poll(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := testTime(t, "2025-10-06T09:55:00Z"); !next.Equal(want) {
+			t.Errorf("next = %v, want %v", next, want)
+		}
+		return fired
+	}
+
+	if fired := poll(); len(fired) != 3 {
+		t.Fatalf("first run fired %d reminders, want 3", len(fired))
+	}
+	if fired := poll(); len(fired) != 0 {
+		t.Errorf("second run fired %d reminders again", len(fired))
+	}
+}
+
+func TestPollRetriesFailedHooks(t *testing.T) {
+	calendar := parseTestReminders(t)
+	var errs []error
+	s := &ReminderScheduler{
+		Load: func(ctx context.Context) ([]*Calendar, error) {
+			return []*Calendar{calendar}, nil
+		},
+		Hook: func(ctx context.Context, r Reminder) error {
+			return AppError{Message: "webhook request failed", Code: CodeWriteFailed}
+		},
+		Location: time.UTC,
+		OnError:  func(err error) { errs = append(errs, err) },
+		Now:      func() time.Time { return testTime(t, "2025-10-06T08:45:00Z") },
+	}
+	if _, err := s.Poll(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 1 || len(s.State.Fired) != 0 {
+		t.Errorf("got errors %v and fired %v, want one error and nothing marked as fired", errs, s.State.Fired)
+	}
+}
+
+func TestPollPrunesAfterGrace(t *testing.T) {
+	calendar := parseTestReminders(t)
+	statePath := filepath.Join(t.TempDir(), "reminders.json")
+
+	poll := func(now string) {
+		t.Helper()
+		state, err := LoadReminderState(statePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &ReminderScheduler{
+			Load: func(ctx context.Context) ([]*Calendar, error) {
+				return []*Calendar{calendar}, nil
+			},
+			Hook:     func(ctx context.Context, r Reminder) error { return nil },
+			State:    state,
+			Grace:    15 * time.Minute,
+			Location: time.UTC,
+			Now:      func() time.Time { return testTime(t, now) },
+		}
+		if _, err := s.Poll(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	poll("2025-10-06T09:00:00Z")
+	poll("2025-10-06T10:00:00Z")
+
+	state, err := LoadReminderState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(state.Fired) != 1 {
+		t.Fatalf("state has %d reminders, want only the one within the grace period", len(state.Fired))
+	}
+	for _, fired := range state.Fired {
+		if want := testTime(t, "2025-10-06T09:55:00Z"); !fired.Equal(want) {
+			t.Errorf("kept the reminder of %v, want %v", fired, want)
+		}
+	}
+}
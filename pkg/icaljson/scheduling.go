@@ -0,0 +1,208 @@
+package icaljson
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// Participation statuses of event attendees (RFC 5545 §3.2.12).
+const (
+	PartStatNeedsAction = "NEEDS-ACTION"
+	PartStatAccepted    = "ACCEPTED"
+	PartStatDeclined    = "DECLINED"
+	PartStatTentative   = "TENTATIVE"
+	PartStatDelegated   = "DELEGATED"
+)
+
+// PartStats lists the participation statuses accepted by BuildReply.
+var PartStats = []string{PartStatAccepted, PartStatDeclined, PartStatTentative, PartStatDelegated, PartStatNeedsAction}
+
+// rescheduleFields are the properties whose change makes a REQUEST a new
+// revision that attendees have to answer again (RFC 5546 §2.1.4).
+var rescheduleFields = []string{"start", "end", "duration", "rrule", "rdates", "exdates"}
+
+// FindEvent returns the event with the given UID and RECURRENCE-ID (empty
+// for the master event). Tasks are not searched: the scheduling builders
+// write VEVENTs only.
+func (c *Calendar) FindEvent(uid, recurrenceID string) (Event, bool) {
+	for _, e := range c.Events {
+		if e.UID == uid && sameInstant(e.RecurrenceID, recurrenceID) {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+// HasTodo reports whether the calendar has a task with the given UID.
+func (c *Calendar) HasTodo(uid string) bool {
+	return slices.ContainsFunc(c.Todos, func(t Todo) bool { return t.UID == uid })
+}
+
+// FindOccurrence returns the override of the occurrence at recurrenceID or,
+// without one, the occurrence derived from the master event.
+func (c *Calendar) FindOccurrence(uid, recurrenceID string) (Event, bool, error) {
+	if e, ok := c.FindEvent(uid, recurrenceID); ok || recurrenceID == "" {
+		return e, ok, nil
+	}
+	master, ok := c.FindEvent(uid, "")
+	if !ok {
+		return Event{}, false, nil
+	}
+	instance, err := occurrenceOf(master, recurrenceID)
+	return instance, err == nil, err
+}
+
+// BuildRequest returns a REQUEST message inviting the attendees of event.
+// With the previously sent revision, SEQUENCE is incremented when the event
+// was rescheduled (DTSTART, DTEND, DURATION, RRULE, RDATE or EXDATE changed)
+// and the attendees are asked to respond again; other updates keep SEQUENCE.
+// DTSTAMP is set to the current time.
+func BuildRequest(event Event, previous *Event) (*Calendar, error) {
+	if err := checkOrganizer(event, MethodRequest); err != nil {
+		return nil, err
+	}
+	if len(event.Attendees) == 0 {
+		return nil, AppError{Message: "REQUEST needs at least one ATTENDEE", Value: event.UID, Code: CodeInvalidArgument}
+	}
+
+	msg := event
+	msg.Source = ""
+	msg.DTStamp = itipTimestamp()
+	rescheduled := false
+	if previous != nil {
+		fields, err := eventFieldChanges(*previous, event)
+		if err != nil {
+			return nil, err
+		}
+		rescheduled = slices.ContainsFunc(fields, func(f FieldChange) bool {
+			return slices.Contains(rescheduleFields, f.Field)
+		})
+		msg.Sequence = max(msg.Sequence, previous.Sequence)
+		if rescheduled && msg.Sequence == previous.Sequence {
+			msg.Sequence++
+		}
+	}
+
+	msg.Attendees = make([]Property, len(event.Attendees))
+	for i, attendee := range event.Attendees {
+		params := schedulingParams(attendee.Params)
+		if rescheduled && !sameCalAddress(attendee.Value, event.Organizer) {
+			params["PARTSTAT"] = PartStatNeedsAction
+			params["RSVP"] = "TRUE"
+		}
+		msg.Attendees[i] = Property{Value: attendee.Value, Params: params}
+	}
+	return itipMessage(MethodRequest, msg), nil
+}
+
+// BuildReply returns a REPLY message in which attendee answers event with
+// partstat, one of PartStats. The attendee's parameters (CN, ROLE, ...) are
+// taken from the event; an attendee who was not invited is added. SEQUENCE
+// is the one of the event being answered, DTSTAMP the current time.
+func BuildReply(event Event, attendee, partstat string) (*Calendar, error) {
+	if err := checkOrganizer(event, MethodReply); err != nil {
+		return nil, err
+	}
+	partstat = strings.ToUpper(strings.TrimSpace(partstat))
+	if !slices.Contains(PartStats, partstat) {
+		return nil, AppError{Message: "invalid PARTSTAT, expected one of " + strings.Join(PartStats, ", "), Value: partstat, Code: CodeInvalidArgument}
+	}
+	if strings.TrimSpace(attendee) == "" {
+		return nil, AppError{Message: "REPLY needs the replying attendee", Code: CodeInvalidArgument}
+	}
+
+	replying := Property{Value: calAddressURI(attendee)}
+	if i := slices.IndexFunc(event.Attendees, func(p Property) bool {
+		return sameCalAddress(p.Value, attendee)
+	}); i >= 0 {
+		replying = event.Attendees[i]
+	}
+	params := schedulingParams(replying.Params)
+	params["PARTSTAT"] = partstat
+	delete(params, "RSVP")
+
+	msg := Event{
+		UID:          event.UID,
+		RecurrenceID: event.RecurrenceID,
+		Sequence:     event.Sequence,
+		DTStamp:      itipTimestamp(),
+		Start:        event.Start,
+		End:          event.End,
+		Duration:     event.Duration,
+		TZID:         event.TZID,
+		Summary:      event.Summary,
+		Organizer:    event.Organizer,
+		Attendees:    []Property{{Value: replying.Value, Params: params}},
+	}
+	return itipMessage(MethodReply, msg), nil
+}
+
+// BuildCancel returns a CANCEL message for event, or for one occurrence when
+// event has a RECURRENCE-ID. SEQUENCE is incremented and DTSTAMP set to the
+// current time; all attendees are addressed.
+func BuildCancel(event Event) (*Calendar, error) {
+	if err := checkOrganizer(event, MethodCancel); err != nil {
+		return nil, err
+	}
+
+	attendees := make([]Property, len(event.Attendees))
+	for i, attendee := range event.Attendees {
+		attendees[i] = Property{Value: attendee.Value, Params: schedulingParams(attendee.Params)}
+	}
+	msg := Event{
+		UID:          event.UID,
+		RecurrenceID: event.RecurrenceID,
+		Sequence:     event.Sequence + 1,
+		DTStamp:      itipTimestamp(),
+		Start:        event.Start,
+		End:          event.End,
+		Duration:     event.Duration,
+		TZID:         event.TZID,
+		Summary:      event.Summary,
+		Location:     event.Location,
+		Status:       "CANCELLED",
+		Organizer:    event.Organizer,
+		Attendees:    attendees,
+	}
+	return itipMessage(MethodCancel, msg), nil
+}
+
+func checkOrganizer(event Event, method string) error {
+	if event.UID == "" {
+		return AppError{Message: method + " needs an event with a UID", Code: CodeInvalidArgument}
+	}
+	if event.Organizer == "" {
+		return AppError{Message: method + " needs an event with an ORGANIZER", Value: event.UID, Code: CodeInvalidArgument}
+	}
+	return nil
+}
+
+func itipMessage(method string, event Event) *Calendar {
+	return &Calendar{
+		ProdID:  DefaultProdID,
+		Version: "2.0",
+		Method:  method,
+		Events:  []Event{event},
+	}
+}
+
+func itipTimestamp() string {
+	return time.Now().UTC().Truncate(time.Second).Format(time.RFC3339)
+}
+
+// schedulingParams copies attendee parameters without the bookkeeping of ApplyITIP.
+func schedulingParams(params map[string]string) map[string]string {
+	clone := cloneParams(params)
+	delete(clone, replyStampParam)
+	return clone
+}
+
+// calAddressURI turns a bare e-mail address into a mailto: URI.
+func calAddressURI(address string) string {
+	address = strings.TrimSpace(address)
+	if !strings.Contains(address, ":") {
+		return "mailto:" + address
+	}
+	return address
+}
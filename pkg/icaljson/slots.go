@@ -0,0 +1,317 @@
+package icaljson
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Default meeting slot search settings.
+const (
+	DefaultSlotStep  = 15 * time.Minute
+	DefaultSlotLimit = 10
+)
+
+// DefaultWorkingHours are Monday to Friday, 09:00 to 17:00.
+var DefaultWorkingHours = WorkingHours{
+	Days:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+	Start: 9 * time.Hour,
+	End:   17 * time.Hour,
+}
+
+// WorkingHours are the daily hours in which meetings may be scheduled.
+type WorkingHours struct {
+	Days  []time.Weekday // Working days
+	Start time.Duration  // Start of the working day, since midnight
+	End   time.Duration  // End of the working day, since midnight
+}
+
+// ParseWorkingHours parses working hours such as "09:00-17:30" (Monday to
+// Friday), "Mon-Thu 08:00-16:00" or "Mon,Wed,Fri 13:00-18:00".
+func ParseWorkingHours(value string) (WorkingHours, error) {
+	invalid := AppError{Message: "invalid working hours, expected e.g. 09:00-17:30 or Mon-Fri 09:00-17:30", Value: value, Code: CodeInvalidArgument}
+	hours := WorkingHours{Days: DefaultWorkingHours.Days}
+	fields := strings.Fields(value)
+	switch len(fields) {
+	case 1:
+	case 2:
+		days, err := parseWeekdays(fields[0])
+		if err != nil {
+			return hours, invalid
+		}
+		hours.Days = days
+	default:
+		return hours, invalid
+	}
+
+	from, to, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return hours, invalid
+	}
+	var err error
+	if hours.Start, err = parseClock(from); err != nil {
+		return hours, invalid
+	}
+	if hours.End, err = parseClock(to); err != nil {
+		return hours, invalid
+	}
+	if hours.Start >= hours.End {
+		return hours, invalid
+	}
+	return hours, nil
+}
+
+// parseClock parses "HH:MM" as the time since midnight; "24:00" is the end of the day.
+func parseClock(value string) (time.Duration, error) {
+	if value == "24:00" {
+		return 24 * time.Hour, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// parseWeekdays parses a list of English weekday abbreviations and ranges, e.g. "Mon-Fri" or "Mon,Wed".
+func parseWeekdays(value string) ([]time.Weekday, error) {
+	weekday := func(name string) (time.Weekday, error) {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(day.String()[:3], name) {
+				return day, nil
+			}
+		}
+		return 0, fmt.Errorf("unknown weekday %q", name)
+	}
+	var days []time.Weekday
+	for _, part := range strings.Split(value, ",") {
+		first, last, isRange := strings.Cut(part, "-")
+		from, err := weekday(first)
+		if err != nil {
+			return nil, err
+		}
+		to := from
+		if isRange {
+			if to, err = weekday(last); err != nil {
+				return nil, err
+			}
+		}
+		for day := from; ; day = (day + 1) % 7 {
+			if !slices.Contains(days, day) {
+				days = append(days, day)
+			}
+			if day == to {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// SlotCalendar is the calendar of one participant of a meeting. Zero fields
+// take the defaults of SlotOptions.
+type SlotCalendar struct {
+	Calendar     *Calendar
+	Name         string         // Name used in reports (default: calendar name or source)
+	WorkingHours *WorkingHours  // Hours in which the participant can meet
+	Location     *time.Location // Time zone of the working hours
+	Buffer       time.Duration  // Free time to keep before and after the participant's events
+}
+
+// SlotOptions configures FindSlots.
+type SlotOptions struct {
+	Duration time.Duration // Length of the meeting
+	From, To time.Time     // Window to search
+	// Location is the time zone of working hours, floating times and the
+	// returned slots (default: the time zone of From).
+	Location       *time.Location
+	WorkingHours   WorkingHours  // Default working hours (default: DefaultWorkingHours)
+	Buffer         time.Duration // Default buffer around events
+	Step           time.Duration // Granularity of slot starts (default: DefaultSlotStep)
+	AllowTentative bool          // Treat tentative events as free, at a lower score
+	Limit          int           // Maximum number of slots (default: DefaultSlotLimit, negative for all)
+}
+
+// Slot is a proposed meeting time.
+type Slot struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Score     float64   `json:"score"`               // 0 to 1, higher is better
+	Tentative []string  `json:"tentative,omitempty"` // Participants with a tentative event at that time
+}
+
+// FindSlots proposes meeting times of opts.Duration within [From, To) at
+// which all calendars are free. A participant is free within their working
+// hours, outside their busy time (see Calendar.FreeBusy) extended by their
+// buffer. Slot starts are aligned to opts.Step in the time zone of the slots.
+//
+// Slots are ranked by a score: 1 for a slot that leaves nobody a gap of free
+// time too short for another meeting of the same length, lowered by such gaps
+// and, with AllowTentative, by tentatively busy participants. Equal scores are
+// ordered by start time.
+func FindSlots(opts SlotOptions, calendars ...SlotCalendar) ([]Slot, error) {
+	if opts.Duration <= 0 {
+		return nil, AppError{Message: "meeting duration must be positive", Value: opts.Duration, Code: CodeInvalidArgument}
+	}
+	if opts.From.IsZero() || opts.To.IsZero() || !opts.From.Before(opts.To) {
+		return nil, AppError{Message: "slot search needs a window with a start before its end", Code: CodeInvalidArgument}
+	}
+	if len(calendars) == 0 {
+		return nil, AppError{Message: "slot search needs at least one calendar", Code: CodeInvalidArgument}
+	}
+	loc := cmp.Or(opts.Location, opts.From.Location())
+	if opts.WorkingHours.End == 0 {
+		opts.WorkingHours = DefaultWorkingHours
+	}
+	if opts.Step <= 0 {
+		opts.Step = DefaultSlotStep
+	}
+	if opts.Limit == 0 {
+		opts.Limit = DefaultSlotLimit
+	}
+
+	type participant struct {
+		name      string
+		free      []BusyPeriod
+		tentative []BusyPeriod
+	}
+	participants := make([]participant, len(calendars))
+	common := []BusyPeriod{{Start: opts.From, End: opts.To, Type: FBTypeFree}}
+	for i, sc := range calendars {
+		hours := opts.WorkingHours
+		if sc.WorkingHours != nil {
+			hours = *sc.WorkingHours
+		}
+		zone := cmp.Or(sc.Location, loc)
+		buffer := cmp.Or(sc.Buffer, opts.Buffer)
+
+		fb, err := sc.Calendar.FreeBusy(opts.From.Add(-buffer).In(zone), opts.To.Add(buffer).In(zone))
+		if err != nil {
+			return nil, err
+		}
+		var busy, tentative []BusyPeriod
+		for _, p := range fb.Busy {
+			p.Start, p.End = p.Start.Add(-buffer), p.End.Add(buffer)
+			if p.Type == FBTypeBusyTentative && opts.AllowTentative {
+				tentative = append(tentative, p)
+			} else {
+				busy = append(busy, p)
+			}
+		}
+
+		p := participant{
+			name:      cmp.Or(sc.Name, sc.Calendar.Name, sc.Calendar.Source, fmt.Sprintf("calendar-%d", i+1)),
+			free:      subtractPeriods(workingPeriods(hours, zone, opts.From, opts.To), mergePeriods(busy)),
+			tentative: mergePeriods(tentative),
+		}
+		participants[i] = p
+		common = intersectPeriods(common, p.free)
+	}
+
+	slots := []Slot{}
+	for _, free := range common {
+		start := free.Start.In(loc)
+		midnight := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc)
+		if offset := start.Sub(midnight) % opts.Step; offset != 0 {
+			start = start.Add(opts.Step - offset)
+		}
+		for ; !start.Add(opts.Duration).After(free.End); start = start.Add(opts.Step) {
+			slot := Slot{Start: start, End: start.Add(opts.Duration), Score: 1}
+			for _, p := range participants {
+				// Gaps left before and after the slot that are too short to use
+				for _, f := range p.free {
+					if f.Start.After(slot.Start) || f.End.Before(slot.End) {
+						continue
+					}
+					for _, gap := range []time.Duration{slot.Start.Sub(f.Start), f.End.Sub(slot.End)} {
+						if gap > 0 && gap < opts.Duration {
+							slot.Score -= 0.5 * (1 - float64(gap)/float64(opts.Duration)) / float64(len(participants))
+						}
+					}
+					break
+				}
+				if slices.ContainsFunc(p.tentative, func(t BusyPeriod) bool {
+					return t.Start.Before(slot.End) && t.End.After(slot.Start)
+				}) {
+					slot.Tentative = append(slot.Tentative, p.name)
+					slot.Score -= 0.5 / float64(len(participants))
+				}
+			}
+			slot.Score = math.Round(max(slot.Score, 0)*100) / 100
+			slots = append(slots, slot)
+		}
+	}
+
+	slices.SortStableFunc(slots, func(a, b Slot) int {
+		if a.Score != b.Score {
+			return cmp.Compare(b.Score, a.Score)
+		}
+		return a.Start.Compare(b.Start)
+	})
+	if opts.Limit > 0 && len(slots) > opts.Limit {
+		slots = slots[:opts.Limit]
+	}
+	return slots, nil
+}
+
+// workingPeriods returns the working hours within [from, to) in loc.
+func workingPeriods(hours WorkingHours, loc *time.Location, from, to time.Time) []BusyPeriod {
+	var periods []BusyPeriod
+	day := from.In(loc)
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, loc)
+	for ; day.Before(to); day = day.AddDate(0, 0, 1) {
+		if !slices.Contains(hours.Days, day.Weekday()) {
+			continue
+		}
+		// Wall-clock hours, so that working days keep their hours across DST changes
+		clock := func(d time.Duration) time.Time {
+			return time.Date(day.Year(), day.Month(), day.Day(), int(d/time.Hour), int(d%time.Hour/time.Minute), 0, 0, loc)
+		}
+		start, end := clock(hours.Start), clock(hours.End)
+		start, end = maxTime(start, from), minTime(end, to)
+		if start.Before(end) {
+			periods = append(periods, BusyPeriod{Start: start, End: end, Type: FBTypeFree})
+		}
+	}
+	return periods
+}
+
+// intersectPeriods returns the time covered by both sorted, merged lists.
+func intersectPeriods(a, b []BusyPeriod) []BusyPeriod {
+	var result []BusyPeriod
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		start, end := maxTime(a[i].Start, b[j].Start), minTime(a[i].End, b[j].End)
+		if start.Before(end) {
+			result = append(result, BusyPeriod{Start: start, End: end, Type: FBTypeFree})
+		}
+		if a[i].End.Before(b[j].End) {
+			i++
+		} else {
+			j++
+		}
+	}
+	return result
+}
+
+// WriteSlotsText writes slots as a ranked, human-readable list.
+func WriteSlotsText(w io.Writer, slots []Slot) error {
+	if len(slots) == 0 {
+		_, err := fmt.Fprintln(w, "No free slots.")
+		return err
+	}
+	for i, slot := range slots {
+		line := fmt.Sprintf("%2d. %s - %s  score %.2f", i+1,
+			slot.Start.Format("Mon 02 Jan 2006 15:04"), slot.End.Format("15:04 MST"), slot.Score)
+		if len(slot.Tentative) > 0 {
+			line += "  tentative: " + strings.Join(slot.Tentative, ", ")
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
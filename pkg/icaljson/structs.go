@@ -1,5 +1,7 @@
 package icaljson
 
+import "strings"
+
 // Calendar represents a VCALENDAR component according to RFC 5545
 type Calendar struct {
 	// Required properties
@@ -10,8 +12,137 @@ type Calendar struct {
 	CalScale string `json:"calscale,omitempty"` // Calendar scale (e.g., GREGORIAN)
 	Method   string `json:"method,omitempty"`   // iTIP method (e.g., REQUEST, PUBLISH)
 
+	// RFC 7986 properties; X-WR-* fill the gaps left by feeds that predate it
+	Name            string     `json:"name,omitempty"`             // NAME or X-WR-CALNAME - Display name
+	Description     string     `json:"description,omitempty"`      // DESCRIPTION or X-WR-CALDESC
+	UID             string     `json:"uid,omitempty"`              // Unique identifier of the calendar
+	URL             string     `json:"url,omitempty"`              // Location of the calendar
+	LastModified    string     `json:"last_modified,omitempty"`    // Last modification date-time
+	Categories      []Property `json:"categories,omitempty"`       // Calendar categories, one per value with the parameters of its line
+	Color           string     `json:"color,omitempty"`            // CSS3 color name, e.g. "turquoise"
+	Images          []Image    `json:"images,omitempty"`           // Logos and banners
+	RefreshInterval string     `json:"refresh_interval,omitempty"` // REFRESH-INTERVAL or X-PUBLISHED-TTL - Suggested polling interval (DURATION)
+	SourceURL       string     `json:"source_url,omitempty"`       // SOURCE - Where to refresh the calendar from
+	Timezone        string     `json:"timezone,omitempty"`         // X-WR-TIMEZONE - Default time zone of the calendar
+
+	// Source the calendar was read from (file path or URL), used for traceability when merging
+	Source string `json:"source,omitempty"`
+	// Headers of the e-mail the calendar was extracted from, see ParseMail
+	Message *MailHeaders `json:"message,omitempty"`
+
 	// Components
-	Events []Event `json:"events,omitempty"`
+	Events       []Event        `json:"events,omitempty"`
+	Todos        []Todo         `json:"todos,omitempty"`
+	Availability []Availability `json:"availability,omitempty"` // VAVAILABILITY components (RFC 7953)
+
+	// Character encoding of the parsed input, see DecodeInput
+	Encoding string `json:"-"`
+	// Problems found by a lenient parse, see ParseOptions
+	Diagnostics []Diagnostic `json:"-"`
+}
+
+// Property is the value of a property that may occur more than once,
+// together with its parameters (e.g. ALTREP, LANGUAGE, RELTYPE).
+type Property struct {
+	Value  string            `json:"value"`
+	Params map[string]string `json:"params,omitempty"` // Upper-cased names; multiple values are comma-separated
+}
+
+// Param returns the value of a parameter, or "".
+func (p Property) Param(name string) string {
+	return p.Params[strings.ToUpper(name)]
+}
+
+// Attachment is an ATTACH property: a URI or inline base64 data. Inline data
+// is kept encoded until Decode is called.
+type Attachment struct {
+	URI      string            `json:"uri,omitempty"`
+	FmtType  string            `json:"fmttype,omitempty"`  // Media type, e.g. application/pdf
+	Filename string            `json:"filename,omitempty"` // FILENAME or X-*-FILENAME parameter
+	Size     int64             `json:"size,omitempty"`     // Size in bytes, from SIZE or the inline data
+	Data     string            `json:"data,omitempty"`     // Base64 content of inline (VALUE=BINARY) attachments
+	Params   map[string]string `json:"params,omitempty"`   // Other parameters
+}
+
+// Image is an IMAGE property (RFC 7986 §5.10): a URI or inline base64 data.
+type Image struct {
+	Attachment
+	Display string `json:"display,omitempty"` // BADGE, GRAPHIC, FULLSIZE or THUMBNAIL
+	AltRep  string `json:"altrep,omitempty"`  // Alternate representation, e.g. a link target
+}
+
+// Conference is a CONFERENCE property (RFC 7986 §5.11), e.g. a video call link.
+type Conference struct {
+	URI      string            `json:"uri"`
+	Features []string          `json:"features,omitempty"` // AUDIO, CHAT, FEED, MODERATOR, PHONE, SCREEN, VIDEO
+	Label    string            `json:"label,omitempty"`    // Human-readable description, e.g. "Attendee dial-in"
+	Params   map[string]string `json:"params,omitempty"`   // Other parameters
+}
+
+// StructuredData is a STRUCTURED-DATA property (RFC 9073 §6.6), e.g. a
+// vCard address or schema.org JSON-LD describing a location or participant.
+type StructuredData struct {
+	Value   string            `json:"value"`             // Decoded text, base64 data or URI, depending on Type
+	Type    string            `json:"type,omitempty"`    // Value type: TEXT, BINARY or URI
+	FmtType string            `json:"fmttype,omitempty"` // Media type, e.g. application/ld+json
+	Schema  string            `json:"schema,omitempty"`  // URI of the schema, e.g. https://schema.org/Place
+	Params  map[string]string `json:"params,omitempty"`  // Other parameters
+}
+
+// VLocation is a VLOCATION component (RFC 9073 §7.2), one of possibly
+// several structured locations of an event or participant.
+type VLocation struct {
+	UID            string           `json:"uid,omitempty"`
+	Name           string           `json:"name,omitempty"`
+	Description    string           `json:"description,omitempty"`
+	LocationTypes  []string         `json:"location_types,omitempty"` // LOCATION-TYPE, e.g. "parking", "online" (RFC 4589)
+	URL            string           `json:"url,omitempty"`
+	Geo            *Geolocation     `json:"geo,omitempty"`
+	StructuredData []StructuredData `json:"structured_data,omitempty"` // Addresses and other details
+}
+
+// VResource is a VRESOURCE component (RFC 9073 §7.3), e.g. a projector or room.
+type VResource struct {
+	UID            string           `json:"uid,omitempty"`
+	Name           string           `json:"name,omitempty"`
+	Description    string           `json:"description,omitempty"`
+	ResourceType   string           `json:"resource_type,omitempty"` // RESOURCE-TYPE, e.g. PROJECTOR, ROOM
+	Geo            *Geolocation     `json:"geo,omitempty"`
+	StructuredData []StructuredData `json:"structured_data,omitempty"`
+}
+
+// Participant is a PARTICIPANT component (RFC 9073 §7.1): a person or
+// organization taking part in an event in a role such as PERFORMER or SPONSOR.
+type Participant struct {
+	UID             string           `json:"uid,omitempty"`
+	ParticipantType string           `json:"participant_type,omitempty"` // ACTIVE, INACTIVE, SPONSOR, CONTACT, BOOKING-CONTACT, EMERGENCY-CONTACT, PUBLICITY-CONTACT, PLANNER-CONTACT, PERFORMER or SPEAKER
+	CalendarAddress string           `json:"calendar_address,omitempty"` // CALENDAR-ADDRESS, e.g. mailto:info@example.com
+	Summary         string           `json:"summary,omitempty"`
+	Description     string           `json:"description,omitempty"`
+	URL             string           `json:"url,omitempty"`
+	Status          string           `json:"status,omitempty"`
+	Geo             *Geolocation     `json:"geo,omitempty"`
+	Contacts        []Property       `json:"contacts,omitempty"`
+	Comments        []Property       `json:"comments,omitempty"`
+	StructuredData  []StructuredData `json:"structured_data,omitempty"`
+	VLocations      []VLocation      `json:"vlocations,omitempty"`
+	VResources      []VResource      `json:"vresources,omitempty"`
+}
+
+// Alarm is a VALARM component (RFC 5545 §3.6.6) of an event or task.
+type Alarm struct {
+	UID         string       `json:"uid,omitempty"`         // RFC 9074 alarm identifier
+	Action      string       `json:"action,omitempty"`      // AUDIO, DISPLAY, EMAIL, ...
+	Trigger     string       `json:"trigger,omitempty"`     // Duration relative to the event such as -PT15M, or an absolute date-time
+	Related     string       `json:"related,omitempty"`     // RELATED parameter of a relative trigger: START (default) or END
+	Duration    string       `json:"duration,omitempty"`    // Delay between repetitions
+	Repeat      int          `json:"repeat,omitempty"`      // Number of additional repetitions after the trigger
+	Summary     string       `json:"summary,omitempty"`     // Subject of EMAIL alarms
+	Description string       `json:"description,omitempty"` // Text to display or send
+	Attendees   []Property   `json:"attendees,omitempty"`   // Recipients of EMAIL alarms
+	Attachments []Attachment `json:"attachments,omitempty"` // Sound of AUDIO alarms, attachments of EMAIL alarms
+	// Acknowledged is when the user last dismissed the alarm (RFC 9074 §6).
+	Acknowledged string `json:"acknowledged,omitempty"`
 }
 
 type Geolocation struct {
@@ -28,30 +159,36 @@ type Event struct {
 	Start    string `json:"start,omitempty"`    // DTSTART - Start date/time
 	End      string `json:"end,omitempty"`      // DTEND - End date/time
 	Duration string `json:"duration,omitempty"` // DURATION - Alternative to DTEND
+	TZID     string `json:"tzid,omitempty"`     // Time zone of DTSTART, used for recurrence expansion
 
 	// Core descriptive properties
 	Summary     string `json:"summary,omitempty"`     // Brief description/title
 	Description string `json:"description,omitempty"` // Full description
 	Location    string `json:"location,omitempty"`    // Event location
 
+	// Alternate representations
+	DescriptionHTML string            `json:"description_html,omitempty"` // X-ALT-DESC;FMTTYPE=text/html - Rich description written by Outlook
+	AltReps         map[string]string `json:"altreps,omitempty"`          // ALTREP URIs by property name (SUMMARY, DESCRIPTION, LOCATION)
+
 	// Optional commonly used properties
-	URL        string   `json:"url,omitempty"`        // Associated URL
-	Status     string   `json:"status,omitempty"`     // Event status (TENTATIVE, CONFIRMED, CANCELLED)
-	Categories []string `json:"categories,omitempty"` // Event categories
+	URL        string     `json:"url,omitempty"`        // Associated URL
+	Status     string     `json:"status,omitempty"`     // Event status (TENTATIVE, CONFIRMED, CANCELLED)
+	Categories []Property `json:"categories,omitempty"` // Event categories, one per value with the LANGUAGE of its line
 
 	// Classification and access
 	Class  string `json:"class,omitempty"`  // Access classification (PUBLIC, PRIVATE, CONFIDENTIAL)
 	Transp string `json:"transp,omitempty"` // Time transparency (OPAQUE, TRANSPARENT)
 
 	// Organizational properties
-	Organizer string   `json:"organizer,omitempty"` // Event organizer
-	Attendees []string `json:"attendees,omitempty"` // Event attendees
+	Organizer string     `json:"organizer,omitempty"` // Event organizer
+	Attendees []Property `json:"attendees,omitempty"` // Event attendees with CN, ROLE, PARTSTAT, RSVP, ... parameters
 
 	// Scheduling properties
 	Priority int `json:"priority,omitempty"` // Priority (0-9, 0=undefined)
 	Sequence int `json:"sequence,omitempty"` // Revision sequence number
 
 	// Date/Time metadata
+	DTStamp      string `json:"dtstamp,omitempty"`       // Date-time the object was created/sent
 	Created      string `json:"created,omitempty"`       // Creation date-time
 	LastModified string `json:"last_modified,omitempty"` // Last modification date-time
 
@@ -62,9 +199,51 @@ type Event struct {
 	RDates       []string `json:"rdates,omitempty"`        // Recurrence dates
 
 	// Other properties
-	Geo       Geolocation `json:"geo,omitempty"`        // Geographic position (latitude;longitude)
-	Resources []string    `json:"resources,omitempty"`  // Resources needed
-	Contact   string      `json:"contact,omitempty"`    // Contact information
-	RelatedTo string      `json:"related_to,omitempty"` // Related to other component
-	Comment   string      `json:"comment,omitempty"`    // Comment
+	Geo       Geolocation `json:"geo,omitempty"`       // Geographic position (latitude;longitude)
+	Resources []Property  `json:"resources,omitempty"` // Resources needed, one per value with the LANGUAGE and ALTREP of its line
+
+	// RFC 7986 properties
+	Color       string       `json:"color,omitempty"`       // CSS3 color name
+	Images      []Image      `json:"images,omitempty"`      // Images for the event
+	Conferences []Conference `json:"conferences,omitempty"` // Meeting links and dial-in numbers
+
+	// RFC 9073 properties and sub-components
+	StructuredData []StructuredData `json:"structured_data,omitempty"` // Machine-readable details, e.g. JSON-LD
+	VLocations     []VLocation      `json:"vlocations,omitempty"`      // Structured locations (venue, parking, online, ...)
+	VResources     []VResource      `json:"vresources,omitempty"`      // Structured resources
+	Participants   []Participant    `json:"participants,omitempty"`    // Performers, sponsors, contacts, ...
+
+	// Reminders
+	Alarms []Alarm `json:"alarms,omitempty"` // VALARM sub-components
+
+	// Properties that may occur more than once, with their parameters
+	Contacts      []Property   `json:"contacts,omitempty"`       // Contact information
+	RelatedTo     []Property   `json:"related_to,omitempty"`     // Related components by UID (RELTYPE parameter)
+	Comments      []Property   `json:"comments,omitempty"`       // Comments
+	Attachments   []Attachment `json:"attachments,omitempty"`    // Attached documents
+	RequestStatus []Property   `json:"request_status,omitempty"` // Scheduling request status (code;description[;data])
+
+	// Source calendar of the event, recorded by Merge
+	Source string `json:"source,omitempty"`
+}
+
+// Todo represents a VTODO component according to RFC 5545. It shares the
+// properties of Event; Start is optional and End is unused.
+type Todo struct {
+	Event
+
+	Due             string `json:"due,omitempty"`              // DUE - Date/time the task is due
+	Completed       string `json:"completed,omitempty"`        // COMPLETED - Date/time the task was completed
+	PercentComplete int    `json:"percent_complete,omitempty"` // PERCENT-COMPLETE - 0 to 100
+}
+
+// Availability is a VAVAILABILITY component (RFC 7953): within its DTSTART
+// and DTEND (unbounded when absent), the calendar user is busy with BusyType
+// except during the AVAILABLE blocks. Components with a higher PRIORITY
+// (1 highest, 9 lowest, 0 undefined and lowest) override lower ones.
+type Availability struct {
+	Event
+
+	BusyType  string  `json:"busy_type,omitempty"` // BUSYTYPE - BUSY, BUSY-UNAVAILABLE (default) or BUSY-TENTATIVE
+	Available []Event `json:"available,omitempty"` // AVAILABLE blocks, possibly recurring
 }
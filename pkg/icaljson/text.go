@@ -0,0 +1,68 @@
+package icaljson
+
+import "strings"
+
+// DecodeText unescapes an RFC 5545 TEXT value (§3.3.11) in a single pass:
+// "\\" becomes a backslash, "\;" and "\," the separator, and "\n" or "\N" a
+// newline. Other escapes are kept as they are.
+func DecodeText(value string) string {
+	if !strings.Contains(value, `\`) {
+		return value
+	}
+
+	var b strings.Builder
+	b.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c != '\\' || i+1 == len(value) {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch value[i] {
+		case '\\', ';', ',':
+			b.WriteByte(value[i])
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte('\\')
+			b.WriteByte(value[i])
+		}
+	}
+	return b.String()
+}
+
+// SplitText splits a multi-valued TEXT property such as CATEGORIES on
+// unescaped separators and decodes each item. Items are not trimmed.
+func SplitText(value string, sep byte) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++ // skip the escaped character
+		case sep:
+			items = append(items, DecodeText(value[start:i]))
+			start = i + 1
+		}
+	}
+	return append(items, DecodeText(value[start:]))
+}
+
+// textEscaper implements EncodeText.
+var textEscaper = strings.NewReplacer(`\`, `\\`, `;`, `\;`, `,`, `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// EncodeText escapes a string as an RFC 5545 TEXT value, the inverse of DecodeText.
+func EncodeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+// JoinText encodes items as a comma-separated multi-valued TEXT property,
+// the inverse of SplitText.
+func JoinText(items []string) string {
+	encoded := make([]string, len(items))
+	for i, item := range items {
+		encoded[i] = EncodeText(item)
+	}
+	return strings.Join(encoded, ",")
+}
@@ -16,7 +16,7 @@ func IsICalFile(filePath string) bool {
 // ValidateOutputPath validates if the given path is a valid file path
 func ValidateOutputPath(outputPath string) error {
 	if outputPath == "" {
-		return AppError{Message: "output path cannot be empty"}
+		return AppError{Message: "output path cannot be empty", Code: CodeInvalidArgument}
 	}
 
 	// Check if the directory exists or can be created
@@ -26,6 +26,7 @@ func ValidateOutputPath(outputPath string) error {
 			return AppError{
 				Message: fmt.Sprintf("cannot create directory %s", dir),
 				Value:   err,
+				Code:    CodeInvalidArgument,
 			}
 		}
 	}
@@ -37,6 +38,7 @@ func ValidateOutputPath(outputPath string) error {
 		return AppError{
 			Message: fmt.Sprintf("cannot write to path %s", outputPath),
 			Value:   err,
+			Code:    CodeInvalidArgument,
 		}
 	}
 	file.Close()
@@ -0,0 +1,641 @@
+package icaljson
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Severity of a diagnostic.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic is a problem found in an iCalendar stream.
+type Diagnostic struct {
+	Line     int      `json:"line"`             // 1-based physical line, 0 for the whole stream
+	Column   int      `json:"column,omitempty"` // 1-based byte offset within the unfolded line
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`           // Stable rule identifier, see ValidationRules
+	Rule     string   `json:"rule,omitempty"` // Specification reference
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	location := fmt.Sprintf("%d", d.Line)
+	if d.Column > 0 {
+		location += fmt.Sprintf(":%d", d.Column)
+	}
+	s := fmt.Sprintf("%s: %s: [%s] %s", location, d.Severity, d.Code, d.Message)
+	if d.Rule != "" {
+		s += " (" + d.Rule + ")"
+	}
+	return s
+}
+
+// ValidationRule documents a diagnostic code.
+type ValidationRule struct {
+	Code        string
+	Severity    Severity
+	Reference   string
+	Description string
+}
+
+// Validation rule codes.
+const (
+	CodeNotICalendar         = "NOT_ICALENDAR"
+	CodeEmptyCalendar        = "EMPTY_CALENDAR"
+	CodeLineTooLong          = "LINE_TOO_LONG"
+	CodeSplitUTF8Sequence    = "SPLIT_UTF8_SEQUENCE"
+	CodeBareLineFeed         = "BARE_LINE_FEED"
+	CodeNonUTF8Encoding      = "NON_UTF8_ENCODING"
+	CodeInvalidContentLine   = "INVALID_CONTENT_LINE"
+	CodeUnbalancedComponent  = "UNBALANCED_COMPONENT"
+	CodeUnknownComponent     = "UNKNOWN_COMPONENT"
+	CodeMissingProdID        = "MISSING_PRODID"
+	CodeMissingVersion       = "MISSING_VERSION"
+	CodeInvalidVersion       = "INVALID_VERSION"
+	CodeMissingUID           = "MISSING_UID"
+	CodeMissingDTStamp       = "MISSING_DTSTAMP"
+	CodeMissingDTStart       = "MISSING_DTSTART"
+	CodeMissingProperty      = "MISSING_PROPERTY"
+	CodeDuplicateProperty    = "DUPLICATE_PROPERTY"
+	CodeEndAndDuration       = "DTEND_AND_DURATION"
+	CodeEndBeforeStart       = "DTEND_BEFORE_DTSTART"
+	CodeValueTypeMismatch    = "VALUE_TYPE_MISMATCH"
+	CodeInvalidDateTime      = "INVALID_DATETIME"
+	CodeInvalidDuration      = "INVALID_DURATION"
+	CodeInvalidRRule         = "INVALID_RRULE"
+	CodeInvalidGeo           = "INVALID_GEO"
+	CodeInvalidPropertyValue = "INVALID_PROPERTY_VALUE"
+	CodeUnknownTZID          = "UNKNOWN_TZID"
+	CodeMissingVTimezone     = "MISSING_VTIMEZONE"
+)
+
+// ValidationRules lists every diagnostic the validator can report.
+var ValidationRules = []ValidationRule{
+	{CodeNotICalendar, SeverityError, "RFC 5545 §3.4", "The stream must start with BEGIN:VCALENDAR"},
+	{CodeEmptyCalendar, SeverityError, "RFC 5545 §3.6", "A calendar must contain at least one component"},
+	{CodeLineTooLong, SeverityWarning, "RFC 5545 §3.1", "Lines should not be longer than 75 octets, excluding the line break"},
+	{CodeSplitUTF8Sequence, SeverityWarning, "RFC 5545 §3.1", "Lines should not be folded in the middle of a UTF-8 sequence"},
+	{CodeBareLineFeed, SeverityWarning, "RFC 5545 §3.1", "Lines must be delimited by CRLF"},
+	{CodeNonUTF8Encoding, SeverityWarning, "RFC 5545 §3.1.4", "The default charset is UTF-8; other encodings are not portable"},
+	{CodeInvalidContentLine, SeverityError, "RFC 5545 §3.1", "Content lines must have the form NAME;PARAM=VALUE:value"},
+	{CodeUnbalancedComponent, SeverityError, "RFC 5545 §3.6", "Every BEGIN must be closed by a matching END"},
+	{CodeUnknownComponent, SeverityWarning, "RFC 5545 §3.6", "Unknown component types are ignored by most clients"},
+	{CodeMissingProdID, SeverityError, "RFC 5545 §3.7.3", "PRODID is required in VCALENDAR"},
+	{CodeMissingVersion, SeverityError, "RFC 5545 §3.7.4", "VERSION is required in VCALENDAR"},
+	{CodeInvalidVersion, SeverityError, "RFC 5545 §3.7.4", "VERSION must be 2.0"},
+	{CodeMissingUID, SeverityError, "RFC 5545 §3.8.4.7", "UID is required in VEVENT, VTODO, VJOURNAL, VFREEBUSY, VAVAILABILITY, AVAILABLE, PARTICIPANT, VLOCATION and VRESOURCE"},
+	{CodeMissingDTStamp, SeverityError, "RFC 5545 §3.8.7.2", "DTSTAMP is required in VEVENT, VTODO, VJOURNAL, VFREEBUSY, VAVAILABILITY and AVAILABLE"},
+	{CodeMissingDTStart, SeverityError, "RFC 5545 §3.6.1", "DTSTART is required in VEVENT when the calendar has no METHOD"},
+	{CodeMissingProperty, SeverityError, "RFC 5545 §3.6", "A required property is missing"},
+	{CodeDuplicateProperty, SeverityError, "RFC 5545 §3.6", "The property must not occur more than once"},
+	{CodeEndAndDuration, SeverityError, "RFC 5545 §3.6.1", "DTEND and DURATION must not both occur"},
+	{CodeEndBeforeStart, SeverityError, "RFC 5545 §3.8.2.2", "DTEND must be later than DTSTART"},
+	{CodeValueTypeMismatch, SeverityError, "RFC 5545 §3.8.2.2", "DTSTART and DTEND must have the same value type"},
+	{CodeInvalidDateTime, SeverityError, "RFC 5545 §3.3.5", "Invalid DATE or DATE-TIME value"},
+	{CodeInvalidDuration, SeverityError, "RFC 5545 §3.3.6", "Invalid DURATION value"},
+	{CodeInvalidRRule, SeverityError, "RFC 5545 §3.3.10", "Invalid recurrence rule"},
+	{CodeInvalidGeo, SeverityError, "RFC 5545 §3.8.1.6", "GEO must be latitude;longitude within -90..90 and -180..180"},
+	{CodeInvalidPropertyValue, SeverityError, "RFC 5545 §3.8", "The property value is not allowed"},
+	{CodeUnknownTZID, SeverityError, "RFC 5545 §3.2.19", "TZID must reference a VTIMEZONE in the calendar"},
+	{CodeMissingVTimezone, SeverityWarning, "RFC 5545 §3.2.19", "TZID references a zone without a VTIMEZONE definition"},
+}
+
+// ruleFor returns the documented rule for a code.
+func ruleFor(code string) ValidationRule {
+	for _, r := range ValidationRules {
+		if r.Code == code {
+			return r
+		}
+	}
+	return ValidationRule{Code: code, Severity: SeverityError}
+}
+
+// knownComponents are the component names defined by RFC 5545 and its extensions.
+var knownComponents = []string{
+	"VCALENDAR", "VEVENT", "VTODO", "VJOURNAL", "VFREEBUSY", "VTIMEZONE", "STANDARD", "DAYLIGHT", "VALARM",
+	"VAVAILABILITY", "AVAILABLE", "VLOCATION", "VRESOURCE", "PARTICIPANT",
+}
+
+// singleProperties lists properties that must not occur more than once per component.
+var singleProperties = map[string][]string{
+	"VCALENDAR": {"PRODID", "VERSION", "CALSCALE", "METHOD",
+		"UID", "LAST-MODIFIED", "URL", "REFRESH-INTERVAL", "SOURCE", "COLOR"},
+	"VEVENT": {"DTSTAMP", "UID", "DTSTART", "CLASS", "CREATED", "DESCRIPTION", "GEO", "LAST-MODIFIED",
+		"LOCATION", "ORGANIZER", "PRIORITY", "SEQUENCE", "STATUS", "SUMMARY", "TRANSP", "URL",
+		"RECURRENCE-ID", "DTEND", "DURATION", "COLOR"},
+	"VTODO": {"DTSTAMP", "UID", "CLASS", "COMPLETED", "CREATED", "DESCRIPTION", "DTSTART", "GEO",
+		"LAST-MODIFIED", "LOCATION", "ORGANIZER", "PERCENT-COMPLETE", "PRIORITY", "RECURRENCE-ID",
+		"SEQUENCE", "STATUS", "SUMMARY", "URL", "DUE", "DURATION", "COLOR"},
+	"VJOURNAL":  {"DTSTAMP", "UID", "CLASS", "CREATED", "DTSTART", "LAST-MODIFIED", "ORGANIZER", "RECURRENCE-ID", "SEQUENCE", "STATUS", "SUMMARY", "URL"},
+	"VFREEBUSY": {"DTSTAMP", "UID", "CONTACT", "DTSTART", "DTEND", "ORGANIZER", "URL"},
+	"VALARM":    {"ACTION", "TRIGGER", "DURATION", "REPEAT", "DESCRIPTION", "SUMMARY"},
+	"VAVAILABILITY": {"DTSTAMP", "UID", "BUSYTYPE", "CLASS", "CREATED", "DESCRIPTION", "DTSTART", "LAST-MODIFIED",
+		"LOCATION", "ORGANIZER", "PRIORITY", "SEQUENCE", "SUMMARY", "URL", "DTEND", "DURATION"},
+	"AVAILABLE": {"DTSTAMP", "DTSTART", "UID", "CREATED", "DESCRIPTION", "LAST-MODIFIED", "LOCATION",
+		"RECURRENCE-ID", "SUMMARY", "DTEND", "DURATION"},
+	"VTIMEZONE": {"TZID", "LAST-MODIFIED", "TZURL"},
+	"PARTICIPANT": {"UID", "PARTICIPANT-TYPE", "CALENDAR-ADDRESS", "CREATED", "DESCRIPTION", "DTSTAMP", "GEO",
+		"LAST-MODIFIED", "PRIORITY", "SEQUENCE", "STATUS", "SUMMARY", "URL"},
+	"VLOCATION": {"UID", "DESCRIPTION", "GEO", "LOCATION-TYPE", "NAME", "URL"},
+	"VRESOURCE": {"UID", "DESCRIPTION", "GEO", "NAME", "RESOURCE-TYPE"},
+}
+
+// dateTimeProperties hold DATE or DATE-TIME values.
+var dateTimeProperties = []string{
+	"DTSTART", "DTEND", "DUE", "RECURRENCE-ID", "EXDATE", "DTSTAMP", "CREATED", "LAST-MODIFIED", "COMPLETED",
+}
+
+// maxLineOctets is the folding limit of RFC 5545 §3.1.
+const maxLineOctets = 75
+
+// vcomponent is a component of the syntax tree built by the validator.
+type vcomponent struct {
+	Name       string
+	Line       int
+	Properties []ContentLine
+	Children   []*vcomponent
+}
+
+func (c *vcomponent) props(name string) []ContentLine {
+	var result []ContentLine
+	for _, p := range c.Properties {
+		if p.Name == name {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func (c *vcomponent) prop(name string) (ContentLine, bool) {
+	for _, p := range c.Properties {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return ContentLine{}, false
+}
+
+// validator collects diagnostics.
+type validator struct {
+	diagnostics []Diagnostic
+	timezones   map[string]bool // TZIDs defined by VTIMEZONE components
+	tzidUses    []ContentLine
+	hasMethod   bool
+}
+
+func (v *validator) report(line, column int, code string, format string, args ...any) {
+	v.diagnostics = append(v.diagnostics, newDiagnostic(line, column, code, fmt.Sprintf(format, args...)))
+}
+
+// newDiagnostic creates a diagnostic with the severity and reference of its rule.
+func newDiagnostic(line, column int, code, message string) Diagnostic {
+	rule := ruleFor(code)
+	return Diagnostic{
+		Line:     line,
+		Column:   column,
+		Severity: rule.Severity,
+		Code:     code,
+		Rule:     rule.Reference,
+		Message:  message,
+	}
+}
+
+// ValidateFile checks the ICS file at path, see Validate.
+func ValidateFile(path string) ([]Diagnostic, error) {
+	return ValidateFileWithOptions(path, ParseOptions{})
+}
+
+// ValidateFileWithOptions checks the ICS file at path, see ValidateWithOptions.
+func ValidateFileWithOptions(path string, opts ParseOptions) ([]Diagnostic, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, AppError{Message: "failed to open ICS file", Value: err, Code: CodeReadFailed}
+	}
+	defer file.Close()
+
+	return ValidateWithOptions(file, opts)
+}
+
+// Validate checks an iCalendar stream for RFC 5545 conformance and returns the
+// diagnostics sorted by line. The error is only set when the stream cannot be read.
+func Validate(r io.Reader) ([]Diagnostic, error) {
+	return ValidateWithOptions(r, ParseOptions{})
+}
+
+// ValidateWithOptions is Validate with an encoding override in opts.Encoding;
+// opts.Strict has no effect.
+func ValidateWithOptions(r io.Reader, opts ParseOptions) ([]Diagnostic, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, AppError{Message: "failed to read file", Value: err, Code: CodeReadFailed}
+	}
+
+	v := &validator{timezones: map[string]bool{}}
+	data, encoding, err := DecodeInput(data, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if encoding != EncodingUTF8 {
+		v.report(1, 0, CodeNonUTF8Encoding, "input is encoded as %s, not UTF-8", encoding)
+	}
+	physical, nonCRLF := splitPhysicalLines(data)
+	if nonCRLF {
+		v.report(1, 0, CodeBareLineFeed, "lines are not terminated by CRLF")
+	}
+	for i, line := range physical {
+		if len(line) > maxLineOctets {
+			v.report(i+1, maxLineOctets+1, CodeLineTooLong, "line is %d octets long, fold lines at 75 octets", len(line))
+		}
+		if i+1 < len(physical) && len(physical[i+1]) > 0 && isFoldSpace(physical[i+1][0]) {
+			if r, size := utf8.DecodeLastRuneInString(line); r == utf8.RuneError && size == 1 {
+				v.report(i+1, len(line), CodeSplitUTF8Sequence, "line is folded in the middle of a UTF-8 sequence")
+			}
+		}
+	}
+
+	roots := v.buildTree(unfoldNumbered(physical))
+	if len(roots) == 0 || roots[0].Name != "VCALENDAR" {
+		v.report(1, 1, CodeNotICalendar, "stream does not start with BEGIN:VCALENDAR")
+	}
+	for _, root := range roots {
+		v.collectTimezones(root)
+	}
+	for _, root := range roots {
+		v.checkComponent(root)
+	}
+	v.checkTZIDs()
+
+	sort.SliceStable(v.diagnostics, func(i, j int) bool {
+		a, b := v.diagnostics[i], v.diagnostics[j]
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return v.diagnostics, nil
+}
+
+// buildTree parses the unfolded lines into components, reporting syntax and
+// nesting errors.
+func (v *validator) buildTree(lines []numberedLine) []*vcomponent {
+	var roots []*vcomponent
+	var stack []*vcomponent
+
+	for _, nl := range lines {
+		cl, err := ParseContentLine(nl.Text)
+		if err != nil {
+			column := 1
+			if cle, ok := err.(*ContentLineError); ok {
+				column = cle.Column
+			}
+			v.report(nl.Line, column, CodeInvalidContentLine, "%v", err)
+			continue
+		}
+		cl.Line = nl.Line
+
+		switch cl.Name {
+		case "BEGIN":
+			name := strings.ToUpper(strings.TrimSpace(cl.Value))
+			comp := &vcomponent{Name: name, Line: nl.Line}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.Children = append(parent.Children, comp)
+			} else {
+				roots = append(roots, comp)
+			}
+			stack = append(stack, comp)
+			if !slices.Contains(knownComponents, name) && !strings.HasPrefix(name, "X-") {
+				v.report(nl.Line, 7, CodeUnknownComponent, "unknown component %s", name)
+			}
+		case "END":
+			name := strings.ToUpper(strings.TrimSpace(cl.Value))
+			if len(stack) == 0 {
+				v.report(nl.Line, 1, CodeUnbalancedComponent, "END:%s without matching BEGIN", name)
+				continue
+			}
+			open := stack[len(stack)-1]
+			if open.Name != name {
+				// Close the innermost matching component, if any
+				idx := slices.IndexFunc(stack, func(c *vcomponent) bool { return c.Name == name })
+				if idx < 0 {
+					v.report(nl.Line, 1, CodeUnbalancedComponent, "END:%s does not match BEGIN:%s on line %d", name, open.Name, open.Line)
+					continue
+				}
+				for _, unclosed := range stack[idx+1:] {
+					v.report(unclosed.Line, 1, CodeUnbalancedComponent, "BEGIN:%s is not closed before END:%s on line %d", unclosed.Name, name, nl.Line)
+				}
+				stack = stack[:idx+1]
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			if len(stack) == 0 {
+				v.report(nl.Line, 1, CodeInvalidContentLine, "property %s outside of any component", cl.Name)
+				continue
+			}
+			comp := stack[len(stack)-1]
+			comp.Properties = append(comp.Properties, cl)
+			if cl.Param("TZID") != "" {
+				v.tzidUses = append(v.tzidUses, cl)
+			}
+		}
+	}
+
+	for _, unclosed := range stack {
+		v.report(unclosed.Line, 1, CodeUnbalancedComponent, "BEGIN:%s is never closed", unclosed.Name)
+	}
+	return roots
+}
+
+func (v *validator) collectTimezones(c *vcomponent) {
+	if c.Name == "VTIMEZONE" {
+		if tzid, ok := c.prop("TZID"); ok {
+			v.timezones[tzid.Value] = true
+		}
+	}
+	for _, child := range c.Children {
+		v.collectTimezones(child)
+	}
+}
+
+// checkComponent applies the component-specific rules recursively.
+func (v *validator) checkComponent(c *vcomponent) {
+	for _, name := range singleProperties[c.Name] {
+		if occurrences := c.props(name); len(occurrences) > 1 {
+			v.report(occurrences[1].Line, 1, CodeDuplicateProperty, "%s occurs %d times in %s", name, len(occurrences), c.Name)
+		}
+	}
+
+	switch c.Name {
+	case "VCALENDAR":
+		v.checkCalendar(c)
+	case "VEVENT", "VTODO", "VJOURNAL", "VFREEBUSY":
+		v.checkRequired(c, "UID", CodeMissingUID)
+		v.checkRequired(c, "DTSTAMP", CodeMissingDTStamp)
+		if c.Name == "VEVENT" {
+			if _, ok := c.prop("DTSTART"); !ok && !v.hasMethod {
+				v.report(c.Line, 1, CodeMissingDTStart, "VEVENT is missing DTSTART")
+			}
+			v.checkEnd(c, "DTEND")
+		}
+		if c.Name == "VTODO" {
+			v.checkEnd(c, "DUE")
+		}
+	case "VAVAILABILITY", "AVAILABLE":
+		v.checkRequired(c, "UID", CodeMissingUID)
+		v.checkRequired(c, "DTSTAMP", CodeMissingDTStamp)
+		if c.Name == "AVAILABLE" {
+			v.checkRequired(c, "DTSTART", CodeMissingProperty)
+		}
+		v.checkEnd(c, "DTEND")
+	case "VALARM":
+		v.checkRequired(c, "ACTION", CodeMissingProperty)
+		v.checkRequired(c, "TRIGGER", CodeMissingProperty)
+	case "PARTICIPANT":
+		v.checkRequired(c, "UID", CodeMissingUID)
+		v.checkRequired(c, "PARTICIPANT-TYPE", CodeMissingProperty)
+	case "VLOCATION", "VRESOURCE":
+		v.checkRequired(c, "UID", CodeMissingUID)
+	case "VTIMEZONE":
+		v.checkRequired(c, "TZID", CodeMissingProperty)
+	case "STANDARD", "DAYLIGHT":
+		v.checkRequired(c, "DTSTART", CodeMissingProperty)
+		v.checkRequired(c, "TZOFFSETFROM", CodeMissingProperty)
+		v.checkRequired(c, "TZOFFSETTO", CodeMissingProperty)
+	}
+
+	for _, p := range c.Properties {
+		v.checkProperty(c, p)
+	}
+
+	for _, child := range c.Children {
+		v.checkComponent(child)
+	}
+}
+
+func (v *validator) checkCalendar(c *vcomponent) {
+	_, v.hasMethod = c.prop("METHOD")
+	if _, ok := c.prop("PRODID"); !ok {
+		v.report(c.Line, 1, CodeMissingProdID, "VCALENDAR is missing PRODID")
+	}
+	if version, ok := c.prop("VERSION"); !ok {
+		v.report(c.Line, 1, CodeMissingVersion, "VCALENDAR is missing VERSION")
+	} else if strings.TrimSpace(version.Value) != "2.0" {
+		v.report(version.Line, len("VERSION:")+1, CodeInvalidVersion, "VERSION is %q, expected 2.0", version.Value)
+	}
+	if len(c.Children) == 0 {
+		v.report(c.Line, 1, CodeEmptyCalendar, "VCALENDAR contains no components")
+	}
+}
+
+func (v *validator) checkRequired(c *vcomponent, name, code string) {
+	if _, ok := c.prop(name); !ok {
+		v.report(c.Line, 1, code, "%s is missing %s", c.Name, name)
+	}
+}
+
+// checkEnd validates DTEND/DUE against DTSTART and DURATION.
+func (v *validator) checkEnd(c *vcomponent, endName string) {
+	end, hasEnd := c.prop(endName)
+	duration, hasDuration := c.prop("DURATION")
+	if hasEnd && hasDuration {
+		v.report(duration.Line, 1, CodeEndAndDuration, "%s has both %s and DURATION", c.Name, endName)
+	}
+
+	start, hasStart := c.prop("DTSTART")
+	if !hasStart || !hasEnd {
+		return
+	}
+	startTime, startDate, err1 := parsePropertyTime(start)
+	endTime, endDate, err2 := parsePropertyTime(end)
+	if err1 != nil || err2 != nil {
+		return // reported by checkProperty
+	}
+	if startDate != endDate {
+		v.report(end.Line, 1, CodeValueTypeMismatch, "DTSTART is a %s but %s is a %s", valueTypeName(startDate), endName, valueTypeName(endDate))
+		return
+	}
+	if endTime.Before(startTime) || (c.Name == "VEVENT" && endTime.Equal(startTime) && startDate) {
+		v.report(end.Line, 1, CodeEndBeforeStart, "%s %s is not after DTSTART %s", endName, end.Value, start.Value)
+	}
+}
+
+func valueTypeName(isDate bool) string {
+	if isDate {
+		return "DATE"
+	}
+	return "DATE-TIME"
+}
+
+// checkProperty validates a single property value.
+func (v *validator) checkProperty(c *vcomponent, p ContentLine) {
+	column := p.valueColumn
+
+	switch {
+	case slices.Contains(dateTimeProperties, p.Name) && c.Name != "STANDARD" && c.Name != "DAYLIGHT":
+		for _, item := range strings.Split(p.Value, ",") {
+			one := p
+			one.Value = item
+			if _, _, err := parsePropertyTime(one); err != nil {
+				v.report(p.Line, column, CodeInvalidDateTime, "%s has invalid value %q: %v", p.Name, item, err)
+			}
+		}
+	case p.Name == "DURATION" || p.Name == "REFRESH-INTERVAL":
+		if _, err := ParseDuration(p.Value); err != nil {
+			v.report(p.Line, column, CodeInvalidDuration, "%s has invalid value %q", p.Name, p.Value)
+		}
+	case p.Name == "RRULE":
+		if _, err := ParseRecur(p.Value, nil); err != nil {
+			v.report(p.Line, column, CodeInvalidRRule, "RRULE is invalid: %v", err)
+		}
+	case p.Name == "GEO":
+		if !validGeo(p.Value) {
+			v.report(p.Line, column, CodeInvalidGeo, "GEO value %q is not a valid latitude;longitude", p.Value)
+		}
+	case p.Name == "STATUS":
+		allowed := map[string][]string{
+			"VEVENT":   {"TENTATIVE", "CONFIRMED", "CANCELLED"},
+			"VTODO":    {"NEEDS-ACTION", "COMPLETED", "IN-PROCESS", "CANCELLED"},
+			"VJOURNAL": {"DRAFT", "FINAL", "CANCELLED"},
+		}[c.Name]
+		if allowed != nil && !slices.Contains(allowed, strings.ToUpper(p.Value)) {
+			v.report(p.Line, column, CodeInvalidPropertyValue, "STATUS %q is not valid in %s (%s)", p.Value, c.Name, strings.Join(allowed, ", "))
+		}
+	case p.Name == "TRANSP":
+		if !slices.Contains([]string{"OPAQUE", "TRANSPARENT"}, strings.ToUpper(p.Value)) {
+			v.report(p.Line, column, CodeInvalidPropertyValue, "TRANSP %q must be OPAQUE or TRANSPARENT", p.Value)
+		}
+	case p.Name == "PRIORITY":
+		if n, err := strconv.Atoi(p.Value); err != nil || n < 0 || n > 9 {
+			v.report(p.Line, column, CodeInvalidPropertyValue, "PRIORITY %q must be an integer from 0 to 9", p.Value)
+		}
+	case p.Name == "SEQUENCE":
+		if n, err := strconv.Atoi(p.Value); err != nil || n < 0 {
+			v.report(p.Line, column, CodeInvalidPropertyValue, "SEQUENCE %q must be a non-negative integer", p.Value)
+		}
+	}
+}
+
+// checkTZIDs verifies that every TZID parameter references a known zone.
+func (v *validator) checkTZIDs() {
+	for _, p := range v.tzidUses {
+		tzid := p.Param("TZID")
+		if v.timezones[tzid] {
+			continue
+		}
+		if _, err := time.LoadLocation(tzid); err == nil {
+			v.report(p.Line, 1, CodeMissingVTimezone, "TZID %q has no VTIMEZONE definition in the calendar", tzid)
+		} else {
+			v.report(p.Line, 1, CodeUnknownTZID, "TZID %q is neither defined by a VTIMEZONE nor a known IANA zone", tzid)
+		}
+	}
+}
+
+// parsePropertyTime parses a DATE or DATE-TIME property value strictly,
+// honouring the VALUE and TZID parameters.
+func parsePropertyTime(p ContentLine) (time.Time, bool, error) {
+	value := strings.TrimSpace(p.Value)
+	if p.Name == "RDATE" || p.Name == "EXDATE" {
+		value, _, _ = strings.Cut(value, "/")
+	}
+	loc := time.UTC
+	if tzid := p.Param("TZID"); tzid != "" {
+		if l, err := time.LoadLocation(tzid); err == nil {
+			loc = l
+		}
+	}
+
+	if strings.EqualFold(p.Param("VALUE"), "DATE") {
+		t, err := time.ParseInLocation("20060102", value, loc)
+		if err != nil {
+			return time.Time{}, true, fmt.Errorf("expected DATE (YYYYMMDD)")
+		}
+		return t, true, nil
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		if p.Param("TZID") != "" {
+			return time.Time{}, false, fmt.Errorf("UTC time must not have a TZID")
+		}
+		t, err := time.Parse("20060102T150405Z", value)
+		if err != nil {
+			return time.Time{}, false, fmt.Errorf("expected DATE-TIME (YYYYMMDDTHHMMSSZ)")
+		}
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("20060102T150405", value, loc); err == nil {
+		return t, false, nil
+	}
+	if t, err := time.ParseInLocation("20060102", value, loc); err == nil && p.Param("VALUE") == "" {
+		// A DATE without VALUE=DATE is common and accepted by most clients
+		return t, true, nil
+	}
+	return time.Time{}, false, fmt.Errorf("expected DATE-TIME (YYYYMMDDTHHMMSS)")
+}
+
+func validGeo(value string) bool {
+	lat, lon, ok := strings.Cut(value, ";")
+	if !ok {
+		return false
+	}
+	latitude, err1 := strconv.ParseFloat(strings.TrimSpace(lat), 64)
+	longitude, err2 := strconv.ParseFloat(strings.TrimSpace(lon), 64)
+	return err1 == nil && err2 == nil &&
+		latitude >= -90 && latitude <= 90 && longitude >= -180 && longitude <= 180
+}
+
+// CountBySeverity returns the number of diagnostics with the given severity.
+func CountBySeverity(diagnostics []Diagnostic, severity Severity) int {
+	n := 0
+	for _, d := range diagnostics {
+		if d.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// ValidationReport holds the diagnostics of one validated file.
+type ValidationReport struct {
+	Path        string       `json:"path"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// Errors returns the number of error diagnostics.
+func (r ValidationReport) Errors() int {
+	return CountBySeverity(r.Diagnostics, SeverityError)
+}
+
+// Warnings returns the number of warning diagnostics.
+func (r ValidationReport) Warnings() int {
+	return CountBySeverity(r.Diagnostics, SeverityWarning)
+}
+
+// WriteValidationText writes one "path:line:col: severity: [CODE] message"
+// line per diagnostic, followed by a summary.
+func WriteValidationText(w io.Writer, reports []ValidationReport) error {
+	errors, warnings := 0, 0
+	for _, report := range reports {
+		for _, d := range report.Diagnostics {
+			if _, err := fmt.Fprintf(w, "%s:%s\n", report.Path, d); err != nil {
+				return err
+			}
+		}
+		errors += report.Errors()
+		warnings += report.Warnings()
+	}
+	_, err := fmt.Fprintf(w, "%d file(s) checked: %d error(s), %d warning(s)\n", len(reports), errors, warnings)
+	return err
+}
@@ -0,0 +1,151 @@
+package icaljson
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+)
+
+// icalWeekdays are the BYDAY codes of time.Weekday values.
+var icalWeekdays = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// usedTimeZone is a zone referenced by a TZID and the first year it is needed for.
+type usedTimeZone struct {
+	loc  *time.Location
+	year int
+}
+
+// calendarTimeZones returns the known IANA zones used by events, tasks and
+// availability components, ordered by name.
+func calendarTimeZones(c *Calendar) []usedTimeZone {
+	years := map[string]int{}
+	locations := map[string]*time.Location{}
+	use := func(e Event) {
+		if e.TZID == "" {
+			return
+		}
+		loc, err := time.LoadLocation(e.TZID)
+		if err != nil {
+			return
+		}
+		year := time.Now().Year()
+		if start, _, err := ParseDateTime(e.Start, loc); err == nil {
+			year = start.Year()
+		}
+		if first, ok := years[e.TZID]; !ok || year < first {
+			years[e.TZID] = year
+		}
+		locations[e.TZID] = loc
+	}
+	for _, e := range c.Events {
+		use(e)
+	}
+	for _, t := range c.Todos {
+		use(t.Event)
+	}
+	for _, a := range c.Availability {
+		use(a.Event)
+		for _, available := range a.Available {
+			use(available)
+		}
+	}
+
+	var zones []usedTimeZone
+	for _, tzid := range slices.Sorted(maps.Keys(locations)) {
+		zones = append(zones, usedTimeZone{loc: locations[tzid], year: years[tzid]})
+	}
+	return zones
+}
+
+// zoneTransition is a change of UTC offset.
+type zoneTransition struct {
+	at       time.Time // Instant of the change
+	from, to int       // UTC offsets in seconds before and after
+	name     string    // Abbreviation after the change, e.g. CEST
+	daylight bool
+}
+
+// timezone writes a VTIMEZONE for loc. The observances are derived from the
+// transitions in the year before year, and repeat yearly on the same
+// weekday of the month, e.g. the last Sunday of March.
+func (iw *icsWriter) timezone(loc *time.Location, year int) {
+	iw.line("BEGIN", nil, "VTIMEZONE")
+	iw.line("TZID", nil, loc.String())
+
+	transitions := yearTransitions(loc, year-1)
+	if len(transitions) == 0 {
+		name, offset := time.Date(year, 1, 1, 0, 0, 0, 0, loc).Zone()
+		iw.line("BEGIN", nil, "STANDARD")
+		iw.line("DTSTART", nil, "19700101T000000")
+		iw.line("TZOFFSETFROM", nil, formatUTCOffset(offset))
+		iw.line("TZOFFSETTO", nil, formatUTCOffset(offset))
+		iw.raw("TZNAME", nil, name)
+		iw.line("END", nil, "STANDARD")
+	}
+	for _, tr := range transitions {
+		kind := "STANDARD"
+		if tr.daylight {
+			kind = "DAYLIGHT"
+		}
+		// DTSTART is the local time before the change
+		local := tr.at.In(time.FixedZone("", tr.from))
+		week := (local.Day()-1)/7 + 1
+		if local.Day()+7 > daysIn(local.Month(), local.Year()) {
+			week = -1
+		}
+
+		iw.line("BEGIN", nil, kind)
+		iw.line("DTSTART", nil, local.Format("20060102T150405"))
+		iw.line("RRULE", nil, fmt.Sprintf("FREQ=YEARLY;BYMONTH=%d;BYDAY=%d%s", local.Month(), week, icalWeekdays[local.Weekday()]))
+		iw.line("TZOFFSETFROM", nil, formatUTCOffset(tr.from))
+		iw.line("TZOFFSETTO", nil, formatUTCOffset(tr.to))
+		iw.raw("TZNAME", nil, tr.name)
+		iw.line("END", nil, kind)
+	}
+	iw.line("END", nil, "VTIMEZONE")
+}
+
+// yearTransitions finds the offset changes of loc within a year.
+func yearTransitions(loc *time.Location, year int) []zoneTransition {
+	var transitions []zoneTransition
+	t := time.Date(year, 1, 1, 0, 0, 0, 0, loc)
+	end := time.Date(year+1, 1, 1, 0, 0, 0, 0, loc)
+	for t.Before(end) {
+		next := t.Add(24 * time.Hour)
+		_, before := t.Zone()
+		if _, after := next.Zone(); after != before {
+			// Narrow the change down to the second
+			lo, hi := t, next
+			for hi.Sub(lo) > time.Second {
+				mid := lo.Add(hi.Sub(lo) / 2)
+				if _, offset := mid.Zone(); offset == before {
+					lo = mid
+				} else {
+					hi = mid
+				}
+			}
+			name, offset := hi.Zone()
+			transitions = append(transitions, zoneTransition{at: hi, from: before, to: offset, name: name, daylight: hi.IsDST()})
+		}
+		t = next
+	}
+	return transitions
+}
+
+// formatUTCOffset formats an offset in seconds as an RFC 5545 UTC-OFFSET.
+func formatUTCOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign, seconds = '-', -seconds
+	}
+	offset := fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds/60%60)
+	if s := seconds % 60; s != 0 {
+		offset += fmt.Sprintf("%02d", s)
+	}
+	return offset
+}
+
+func daysIn(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}